@@ -0,0 +1,101 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// defaultAccountTTL is how long account details are cached when Options.AccountTTL is nil
+const defaultAccountTTL = 10 * time.Minute
+
+// Account holds the details of the account the token belongs to
+type Account struct {
+	ID         string // ID of the account
+	Tier       string // tier of the account (eg: "standard")
+	RootFolder string // ID of the account's root folder
+	Email      string // email address of the account, empty for guests
+}
+
+// accountCache holds the account resolved for a token until it expires
+type accountCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	token    string    // token the account was resolved with
+	account  *Account  // nil until resolved
+	resolved time.Time // time the account was resolved
+}
+
+// Account returns the ID, tier and root folder of the account.
+//
+// The details are resolved with GetAccountID and GetAccountInformation on first use and
+// cached for Options.AccountTTL. Changing the token (SetToken, guest mode) discards the cache.
+//
+// Returns the account or an error.
+func (a *Api) Account() (Account, error) {
+	c := a.account
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.account != nil && c.token == a.client.APIToken() && time.Since(c.resolved) < c.ttl {
+		return *c.account, nil
+	}
+	if _, err := a.refreshAccount(); err != nil {
+		return Account{}, err
+	}
+	return *c.account, nil
+}
+
+// accountInformation fetches the current account information and refreshes the cached account with it.
+//
+// The account ID is taken from the cache when it was resolved for the current token, even if it expired,
+// since it does not change for a token.
+func (a *Api) accountInformation() (model.AccountInformationData, error) {
+	a.account.mu.Lock()
+	defer a.account.mu.Unlock()
+	return a.refreshAccount()
+}
+
+// refreshAccount fetches the account information and caches the account, the cache lock must be held
+func (a *Api) refreshAccount() (model.AccountInformationData, error) {
+	c := a.account
+	token := a.client.APIToken()
+	id := ""
+	if c.account != nil && c.token == token {
+		id = c.account.ID
+	}
+	if id == "" {
+		resp, err := a.GetAccountID()
+		if err != nil {
+			return model.AccountInformationData{}, err
+		}
+		id = resp.Data.ID
+	}
+	info, err := a.GetAccountInformation(id)
+	if err != nil {
+		return model.AccountInformationData{}, err
+	}
+	c.account = &Account{
+		ID:         info.Data.ID,
+		Tier:       info.Data.Tier,
+		RootFolder: info.Data.RootFolder,
+		Email:      info.Data.Email,
+	}
+	c.token = token
+	c.resolved = time.Now()
+	return info.Data, nil
+}
+
+// InvalidateAccount discards the cached account so the next call to Account resolves it again
+func (a *Api) InvalidateAccount() {
+	a.account.mu.Lock()
+	defer a.account.mu.Unlock()
+	a.account.account = nil
+}
+
+// SetToken changes the token used for the following requests and discards the cached account
+func (a *Api) SetToken(token string) {
+	a.client.SetAPIToken(token)
+	a.InvalidateAccount()
+}
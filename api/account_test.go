@@ -0,0 +1,95 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountCached(t *testing.T) {
+	srv := &accountServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	for range 3 {
+		acc, err := a.Account()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.ID != "acc-token" || acc.RootFolder != "root-token" || acc.Tier != "standard" {
+			t.Errorf("account is %+v", acc)
+		}
+	}
+	if srv.count("getid") != 1 || srv.count("info") != 1 {
+		t.Errorf("sent %v, want the account resolved once", srv.requests)
+	}
+}
+
+func TestAccountTTL(t *testing.T) {
+	srv := &accountServer{}
+	ttl := 10 * time.Millisecond
+	a := testApi(t, srv.ServeHTTP, &Options{AccountTTL: &ttl})
+
+	if _, err := a.Account(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * ttl)
+	if _, err := a.Account(); err != nil {
+		t.Fatal(err)
+	}
+	// the ID of a token never changes, only the information is fetched again
+	if srv.count("getid") != 1 || srv.count("info") != 2 {
+		t.Errorf("sent %v, want the information fetched again after the TTL", srv.requests)
+	}
+}
+
+func TestSetTokenInvalidatesAccount(t *testing.T) {
+	srv := &accountServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	if _, err := a.Account(); err != nil {
+		t.Fatal(err)
+	}
+	a.SetToken("other")
+	acc, err := a.Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.ID != "acc-other" || acc.RootFolder != "root-other" {
+		t.Errorf("account after SetToken is %+v, want the account of the new token", acc)
+	}
+	if srv.count("getid") != 2 {
+		t.Errorf("sent %v, want the account resolved again for the new token", srv.requests)
+	}
+}
+
+func TestInvalidateAccount(t *testing.T) {
+	srv := &accountServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	if _, err := a.Account(); err != nil {
+		t.Fatal(err)
+	}
+	a.InvalidateAccount()
+	if _, err := a.Account(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.count("getid") != 2 || srv.count("info") != 2 {
+		t.Errorf("sent %v, want the account resolved again after InvalidateAccount", srv.requests)
+	}
+}
+
+func TestResumeGuestInvalidatesAccount(t *testing.T) {
+	srv := &accountServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	if _, err := a.Account(); err != nil {
+		t.Fatal(err)
+	}
+	a.ResumeGuest(GuestSession{Token: "guest", FolderID: "gfld"})
+	acc, err := a.Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.ID != "acc-guest" {
+		t.Errorf("account after ResumeGuest is %+v, want the guest account", acc)
+	}
+}
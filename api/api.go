@@ -2,40 +2,56 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/plutack/go-gofile/internal/client"
+	"github.com/plutack/go-gofile/metrics"
 	"github.com/plutack/go-gofile/model"
+	"github.com/plutack/go-gofile/tracing"
 )
 
 type Api struct {
-	client *client.Client
+	client  *client.Client
+	logger  *slog.Logger
+	metrics metrics.Recorder
+	tracer  tracing.Tracer
+	guest   *guestState // guest tracks guest mode and its session
+
+	tierLimits map[string]TierLimits
+	account    *accountCache
 }
 
 // Options defines optional configuration for the API client.
 type Options struct {
-	APIToken   *string // APIToken is the authentication token for the GoFile.io API
-	RetryCount *int    // RetryCount specifies the number of times to retry failed API requests
-	Timeout    *int    // Timeout specifies the maximum time to wait for an API Request to be resolved
-
+	APIToken   *string      // APIToken is the authentication token for the GoFile.io API
+	RetryCount *int         // RetryCount specifies the number of times to retry failed API requests
+	Timeout    *int         // Timeout specifies the maximum time to wait for an API Request to be resolved
+	Logger     *slog.Logger // Logger receives request logs, response bodies are logged at debug level. Logs are discarded if nil
+	Guest      bool         // Guest ignores any token and lets gofile create a guest account on the first upload, see Api.Guest
+
+	TierLimits map[string]TierLimits // TierLimits maps tier names (eg: "standard") to their quotas, gofile does not report them so quota checks need them
+	AccountTTL *time.Duration        // AccountTTL specifies how long Api.Account caches the account details, defaults to 10 minutes
+	Metrics    metrics.Recorder      // Metrics records requests, retries and transfers, see metrics.Registry. Nothing is recorded if nil
+	Tracer     tracing.Tracer        // Tracer receives spans around API calls and HTTP requests, see package tracing. Nothing is traced if nil
+	Transport  http.RoundTripper     // Transport sends every request including uploads and downloads, e.g. a cassette.Recorder. http.DefaultTransport is used if nil
+	Context    context.Context       // Context aborts pending requests and retry waits once it is done, context.Background is used if nil
 }
 
 // New initializes a new API client with optional configuration.
 //
 // If opts is nil, default client settings are used.
 func New(opts *Options) *Api {
-	clientConfig := client.NewDefaultClientConfig()
 	if opts == nil {
-		apiClient := client.NewClient(clientConfig)
-		return &Api{
-			client: apiClient,
-		}
-
+		opts = &Options{}
 	}
+	clientConfig := client.NewDefaultClientConfig()
 	if opts.APIToken != nil {
 		clientConfig.APIToken = *opts.APIToken
 	}
@@ -48,24 +64,46 @@ func New(opts *Options) *Api {
 		clientConfig.Timeout = time.Duration(*opts.Timeout) * time.Second
 	}
 
-	apiClient := client.NewClient(clientConfig)
+	logger := slog.New(slog.DiscardHandler)
+	if opts.Logger != nil {
+		logger = opts.Logger
+	}
 
-	return &Api{
-		client: apiClient,
+	guest := &guestState{enabled: opts.Guest}
+	if opts.Guest {
+		clientConfig.APIToken = ""
 	}
-}
 
-// readResponseBody reads and returns the response body as a byte slice.
-func readResponseBody(r *http.Response) ([]byte, error) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return nil, err
+	accountTTL := defaultAccountTTL
+	if opts.AccountTTL != nil {
+		accountTTL = *opts.AccountTTL
+	}
+
+	var recorder metrics.Recorder = metrics.Nop{}
+	if opts.Metrics != nil {
+		recorder = opts.Metrics
+	}
+	clientConfig.Metrics = recorder
+
+	var tracer tracing.Tracer = tracing.Nop{}
+	if opts.Tracer != nil {
+		tracer = opts.Tracer
 	}
-	defer r.Body.Close()
+	clientConfig.Tracer = tracer
+	clientConfig.Transport = opts.Transport
+	clientConfig.Context = opts.Context
 
-	// Logs the response body for debugging just to pipe to jq
-	fmt.Println(string(body))
-	return body, nil
+	apiClient := client.NewClient(clientConfig)
+
+	return &Api{
+		client:     apiClient,
+		logger:     logger,
+		metrics:    recorder,
+		tracer:     tracer,
+		guest:      guest,
+		tierLimits: opts.TierLimits,
+		account:    &accountCache{ttl: accountTTL},
+	}
 }
 
 // GetAvailableServers retrieves available servers, optionally filtered by zone
@@ -73,40 +111,32 @@ func readResponseBody(r *http.Response) ([]byte, error) {
 // zone can either be "eu" or "na"
 // Returns a structured response or an error.
 func (a *Api) GetAvailableServers(zone string) (model.AvailableServerResponse, error) {
-	resp, err := a.client.GetAvailableServers(zone)
-	if err != nil {
-		return model.AvailableServerResponse{}, err
-
-	}
-	buf, err := readResponseBody(resp)
-	if err != nil {
-		return model.AvailableServerResponse{}, err
-	}
-
-	var body model.AvailableServerResponse
-	json.Unmarshal(buf, &body)
-	return body, nil
+	return do[model.AvailableServerData](a, "servers", func() (*http.Response, error) {
+		return a.client.GetAvailableServers(zone)
+	})
 }
 
 // DeleteContent delete files and folders uploaded or created by user
 //
-// If contentID arguement is not supplied code panics.
+// The deletion is immediate and cannot be undone, see SafeDelete for previews and confirmation.
+//
+// A *ValidationError is returned if no contentID is supplied or one of them is malformed.
 //
 // Returns a structured response or an error.
 func (a *Api) DeleteContent(contentID ...string) (model.DeleteContentResponse, error) {
-	resp, err := a.client.DeleteContent(contentID)
-	if err != nil {
-		return model.DeleteContentResponse{}, err
-
-	}
-	buf, err := readResponseBody(resp)
-	if err != nil {
+	if err := validateIDs("contentID", contentID); err != nil {
 		return model.DeleteContentResponse{}, err
 	}
+	return do[model.DeleteContentData](a, "deleteContent", func() (*http.Response, error) {
+		return a.client.DeleteContent(contentID)
+	})
+}
 
-	var body model.DeleteContentResponse
-	json.Unmarshal(buf, &body)
-	return body, nil
+// UpdateResult is the outcome of changing a single attribute with Update
+type UpdateResult struct {
+	Attribute string                      // name of the attribute that was changed
+	Response  model.UpdateContentResponse // response returned by gofile if the request was made
+	Err       error                       // error encountered while changing the attribute, nil on success
 }
 
 // UpdateContent changes the attribute of a file or a folder.
@@ -121,64 +151,141 @@ func (a *Api) DeleteContent(contentID ...string) (model.DeleteContentResponse, e
 //
 // 4. type public = bool
 //
-// 5. type expiry = string
+// 5. type expiry = time.Time, time.Duration or string in RFC3339 format
 //
 // 6. type password = string
 //
+// Deprecated: use Update with the typed options from the model package (eg: model.SetName).
+//
 // Returns a structured response or an error.
 func (a *Api) UpdateContent(contentID string, attribute string, newAttributeValue any) (model.UpdateContentResponse, error) {
-	resp, err := a.client.UpdateContent(contentID, attribute, newAttributeValue)
-	if err != nil {
+	if err := validateID("contentID", contentID); err != nil {
 		return model.UpdateContentResponse{}, err
 	}
-	buf, err := readResponseBody(resp)
+	opt, err := model.AttributeOption(attribute, newAttributeValue)
 	if err != nil {
 		return model.UpdateContentResponse{}, err
 	}
+	return a.updateContent(contentID, opt)
+}
+
+// Update changes one or more attributes of a file or a folder.
+//
+// gofile only changes one attribute per request so each option is sent separately,
+// a failing option does not prevent the following ones from being applied.
+//
+//	c.Update(id, model.SetName("report.pdf"), model.SetPublic(false))
+//
+// Returns the result of each option in the order given and an error joining all failures.
+func (a *Api) Update(contentID string, opts ...model.UpdateOption) ([]UpdateResult, error) {
+	if err := validateID("contentID", contentID); err != nil {
+		return nil, err
+	}
+	results := make([]UpdateResult, 0, len(opts))
+	var errs []error
+	for _, opt := range opts {
+		r := a.applyUpdate(contentID, opt)
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Attribute, r.Err))
+		}
+		results = append(results, r)
+	}
+	return results, errors.Join(errs...)
+}
+
+// applyUpdate sends a single attribute change and records its outcome
+func (a *Api) applyUpdate(contentID string, opt model.UpdateOption) UpdateResult {
+	resp, err := a.updateContent(contentID, opt)
+	return UpdateResult{
+		Attribute: opt.Attribute(),
+		Response:  resp,
+		Err:       err,
+	}
+}
 
-	var body model.UpdateContentResponse
-	json.Unmarshal(buf, &body)
-	return body, nil
+// updateContent sends a single attribute change
+func (a *Api) updateContent(contentID string, opt model.UpdateOption) (model.UpdateContentResponse, error) {
+	return do[model.UpdateContentData](a, "updateContent", func() (*http.Response, error) {
+		return a.client.UpdateContent(contentID, opt)
+	})
 }
 
 // UploadFile saves a file on a specified server
 //
+// In guest mode an empty folderID uploads into the guest folder, which is created
+// along with the guest account by the first upload.
+//
 // Returns a structured response or an error.
 func (a *Api) UploadFile(server string, filePath string, folderID string, callbackUpdate client.ProgressCallback) (model.UploadFileResponse, error) {
-	resp, err := a.client.UploadFile(server, filePath, folderID, callbackUpdate)
-	if err != nil {
+	if err := validateID("server", server); err != nil {
 		return model.UploadFileResponse{}, err
+	}
+	if folderID == "" && a.guestMode() {
+		return a.guestSend(func(folderID string) (model.UploadFileResponse, error) {
+			return a.uploadFile(server, filePath, folderID, callbackUpdate)
+		})
+	}
+	if folderID != "" {
+		if err := validateID("folderID", folderID); err != nil {
+			return model.UploadFileResponse{}, err
+		}
+	}
+	return a.uploadFile(server, filePath, folderID, callbackUpdate)
+}
 
+// UploadReader saves the content read from r as a file named name on a specified server
+//
+// The content is streamed without being buffered, size is only used for progress updates
+// and can be -1 if unknown. Guest mode applies as in UploadFile.
+//
+// Returns a structured response or an error.
+func (a *Api) UploadReader(server string, name string, r io.Reader, size int64, folderID string, callbackUpdate client.ProgressCallback) (model.UploadFileResponse, error) {
+	if err := validateID("server", server); err != nil {
+		return model.UploadFileResponse{}, err
 	}
-	buf, err := readResponseBody(resp)
-	if err != nil {
+	if err := validateName("name", name); err != nil {
 		return model.UploadFileResponse{}, err
 	}
+	send := func(folderID string) (model.UploadFileResponse, error) {
+		return do[model.UploadFileData](a, "uploadFile", func() (*http.Response, error) {
+			return a.client.UploadReader(server, name, r, size, folderID, callbackUpdate)
+		})
+	}
+	if folderID == "" && a.guestMode() {
+		return a.guestSend(send)
+	}
+	if folderID != "" {
+		if err := validateID("folderID", folderID); err != nil {
+			return model.UploadFileResponse{}, err
+		}
+	}
+	return send(folderID)
+}
 
-	var body model.UploadFileResponse
-	json.Unmarshal(buf, &body)
-	return body, nil
+// uploadFile sends the upload request
+func (a *Api) uploadFile(server string, filePath string, folderID string, callbackUpdate client.ProgressCallback) (model.UploadFileResponse, error) {
+	return do[model.UploadFileData](a, "uploadFile", func() (*http.Response, error) {
+		return a.client.UploadFile(server, filePath, folderID, callbackUpdate)
+	})
 }
 
 // CreateFolder makes a new folder at the root of the specified parent folder id
 //
+// A *ValidationError is returned if parentFolderID is malformed or name is empty.
+//
 //	See model.CreateFolderResponse for struct structure
 //
 // Returns a structured response or an error.
 func (a *Api) CreateFolder(parentFolderID string, name string) (model.CreateFolderResponse, error) {
-	resp, err := a.client.CreateFolder(parentFolderID, name)
-	if err != nil {
+	if err := validateID("parentFolderID", parentFolderID); err != nil {
 		return model.CreateFolderResponse{}, err
-
 	}
-	buf, err := readResponseBody(resp)
-	if err != nil {
+	if err := validateName("name", name); err != nil {
 		return model.CreateFolderResponse{}, err
 	}
-
-	var body model.CreateFolderResponse
-	json.Unmarshal(buf, &body)
-	return body, nil
+	return do[model.CreateFolderData](a, "createFolder", func() (*http.Response, error) {
+		return a.client.CreateFolder(parentFolderID, name)
+	})
 }
 
 // GetAccountID returns a struct containing the user account ID.
@@ -187,42 +294,72 @@ func (a *Api) CreateFolder(parentFolderID string, name string) (model.CreateFold
 //
 // Returns a structured response or an error.
 func (a *Api) GetAccountID() (model.AccountIDResponse, error) {
-	resp, err := a.client.GetAccountId()
-	if err != nil {
-		return model.AccountIDResponse{}, err
-
-	}
-	buf, err := readResponseBody(resp)
-	if err != nil {
-		return model.AccountIDResponse{}, err
-	}
-
-	var body model.AccountIDResponse
-	json.Unmarshal(buf, &body)
-	return body, nil
+	return do[model.AccountIDData](a, "getAccountId", func() (*http.Response, error) {
+		return a.client.GetAccountId()
+	})
 }
 
 // GetAccountInformation returns a struct containing the user account information.
 //
-// NOTE: this is where the root folder ID can be gotten from
+// NOTE: this is where the root folder ID can be gotten from, Account caches it along with the account ID
 //
 //	See model.AccountInformationResponse for struct structure
 //
 // Returns a structured response or an error.
 func (a *Api) GetAccountInformation(accountId string) (model.AccountInformationResponse, error) {
-	resp, err := a.client.GetAccountInformation(accountId)
-	if err != nil {
+	if err := validateID("accountId", accountId); err != nil {
 		return model.AccountInformationResponse{}, err
+	}
+	return do[model.AccountInformationData](a, "getAccountInformation", func() (*http.Response, error) {
+		return a.client.GetAccountInformation(accountId)
+	})
+}
 
+// GetContent returns the details of a file or folder.
+//
+// For folders the direct children are listed in Data.Children.
+// ErrPasswordRequired is returned for password protected content, use GetContentWithPassword instead.
+//
+//	See model.ContentResponse for struct structure
+//
+// Returns a structured response or an error.
+func (a *Api) GetContent(contentID string) (model.ContentResponse, error) {
+	if err := validateID("contentID", contentID); err != nil {
+		return model.ContentResponse{}, err
 	}
-	buf, err := readResponseBody(resp)
-	if err != nil {
-		return model.AccountInformationResponse{}, err
+	return a.getContent(contentID, "")
+}
+
+// CopyContent copies files and folders into the folder with the specified folderID
+//
+// NOTE: this is a premium only feature
+//
+// Returns a structured response or an error.
+func (a *Api) CopyContent(folderID string, contentID ...string) (model.CopyContentResponse, error) {
+	if err := validateID("folderID", folderID); err != nil {
+		return model.CopyContentResponse{}, err
+	}
+	if err := validateIDs("contentID", contentID); err != nil {
+		return model.CopyContentResponse{}, err
 	}
+	return do[json.RawMessage](a, "copyContent", func() (*http.Response, error) {
+		return a.client.CopyContent(contentID, folderID)
+	})
+}
 
-	var body model.AccountInformationResponse
-	json.Unmarshal(buf, &body)
-	return body, nil
+// MoveContent moves files and folders into the folder with the specified folderID
+//
+// Returns a structured response or an error.
+func (a *Api) MoveContent(folderID string, contentID ...string) (model.MoveContentResponse, error) {
+	if err := validateID("folderID", folderID); err != nil {
+		return model.MoveContentResponse{}, err
+	}
+	if err := validateIDs("contentID", contentID); err != nil {
+		return model.MoveContentResponse{}, err
+	}
+	return do[json.RawMessage](a, "moveContent", func() (*http.Response, error) {
+		return a.client.MoveContent(contentID, folderID)
+	})
 }
 
 // features to be implemented
@@ -231,6 +368,4 @@ func (a *Api) GetAccountInformation(accountId string) (model.AccountInformationR
 // func (a *api) CreateDirectLink()       {}
 // func (a *api) UpdateDirectLinkConfig() {}
 // func (a *api) DeleteDirectLink()       {}
-// func (a *api) CopyContent()            {}
-// func (a *api) MoveContent()            {}
 // func (a *api) ImportContent()          {}
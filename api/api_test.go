@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// handlerTransport answers every request with h instead of sending it over the network
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.h.ServeHTTP(w, req)
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// testApi returns an Api whose requests are answered by h, opts may be nil.
+// h is ignored if opts sets a Transport.
+func testApi(t *testing.T, h http.HandlerFunc, opts *Options) *Api {
+	t.Helper()
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.APIToken == nil && !opts.Guest {
+		token := "token"
+		opts.APIToken = &token
+	}
+	if opts.RetryCount == nil {
+		retries := 0
+		opts.RetryCount = &retries
+	}
+	if opts.Transport == nil {
+		opts.Transport = handlerTransport{h: h}
+	}
+	return New(opts)
+}
+
+// writeData answers with a gofile response with status "ok" and data
+func writeData(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"status": "ok", "data": data})
+}
+
+// writeStatus answers with a gofile error status and the HTTP status code
+func writeStatus(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"status": status, "data": map[string]any{}})
+}
+
+// updateBody decodes the attribute change sent to the update endpoint
+func updateBody(t *testing.T, r *http.Request) (attribute string, value any) {
+	t.Helper()
+	var body struct {
+		Attribute      string `json:"attribute"`
+		AttributeValue any    `json:"attributeValue"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Errorf("decode update body: %v", err)
+	}
+	return body.Attribute, body.AttributeValue
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// defaultBatchConcurrency is the number of contents updated at the same time when none is configured
+const defaultBatchConcurrency = 4
+
+// BatchOptions defines how a batch of updates is sent.
+type BatchOptions struct {
+	Concurrency int           // Concurrency is the maximum number of contents updated at the same time, defaults to 4
+	Interval    time.Duration // Interval is the minimum delay between two requests, 0 disables rate limiting
+}
+
+// BatchResult is the outcome of updating a single content in a batch
+type BatchResult struct {
+	ContentID string         // ID of the content that was updated
+	Results   []UpdateResult // result of each attribute change in the order given
+	Tags      []string       // tags of the content after BatchAddTags or BatchRemoveTags
+	Err       error          // error joining all failed attribute changes, nil on success
+}
+
+// BatchReport lists the outcome of every content in a batch in the order they were given
+type BatchReport struct {
+	Results []BatchResult
+}
+
+// Succeeded returns the contents whose attributes were all changed
+func (r BatchReport) Succeeded() []BatchResult {
+	var ok []BatchResult
+	for _, res := range r.Results {
+		if res.Err == nil {
+			ok = append(ok, res)
+		}
+	}
+	return ok
+}
+
+// Failed returns the contents for which at least one attribute change failed
+func (r BatchReport) Failed() []BatchResult {
+	var failed []BatchResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns an error joining the failures of every content, nil if all succeeded
+func (r BatchReport) Err() error {
+	var errs []error
+	for _, res := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", res.ContentID, res.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// BatchUpdate applies the same attribute changes to every content in contentIDs.
+//
+// A failure on one content does not stop the others, check the report for per content results.
+// model.SetTags replaces every tag of a content, use BatchAddTags or BatchRemoveTags to keep the others.
+//
+//	report := c.BatchUpdate(ids, api.BatchOptions{Interval: 200 * time.Millisecond}, model.SetPublic(false))
+//
+// Returns a report with one result per content ID.
+func (a *Api) BatchUpdate(contentIDs []string, opts BatchOptions, update ...model.UpdateOption) BatchReport {
+	return a.batch(contentIDs, opts, func(contentID string, wait func()) BatchResult {
+		return a.batchUpdateOne(contentID, wait, update)
+	})
+}
+
+// BatchAddTags adds tags to every content in contentIDs while keeping their existing tags, see AddTags.
+//
+// Each content is read before it is written so it takes two requests, both subject to opts.Interval.
+//
+// Returns a report with one result per content ID, holding the tags of the content after the change.
+func (a *Api) BatchAddTags(contentIDs []string, opts BatchOptions, tags ...string) BatchReport {
+	return a.batchTags(contentIDs, opts, tags, addTags)
+}
+
+// BatchRemoveTags removes tags from every content in contentIDs while keeping their other tags, see RemoveTags.
+//
+// Each content is read before it is written so it takes two requests, both subject to opts.Interval.
+//
+// Returns a report with one result per content ID, holding the tags of the content after the change.
+func (a *Api) BatchRemoveTags(contentIDs []string, opts BatchOptions, tags ...string) BatchReport {
+	return a.batchTags(contentIDs, opts, tags, removeTags)
+}
+
+// batchTags changes the tags of every content with change
+func (a *Api) batchTags(contentIDs []string, opts BatchOptions, tags []string, change func(current []string, tags []string) []string) BatchReport {
+	if err := validateTags(tags); err != nil {
+		report := BatchReport{Results: make([]BatchResult, len(contentIDs))}
+		for i, id := range contentIDs {
+			report.Results[i] = BatchResult{ContentID: id, Err: err}
+		}
+		return report
+	}
+	return a.batch(contentIDs, opts, func(contentID string, wait func()) BatchResult {
+		res := BatchResult{ContentID: contentID}
+		if err := validateID("contentID", contentID); err != nil {
+			res.Err = err
+			return res
+		}
+		res.Tags, res.Err = a.changeTags(contentID, tags, wait, change)
+		return res
+	})
+}
+
+// batch runs one for every content in contentIDs on opts.Concurrency workers.
+//
+// one must call wait before each request it sends so opts.Interval is respected across workers.
+func (a *Api) batch(contentIDs []string, opts BatchOptions, one func(contentID string, wait func()) BatchResult) BatchReport {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	// wait blocks until the next request is allowed to be sent
+	wait := func() {}
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		wait = func() { <-ticker.C }
+	}
+
+	report := BatchReport{Results: make([]BatchResult, len(contentIDs))}
+	ids := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(contentIDs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ids {
+				report.Results[i] = one(contentIDs[i], wait)
+			}
+		}()
+	}
+	for i := range contentIDs {
+		ids <- i
+	}
+	close(ids)
+	wg.Wait()
+	return report
+}
+
+// batchUpdateOne applies every option to a single content, calling wait before each request.
+//
+// An invalid contentID is recorded in its result without sending any request.
+func (a *Api) batchUpdateOne(contentID string, wait func(), update []model.UpdateOption) BatchResult {
+	res := BatchResult{ContentID: contentID}
+	if err := validateID("contentID", contentID); err != nil {
+		res.Err = err
+		return res
+	}
+	var errs []error
+	for _, opt := range update {
+		wait()
+		r := a.applyUpdate(contentID, opt)
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Attribute, r.Err))
+		}
+		res.Results = append(res.Results, r)
+	}
+	res.Err = errors.Join(errs...)
+	return res
+}
+
+// BatchUpdateFolder applies the same attribute changes to every item in the folder with the specified folderID.
+//
+// If recursive is true, items of subfolders are updated too. The folder itself is not updated.
+//
+// Returns a report with one result per item or an error if a folder could not be listed.
+func (a *Api) BatchUpdateFolder(folderID string, recursive bool, opts BatchOptions, update ...model.UpdateOption) (BatchReport, error) {
+	ids, err := a.listIDs(folderID, recursive)
+	if err != nil {
+		return BatchReport{}, err
+	}
+	return a.BatchUpdate(ids, opts, update...), nil
+}
+
+// listIDs returns the IDs of the items in the folder with the specified folderID.
+//
+// Children are listed by name then ID, each subfolder followed by its items, so reports come in a stable order.
+func (a *Api) listIDs(folderID string, recursive bool) ([]string, error) {
+	resp, err := a.GetContent(folderID)
+	if err != nil {
+		return nil, err
+	}
+	children := slices.SortedFunc(maps.Values(resp.Data.Children), func(x, y model.Content) int {
+		return cmp.Or(strings.Compare(x.Name, y.Name), strings.Compare(x.ID, y.ID))
+	})
+	var ids []string
+	for _, child := range children {
+		ids = append(ids, child.ID)
+		if recursive && child.Type == model.FolderType {
+			sub, err := a.listIDs(child.ID, recursive)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, sub...)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// tagStore is a fake gofile holding the tags of files, keyed by content ID
+type tagStore struct {
+	t *testing.T
+
+	mu     sync.Mutex
+	tags   map[string]string
+	writes int
+}
+
+func (s *tagStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, update := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/contents/"), "/update")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags, ok := s.tags[id]
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "error-notFound")
+		return
+	}
+	if !update {
+		writeData(w, map[string]any{"id": id, "type": "file", "name": id, "tags": tags})
+		return
+	}
+	attribute, value := updateBody(s.t, r)
+	if attribute != "tags" {
+		s.t.Errorf("attribute %q updated, want tags", attribute)
+	}
+	s.tags[id], _ = value.(string)
+	s.writes++
+	writeData(w, map[string]any{"id": id, "type": "file", "name": id})
+}
+
+func TestBatchUpdateConcurrency(t *testing.T) {
+	var active, peak atomic.Int32
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		writeData(w, map[string]any{"id": "x", "type": "file"})
+	}, nil)
+
+	ids := []string{"a", "b", "c", "d", "e", "f"}
+	report := a.BatchUpdate(ids, BatchOptions{Concurrency: 2}, model.SetPublic(false))
+	if err := report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("%d contents updated at the same time, want at most 2", p)
+	}
+	for i, res := range report.Results {
+		if res.ContentID != ids[i] || len(res.Results) != 1 {
+			t.Errorf("result %d is %+v, want one change of %s", i, res, ids[i])
+		}
+	}
+}
+
+func TestBatchUpdateInterval(t *testing.T) {
+	var mu sync.Mutex
+	var sent []time.Time
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, time.Now())
+		mu.Unlock()
+		writeData(w, map[string]any{"id": "x", "type": "file"})
+	}, nil)
+
+	const interval = 20 * time.Millisecond
+	report := a.BatchUpdate([]string{"a", "b", "c", "d"}, BatchOptions{Concurrency: 4, Interval: interval}, model.SetPublic(true))
+	if err := report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(sent, time.Time.Compare)
+	for i := 1; i < len(sent); i++ {
+		// the ticker may deliver a tick late and the next one early, allow some jitter
+		if gap := sent[i].Sub(sent[i-1]); gap < interval/2 {
+			t.Errorf("requests %d and %d sent %s apart, want about %s", i-1, i, gap, interval)
+		}
+	}
+}
+
+func TestBatchUpdatePartialFailure(t *testing.T) {
+	var requests atomic.Int32
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if strings.HasPrefix(r.URL.Path, "/contents/gone/") {
+			writeStatus(w, http.StatusNotFound, "error-notFound")
+			return
+		}
+		writeData(w, map[string]any{"id": "x", "type": "file"})
+	}, nil)
+
+	report := a.BatchUpdate([]string{"ok", "gone", "bad/id"}, BatchOptions{}, model.SetPublic(true))
+	if n := len(report.Succeeded()); n != 1 {
+		t.Errorf("%d contents updated, want 1", n)
+	}
+	if !errors.Is(report.Results[1].Err, ErrNotFound) {
+		t.Errorf("deleted content failed with %v, want ErrNotFound", report.Results[1].Err)
+	}
+	var verr *ValidationError
+	if !errors.As(report.Results[2].Err, &verr) {
+		t.Errorf("malformed ID failed with %v, want a *ValidationError", report.Results[2].Err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests sent, want 2", n)
+	}
+}
+
+func TestBatchTags(t *testing.T) {
+	store := &tagStore{t: t, tags: map[string]string{"a": "keep", "b": "keep,old", "c": "new"}}
+	a := testApi(t, store.ServeHTTP, nil)
+
+	report := a.BatchAddTags([]string{"a", "b", "c"}, BatchOptions{}, "new")
+	if err := report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "keep,new", "b": "keep,old,new", "c": "new"}
+	for id, tags := range want {
+		if store.tags[id] != tags {
+			t.Errorf("%s has tags %q, want %q", id, store.tags[id], tags)
+		}
+	}
+	if store.writes != 2 {
+		t.Errorf("%d tag writes, want 2 since c already had the tag", store.writes)
+	}
+
+	report = a.BatchRemoveTags([]string{"b", "missing"}, BatchOptions{}, "old")
+	if !slices.Equal(report.Results[0].Tags, []string{"keep", "new"}) {
+		t.Errorf("b has tags %v after removal, want [keep new]", report.Results[0].Tags)
+	}
+	if !errors.Is(report.Results[1].Err, ErrNotFound) {
+		t.Errorf("missing content failed with %v, want ErrNotFound", report.Results[1].Err)
+	}
+
+	report = a.BatchAddTags([]string{"a"}, BatchOptions{}, "no,comma")
+	if !errors.Is(report.Results[0].Err, ErrInvalidTag) {
+		t.Errorf("invalid tag failed with %v, want ErrInvalidTag", report.Results[0].Err)
+	}
+}
+
+func TestBatchUpdateFolderOrder(t *testing.T) {
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/contents/root":
+			writeData(w, map[string]any{"id": "root", "type": "folder", "children": map[string]any{
+				"f3":  map[string]any{"id": "f3", "type": "file", "name": "c.txt"},
+				"f1":  map[string]any{"id": "f1", "type": "file", "name": "a.txt"},
+				"f0":  map[string]any{"id": "f0", "type": "file", "name": "a.txt"},
+				"sub": map[string]any{"id": "sub", "type": "folder", "name": "b"},
+			}})
+		case "/contents/sub":
+			writeData(w, map[string]any{"id": "sub", "type": "folder", "children": map[string]any{
+				"s2": map[string]any{"id": "s2", "type": "file", "name": "z.txt"},
+				"s1": map[string]any{"id": "s1", "type": "file", "name": "y.txt"},
+			}})
+		default:
+			writeData(w, map[string]any{"id": "x", "type": "file"})
+		}
+	}, nil)
+
+	report, err := a.BatchUpdateFolder("root", true, BatchOptions{Concurrency: 3}, model.SetPublic(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, res := range report.Results {
+		ids = append(ids, res.ContentID)
+	}
+	if want := []string{"f0", "f1", "sub", "s1", "s2", "f3"}; !slices.Equal(ids, want) {
+		t.Errorf("results for %v, want %v", ids, want)
+	}
+}
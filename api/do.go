@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+	"github.com/plutack/go-gofile/tracing"
+)
+
+// do runs call and decodes the returned response into the gofile envelope.
+//
+// endpoint names the API call in logs and errors. A response whose status is not "ok"
+// is returned along with an *APIError so the caller can still inspect it.
+func do[T any](a *Api, endpoint string, call func() (*http.Response, error)) (body model.Response[T], err error) {
+	span := a.tracer.Start("gofile.api", tracing.String("endpoint", endpoint))
+	defer func() {
+		if body.Status != "" {
+			span.SetAttributes(tracing.String("status", body.Status))
+		}
+		span.End(err)
+	}()
+
+	start := time.Now()
+	resp, err := call()
+	if err != nil {
+		a.metrics.ObserveRequest(endpoint, "error", time.Since(start))
+		a.logger.Error("gofile request failed", "endpoint", endpoint, "duration", time.Since(start), "error", err)
+		return model.Response[T]{}, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.metrics.ObserveRequest(endpoint, "error", time.Since(start))
+		a.logger.Error("gofile response read failed", "endpoint", endpoint, "duration", time.Since(start), "error", err)
+		return model.Response[T]{}, fmt.Errorf("read %s response failed: %w", endpoint, err)
+	}
+	a.logger.Debug("gofile response", "endpoint", endpoint, "httpStatus", resp.StatusCode, "duration", time.Since(start), "body", string(buf))
+	span.SetAttributes(tracing.Int("http.status_code", int64(resp.StatusCode)), tracing.Int("bytes", int64(len(buf))))
+
+	if err := json.Unmarshal(buf, &body); err != nil {
+		a.metrics.ObserveRequest(endpoint, "invalid-response", time.Since(start))
+		return body, &APIError{Endpoint: endpoint, HTTPStatus: resp.StatusCode, Err: err}
+	}
+	a.metrics.ObserveRequest(endpoint, body.Status, time.Since(start))
+	if body.Status != "ok" {
+		return body, &APIError{Endpoint: endpoint, HTTPStatus: resp.StatusCode, Status: body.Status}
+	}
+	return body, nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plutack/go-gofile/metrics"
+)
+
+func TestDoOK(t *testing.T) {
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization is %q, want the bearer token", got)
+		}
+		writeData(w, map[string]any{"id": "acc"})
+	}, nil)
+
+	resp, err := a.GetAccountID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "ok" || resp.Data.ID != "acc" {
+		t.Errorf("response is %+v, want account acc", resp)
+	}
+}
+
+func TestDoErrorStatus(t *testing.T) {
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, "error-notPremium")
+	}, nil)
+
+	resp, err := a.CopyContent("dst", "src")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error is %v, want an *APIError", err)
+	}
+	if apiErr.Endpoint != "copyContent" || apiErr.Status != "error-notPremium" || apiErr.HTTPStatus != http.StatusOK {
+		t.Errorf("error is %+v", apiErr)
+	}
+	if !errors.Is(err, ErrNotPremium) || errors.Is(err, ErrNotFound) {
+		t.Errorf("error %v does not match ErrNotPremium only", err)
+	}
+	if resp.Status != "error-notPremium" {
+		t.Errorf("response status is %q, the decoded response must be returned along with the error", resp.Status)
+	}
+}
+
+func TestDoInvalidBody(t *testing.T) {
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>bad gateway</html>"))
+	}, nil)
+
+	_, err := a.GetAccountID()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Err == nil || apiErr.HTTPStatus != http.StatusBadGateway {
+		t.Fatalf("error is %v, want an *APIError holding the decoding error", err)
+	}
+}
+
+func TestRetryWaitCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var attempts atomic.Int32
+	retries := 3
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		cancel()
+		writeStatus(w, http.StatusServiceUnavailable, "error-unavailable")
+	}, &Options{RetryCount: &retries, Context: ctx})
+
+	start := time.Now()
+	_, err := a.GetAccountID()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error is %v, want context.Canceled", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("%d attempts, want 1", n)
+	}
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("cancelled request returned after %s, the retry wait was not interrupted", d)
+	}
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		err    APIError
+		target error
+		want   bool
+	}{
+		{APIError{Status: "error-notFound"}, ErrNotFound, true},
+		{APIError{HTTPStatus: http.StatusNotFound}, ErrNotFound, true},
+		{APIError{Status: "error-notPremium"}, ErrNotPremium, true},
+		{APIError{Status: "error-rateLimit"}, ErrRateLimited, true},
+		{APIError{HTTPStatus: http.StatusTooManyRequests}, ErrRateLimited, true},
+		{APIError{Status: "error-passwordRequired"}, ErrPasswordRequired, true},
+		{APIError{Status: "error-passwordWrong"}, ErrPasswordWrong, true},
+		{APIError{Status: "error-auth"}, ErrUnauthorized, true},
+		{APIError{Status: "error-token"}, ErrUnauthorized, true},
+		{APIError{HTTPStatus: http.StatusUnauthorized}, ErrUnauthorized, true},
+		{APIError{HTTPStatus: http.StatusForbidden}, ErrUnauthorized, true},
+		{APIError{Status: "error-notFound"}, ErrUnauthorized, false},
+		{APIError{Status: "error-passwordRequired"}, ErrPasswordWrong, false},
+		{APIError{Status: "error-unknown", HTTPStatus: http.StatusOK}, ErrNotFound, false},
+	}
+	for _, tt := range tests {
+		if got := errors.Is(&tt.err, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%+v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestDoRecordsMetrics(t *testing.T) {
+	reg := metrics.NewRegistry(nil)
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, "error-notFound")
+	}, &Options{Metrics: reg})
+
+	a.DeleteContent("a")
+	a.DeleteContent("b")
+
+	var b strings.Builder
+	reg.WriteTo(&b)
+	for _, want := range []string{
+		`gofile_requests_total{endpoint="deleteContent",status="error-notFound"} 2`,
+		`gofile_request_duration_seconds_count{endpoint="deleteContent"} 2`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics do not hold %s:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// Download opens the file behind a direct download link (see model.Content.Link)
+//
+// The caller must close the returned reader.
+// Returns the file content as a stream or an error.
+func (a *Api) Download(link string) (io.ReadCloser, error) {
+	return a.DownloadFrom(link, 0)
+}
+
+// DownloadFrom opens the file behind a direct download link starting at byte offset
+//
+// The caller must close the returned reader.
+// Returns the file content from offset as a stream or an error.
+func (a *Api) DownloadFrom(link string, offset int64) (io.ReadCloser, error) {
+	resp, err := a.client.Download(link, offset)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &APIError{Endpoint: "download", HTTPStatus: resp.StatusCode}
+	}
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		// the server ignored the range, skip to offset ourselves
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("skip to offset %d failed: %w", offset, err)
+		}
+	}
+	return resp.Body, nil
+}
+
+// DownloadContent writes the file with the specified contentID to w.
+//
+// password is only needed for password protected files, leave it empty otherwise.
+//
+// Returns the details of the downloaded file or an error.
+func (a *Api) DownloadContent(contentID string, password string, w io.Writer) (model.Content, error) {
+	resp, err := a.GetContentWithPassword(contentID, password)
+	if err != nil {
+		return resp.Data, err
+	}
+	if resp.Data.Type != model.FileType {
+		return resp.Data, fmt.Errorf("content %s is a %s, only files can be downloaded", contentID, resp.Data.Type)
+	}
+	if resp.Data.Link == "" {
+		return resp.Data, fmt.Errorf("content %s has no download link", contentID)
+	}
+
+	r, err := a.Download(resp.Data.Link)
+	if err != nil {
+		return resp.Data, err
+	}
+	defer r.Close()
+	if _, err := io.Copy(w, r); err != nil {
+		return resp.Data, fmt.Errorf("download %s failed: %w", contentID, err)
+	}
+	return resp.Data, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors matching common gofile failures, use errors.Is on the error returned by any Api method
+var (
+	ErrNotFound     = errors.New("content not found")
+	ErrNotPremium   = errors.New("premium account required")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnauthorized = errors.New("unauthorized")
+
+	ErrPasswordRequired = errors.New("content is password protected")
+	ErrPasswordWrong    = errors.New("wrong content password")
+)
+
+// APIError is returned when gofile answers a request with a status other than "ok"
+type APIError struct {
+	Endpoint   string // Endpoint is the name of the API call that failed (eg: "uploadFile")
+	HTTPStatus int    // HTTPStatus is the HTTP status code of the response
+	Status     string // Status is the gofile status of the response (eg: "error-notFound"), empty if the body could not be decoded
+	Err        error  // Err is the decoding error if the body was not a valid gofile response
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("gofile %s failed (HTTP %d): %v", e.Endpoint, e.HTTPStatus, e.Err)
+	}
+	return fmt.Sprintf("gofile %s failed with status %q (HTTP %d)", e.Endpoint, e.Status, e.HTTPStatus)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is maps the gofile status and HTTP status code to the exported sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == "error-notFound" || e.HTTPStatus == http.StatusNotFound
+	case ErrNotPremium:
+		return e.Status == "error-notPremium"
+	case ErrRateLimited:
+		return e.Status == "error-rateLimit" || e.HTTPStatus == http.StatusTooManyRequests
+	case ErrPasswordRequired:
+		return e.Status == "error-passwordRequired"
+	case ErrPasswordWrong:
+		return e.Status == "error-passwordWrong"
+	case ErrUnauthorized:
+		return e.Status == "error-auth" || e.Status == "error-token" ||
+			e.HTTPStatus == http.StatusUnauthorized || e.HTTPStatus == http.StatusForbidden
+	}
+	return false
+}
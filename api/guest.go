@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// GuestSession holds the guest account gofile creates on the first anonymous upload
+//
+// Keep it to manage or delete the uploaded content later, or pass it to ResumeGuest
+// to keep uploading into the same folder from another process.
+type GuestSession struct {
+	Token        string `json:"token"`        // token of the guest account
+	FolderID     string `json:"folderId"`     // ID of the public folder holding the uploads
+	FolderCode   string `json:"folderCode"`   // short code of the public folder
+	DownloadPage string `json:"downloadPage"` // gofile.io download page of the public folder
+}
+
+// guestState tracks the guest session of an Api in guest mode
+type guestState struct {
+	mu      sync.Mutex    // held while the session is being created so concurrent uploads wait for it
+	enabled bool          // enabled is set in guest mode
+	session *GuestSession // nil until the first upload completes
+}
+
+// guestMode reports whether uploads without a folder go through the guest session
+func (a *Api) guestMode() bool {
+	a.guest.mu.Lock()
+	defer a.guest.mu.Unlock()
+	return a.guest.enabled
+}
+
+// Guest returns the guest session captured from the first upload.
+//
+// Returns the session and whether one exists.
+func (a *Api) Guest() (GuestSession, bool) {
+	a.guest.mu.Lock()
+	defer a.guest.mu.Unlock()
+	if a.guest.session == nil {
+		return GuestSession{}, false
+	}
+	return *a.guest.session, true
+}
+
+// ResumeGuest switches to guest mode using a session captured earlier.
+//
+// Following uploads without a folderID go to s.FolderID and every request uses s.Token.
+func (a *Api) ResumeGuest(s GuestSession) {
+	a.guest.mu.Lock()
+	defer a.guest.mu.Unlock()
+	a.guest.enabled = true
+	a.guest.session = &s
+	a.client.SetAPIToken(s.Token)
+}
+
+// guestSend runs send with the guest folder, creating the guest account on the first upload.
+//
+// If a guest account was already created for reading shared content, gofile uploads with its token
+// and returns no guest token, the session then keeps that token.
+func (a *Api) guestSend(send func(folderID string) (model.UploadFileResponse, error)) (model.UploadFileResponse, error) {
+	a.guest.mu.Lock()
+	if s := a.guest.session; s != nil {
+		folderID := s.FolderID
+		a.guest.mu.Unlock()
+		return send(folderID)
+	}
+	defer a.guest.mu.Unlock()
+
+	resp, err := send("")
+	if err != nil {
+		return resp, err
+	}
+	token := resp.Data.GuestToken
+	if token == "" {
+		token = a.client.APIToken()
+	}
+	if token == "" {
+		return resp, errors.New("gofile did not return a guest token, the uploaded file cannot be managed")
+	}
+	a.guest.session = &GuestSession{
+		Token:        token,
+		FolderID:     resp.Data.ParentFolder,
+		FolderCode:   resp.Data.ParentFolderCode,
+		DownloadPage: resp.Data.DownloadPage,
+	}
+	a.client.SetAPIToken(token)
+	return resp, nil
+}
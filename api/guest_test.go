@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// guestUploads is a fake upload server recording the folder and token of each upload,
+// it answers with guestToken on the upload sent without a token
+type guestUploads struct {
+	guestToken string
+
+	folders []string
+	tokens  []string
+}
+
+func (g *guestUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.folders = append(g.folders, r.FormValue("folderId"))
+	auth := r.Header.Get("Authorization")
+	g.tokens = append(g.tokens, strings.TrimPrefix(auth, "Bearer "))
+	data := map[string]any{
+		"id": "f", "type": "file", "name": "a.txt",
+		"parentFolder": "gfld", "parentFolderCode": "code", "downloadPage": "https://gofile.io/d/code",
+	}
+	if auth == "" && g.guestToken != "" {
+		data["guestToken"] = g.guestToken
+	}
+	writeData(w, data)
+}
+
+// upload sends a guest upload without a folder
+func upload(t *testing.T, a *Api) error {
+	t.Helper()
+	_, err := a.UploadReader("store1", "a.txt", strings.NewReader("hello"), 5, "", nil)
+	return err
+}
+
+func TestGuestCapturesToken(t *testing.T) {
+	srv := &guestUploads{guestToken: "gtok"}
+	a := testApi(t, srv.ServeHTTP, &Options{Guest: true})
+
+	if _, ok := a.Guest(); ok {
+		t.Fatal("a guest session exists before the first upload")
+	}
+	for range 2 {
+		if err := upload(t, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, ok := a.Guest()
+	if !ok || s.Token != "gtok" || s.FolderID != "gfld" || s.FolderCode != "code" {
+		t.Errorf("guest session is %+v, want token gtok and folder gfld", s)
+	}
+	if srv.tokens[0] != "" || srv.folders[0] != "" {
+		t.Errorf("first upload sent token %q into folder %q, want an anonymous upload", srv.tokens[0], srv.folders[0])
+	}
+	if srv.tokens[1] != "gtok" || srv.folders[1] != "gfld" {
+		t.Errorf("second upload sent token %q into folder %q, want the guest session", srv.tokens[1], srv.folders[1])
+	}
+}
+
+func TestGuestKeepsExistingToken(t *testing.T) {
+	// reading a share link created a guest account before the first upload
+	srv := &guestUploads{}
+	a := testApi(t, srv.ServeHTTP, &Options{Guest: true})
+	a.client.SetAPIToken("read")
+
+	if err := upload(t, a); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := a.Guest(); !ok || s.Token != "read" || s.FolderID != "gfld" {
+		t.Errorf("guest session is %+v, want the read token kept", s)
+	}
+}
+
+func TestGuestWithoutToken(t *testing.T) {
+	srv := &guestUploads{}
+	a := testApi(t, srv.ServeHTTP, &Options{Guest: true})
+
+	if err := upload(t, a); err == nil {
+		t.Error("upload succeeded although no guest token can be captured")
+	}
+	if _, ok := a.Guest(); ok {
+		t.Error("a guest session was kept without a token")
+	}
+}
+
+func TestResumeGuest(t *testing.T) {
+	srv := &guestUploads{guestToken: "new"}
+	a := testApi(t, srv.ServeHTTP, &Options{Guest: true})
+	a.ResumeGuest(GuestSession{Token: "old", FolderID: "ofld"})
+
+	if err := upload(t, a); err != nil {
+		t.Fatal(err)
+	}
+	if srv.tokens[0] != "old" || srv.folders[0] != "ofld" {
+		t.Errorf("upload sent token %q into folder %q, want the resumed session", srv.tokens[0], srv.folders[0])
+	}
+}
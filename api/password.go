@@ -0,0 +1,49 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// HashPassword returns the SHA-256 hex digest of password, which is how gofile expects content passwords
+func HashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetContentWithPassword returns the details of a password protected file or folder.
+//
+// password is the plain password set with model.SetPassword, it is hashed before being sent.
+// ErrPasswordRequired is returned if password is empty and ErrPasswordWrong if it does not match.
+//
+// Returns a structured response or an error.
+func (a *Api) GetContentWithPassword(contentID string, password string) (model.ContentResponse, error) {
+	if err := validateID("contentID", contentID); err != nil {
+		return model.ContentResponse{}, err
+	}
+	hash := ""
+	if password != "" {
+		hash = HashPassword(password)
+	}
+	return a.getContent(contentID, hash)
+}
+
+// getContent fetches content details and reports a locked content as an error
+func (a *Api) getContent(contentID string, passwordHash string) (model.ContentResponse, error) {
+	resp, err := do[model.Content](a, "getContent", func() (*http.Response, error) {
+		return a.client.GetContent(contentID, passwordHash)
+	})
+	if err != nil {
+		return resp, err
+	}
+	switch resp.Data.PasswordStatus {
+	case "passwordRequired":
+		return resp, ErrPasswordRequired
+	case "passwordWrong":
+		return resp, ErrPasswordWrong
+	}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	const want = "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+	if got := HashPassword("password"); got != want {
+		t.Errorf("HashPassword(\"password\") = %s, want %s", got, want)
+	}
+}
+
+func TestGetContentWithPassword(t *testing.T) {
+	secret := HashPassword("secret")
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]any{"id": "f", "type": "file", "name": "a.txt", "password": true}
+		switch r.URL.Query().Get("password") {
+		case "":
+			data["passwordStatus"] = "passwordRequired"
+		case secret:
+			data["link"] = "https://store1.gofile.io/download/f/a.txt"
+		default:
+			data["passwordStatus"] = "passwordWrong"
+		}
+		writeData(w, data)
+	}, nil)
+
+	tests := []struct {
+		password string
+		want     error
+	}{
+		{"", ErrPasswordRequired},
+		{"guess", ErrPasswordWrong},
+		{"secret", nil},
+	}
+	for _, tt := range tests {
+		resp, err := a.GetContentWithPassword("f", tt.password)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("password %q: error is %v, want %v", tt.password, err, tt.want)
+		}
+		if tt.want == nil && resp.Data.Link == "" {
+			t.Errorf("password %q: the unlocked content has no link", tt.password)
+		}
+	}
+
+	if _, err := a.GetContent("f"); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("GetContent of a protected file = %v, want ErrPasswordRequired", err)
+	}
+}
+
+func TestPasswordStatusErrors(t *testing.T) {
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusUnauthorized, "error-passwordRequired")
+	}, nil)
+
+	_, err := a.GetContent("f")
+	if !errors.Is(err, ErrPasswordRequired) || errors.Is(err, ErrPasswordWrong) {
+		t.Errorf("error is %v, want ErrPasswordRequired only", err)
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// ErrDeleteNotConfirmed is returned by SafeDelete when the confirmation callback declines the deletion
+var ErrDeleteNotConfirmed = errors.New("deletion not confirmed")
+
+// ErrNotDeleted is wrapped by the errors SafeDelete returns for contents gofile did not report as deleted
+var ErrNotDeleted = errors.New("not deleted")
+
+// defaultConfirmThreshold is the number of items above which SafeDelete asks for confirmation
+const defaultConfirmThreshold = 10
+
+// DeleteItem describes a content that is about to be deleted
+type DeleteItem struct {
+	ID           string            `json:"id"`               // ID of the content
+	Name         string            `json:"name"`             // name of the content
+	Type         model.ContentType `json:"type"`             // type of the content
+	Size         int64             `json:"size"`             // size of the file, or total size of the folder, in bytes
+	MD5          string            `json:"md5,omitempty"`    // MD5 hash of the file
+	ParentFolder string            `json:"parentFolder"`     // ID of the parent folder
+	DeletedAt    time.Time         `json:"deletedAt"`        // time the content was deleted, zero in a preview or if gofile did not delete it
+	Status       string            `json:"status,omitempty"` // status returned by gofile for this content
+
+	DeletedWith string       `json:"deletedWith,omitempty"` // ID of the deleted folder this content was in, empty for the contents passed to SafeDelete
+	Contents    []DeleteItem `json:"-"`                     // files and subfolders of a folder, deleted along with it
+}
+
+// DeletePlan lists the contents a deletion affects
+type DeletePlan struct {
+	Items []DeleteItem
+}
+
+// TotalSize returns the number of bytes the deletion frees
+func (p DeletePlan) TotalSize() int64 {
+	var total int64
+	for _, it := range p.Items {
+		total += it.Size
+	}
+	return total
+}
+
+// HasFolders reports whether the deletion includes at least one folder
+func (p DeletePlan) HasFolders() bool {
+	for _, it := range p.Items {
+		if it.Type == model.FolderType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteOptions configures SafeDelete.
+type DeleteOptions struct {
+	DryRun bool // DryRun only resolves and returns the plan, nothing is deleted
+
+	// Confirm is called before deleting when the plan includes a folder or more than
+	// ConfirmThreshold items. The deletion only happens if it returns true. If Confirm is nil
+	// such deletions are refused with ErrDeleteNotConfirmed.
+	Confirm          func(plan DeletePlan) bool
+	ConfirmThreshold int // ConfirmThreshold defaults to 10
+
+	ManifestPath string // ManifestPath is a file every deleted item, including the contents of deleted folders, is appended to as a JSON line, empty disables it
+}
+
+// PlanDelete resolves the name, size, MD5 and parent of each content without deleting anything.
+//
+// Returns the plan or an error if a content could not be resolved.
+func (a *Api) PlanDelete(contentID ...string) (DeletePlan, error) {
+	if err := validateIDs("contentID", contentID); err != nil {
+		return DeletePlan{}, err
+	}
+	var plan DeletePlan
+	for _, id := range contentID {
+		resp, err := a.GetContent(id)
+		if err != nil {
+			return DeletePlan{}, fmt.Errorf("resolve %s: %w", id, err)
+		}
+		d := resp.Data
+		it := deleteItem(d)
+		if d.Type == model.FolderType {
+			it.Size = d.TotalSize
+			if it.Contents, err = a.planFolder(d, d.ID); err != nil {
+				return DeletePlan{}, fmt.Errorf("resolve %s: %w", id, err)
+			}
+		}
+		plan.Items = append(plan.Items, it)
+	}
+	return plan, nil
+}
+
+// planFolder lists every file and subfolder of folder, recursively, as deleted with the folder deletedWith
+func (a *Api) planFolder(folder model.Content, deletedWith string) ([]DeleteItem, error) {
+	var items []DeleteItem
+	for _, child := range folder.Children {
+		it := deleteItem(child)
+		it.DeletedWith = deletedWith
+		items = append(items, it)
+		if child.Type != model.FolderType {
+			continue
+		}
+		resp, err := a.GetContent(child.ID)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := a.planFolder(resp.Data, deletedWith)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, sub...)
+	}
+	return items, nil
+}
+
+// deleteItem describes c in a deletion plan
+func deleteItem(c model.Content) DeleteItem {
+	return DeleteItem{
+		ID:           c.ID,
+		Name:         c.Name,
+		Type:         c.Type,
+		Size:         c.Size,
+		MD5:          c.MD5,
+		ParentFolder: c.ParentFolder,
+	}
+}
+
+// SafeDelete deletes contents after previewing them.
+//
+// The contents are resolved with PlanDelete first. Deletions of folders or of more than
+// opts.ConfirmThreshold items need opts.Confirm to approve them. Deleted items, and every file
+// and subfolder of deleted folders, can be recorded in a local manifest for auditing.
+// The manifest is opened before anything is deleted, so a deletion never goes unrecorded.
+//
+// Only items gofile reports with the status "ok" get a DeletedAt time and are written to the manifest.
+// Returns the plan, with the status of each item once deleted, or an error. Items gofile did not
+// delete are reported in an error wrapping ErrNotDeleted, returned along with the plan.
+func (a *Api) SafeDelete(opts DeleteOptions, contentID ...string) (DeletePlan, error) {
+	plan, err := a.PlanDelete(contentID...)
+	if err != nil {
+		return plan, err
+	}
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	threshold := opts.ConfirmThreshold
+	if threshold <= 0 {
+		threshold = defaultConfirmThreshold
+	}
+	if plan.HasFolders() || len(plan.Items) > threshold {
+		if opts.Confirm == nil || !opts.Confirm(plan) {
+			return plan, ErrDeleteNotConfirmed
+		}
+	}
+
+	var manifest *os.File
+	if opts.ManifestPath != "" {
+		manifest, err = os.OpenFile(opts.ManifestPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return plan, fmt.Errorf("open manifest failed, nothing was deleted: %w", err)
+		}
+		defer manifest.Close()
+	}
+
+	resp, err := a.DeleteContent(contentID...)
+	if err != nil {
+		return plan, err
+	}
+	now := time.Now()
+	var failed []error
+	for i := range plan.Items {
+		it := &plan.Items[i]
+		if st, ok := resp.Data[it.ID]; ok {
+			it.Status = st.Status
+		}
+		if it.Status == "ok" {
+			it.DeletedAt = now
+		} else {
+			failed = append(failed, fmt.Errorf("%s (%s) %w: status %q", it.Name, it.ID, ErrNotDeleted, it.Status))
+		}
+		for j := range it.Contents {
+			it.Contents[j].DeletedAt = it.DeletedAt
+			it.Contents[j].Status = it.Status
+		}
+	}
+
+	if manifest != nil {
+		if err := writeManifest(manifest, plan.Items); err != nil {
+			return plan, fmt.Errorf("contents were deleted but the manifest could not be written: %w", err)
+		}
+	}
+	return plan, errors.Join(failed...)
+}
+
+// writeManifest writes the deleted items, followed by the contents of each folder, to the manifest f
+// as JSON lines and syncs it, closing f is left to the caller
+func writeManifest(f *os.File, items []DeleteItem) error {
+	enc := json.NewEncoder(f)
+	for _, it := range items {
+		if it.DeletedAt.IsZero() {
+			continue
+		}
+		if err := enc.Encode(it); err != nil {
+			return err
+		}
+		for _, c := range it.Contents {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		}
+	}
+	return f.Sync()
+}
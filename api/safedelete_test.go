@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// deleteServer is a fake gofile holding a folder "fld" with a file and a subfolder, and loose files,
+// it records the IDs sent to the delete endpoint
+type deleteServer struct {
+	mu      sync.Mutex
+	deleted []string
+	refused map[string]string // refused maps IDs to the status answered instead of "ok"
+}
+
+func (s *deleteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method == http.MethodDelete {
+		var body struct {
+			ContentsID string `json:"contentsId"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		data := map[string]any{}
+		for _, id := range strings.Split(body.ContentsID, ",") {
+			if status, ok := s.refused[id]; ok {
+				data[id] = map[string]any{"status": status}
+				continue
+			}
+			s.deleted = append(s.deleted, id)
+			data[id] = map[string]any{"status": "ok"}
+		}
+		writeData(w, data)
+		return
+	}
+	switch id := strings.TrimPrefix(r.URL.Path, "/contents/"); id {
+	case "fld":
+		writeData(w, map[string]any{"id": "fld", "type": "folder", "name": "fld", "parentFolder": "root", "totalSize": 30, "children": map[string]any{
+			"in":  map[string]any{"id": "in", "type": "file", "name": "in.txt", "size": 10, "md5": "aa", "parentFolder": "fld"},
+			"sub": map[string]any{"id": "sub", "type": "folder", "name": "sub", "parentFolder": "fld"},
+		}})
+	case "sub":
+		writeData(w, map[string]any{"id": "sub", "type": "folder", "name": "sub", "parentFolder": "fld", "children": map[string]any{
+			"deep": map[string]any{"id": "deep", "type": "file", "name": "deep.txt", "size": 20, "md5": "bb", "parentFolder": "sub"},
+		}})
+	case "f1", "f2", "f3":
+		writeData(w, map[string]any{"id": id, "type": "file", "name": id + ".txt", "size": 5, "md5": "md5-" + id, "parentFolder": "root"})
+	default:
+		writeStatus(w, http.StatusNotFound, "error-notFound")
+	}
+}
+
+func TestPlanDelete(t *testing.T) {
+	srv := &deleteServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	plan, err := a.PlanDelete("fld", "f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Items) != 2 || plan.TotalSize() != 35 || !plan.HasFolders() {
+		t.Errorf("plan is %+v, want fld and f1 freeing 35 bytes", plan)
+	}
+	if n := len(plan.Items[0].Contents); n != 3 {
+		t.Errorf("fld lists %d contents, want in.txt, sub and deep.txt", n)
+	}
+	if len(srv.deleted) != 0 {
+		t.Errorf("PlanDelete deleted %v", srv.deleted)
+	}
+	if _, err := a.PlanDelete("f1", "gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("plan with a missing content = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSafeDeleteDryRun(t *testing.T) {
+	srv := &deleteServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	plan, err := a.SafeDelete(DeleteOptions{DryRun: true}, "fld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Items) != 1 || !plan.Items[0].DeletedAt.IsZero() || len(srv.deleted) != 0 {
+		t.Errorf("dry run returned %+v and deleted %v", plan, srv.deleted)
+	}
+}
+
+func TestSafeDeleteConfirmThreshold(t *testing.T) {
+	srv := &deleteServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	// at the threshold no confirmation is needed
+	if _, err := a.SafeDelete(DeleteOptions{ConfirmThreshold: 2}, "f1", "f2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.deleted) != 2 {
+		t.Fatalf("deleted %v, want f1 and f2", srv.deleted)
+	}
+
+	srv.deleted = nil
+	_, err := a.SafeDelete(DeleteOptions{ConfirmThreshold: 2}, "f1", "f2", "f3")
+	if !errors.Is(err, ErrDeleteNotConfirmed) || len(srv.deleted) != 0 {
+		t.Errorf("deletion over the threshold without Confirm = %v and deleted %v, want ErrDeleteNotConfirmed", err, srv.deleted)
+	}
+
+	var asked int
+	decline := func(p DeletePlan) bool { asked++; return false }
+	_, err = a.SafeDelete(DeleteOptions{ConfirmThreshold: 2, Confirm: decline}, "f1", "f2", "f3")
+	if !errors.Is(err, ErrDeleteNotConfirmed) || asked != 1 || len(srv.deleted) != 0 {
+		t.Errorf("declined deletion = %v after %d prompts and deleted %v", err, asked, srv.deleted)
+	}
+
+	accept := func(p DeletePlan) bool { return len(p.Items) == 3 }
+	if _, err := a.SafeDelete(DeleteOptions{ConfirmThreshold: 2, Confirm: accept}, "f1", "f2", "f3"); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.deleted) != 3 {
+		t.Errorf("deleted %v after confirmation, want f1, f2 and f3", srv.deleted)
+	}
+}
+
+func TestSafeDeleteFolderNeedsConfirm(t *testing.T) {
+	srv := &deleteServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	if _, err := a.SafeDelete(DeleteOptions{}, "fld"); !errors.Is(err, ErrDeleteNotConfirmed) {
+		t.Errorf("folder deletion without Confirm = %v, want ErrDeleteNotConfirmed", err)
+	}
+	if len(srv.deleted) != 0 {
+		t.Errorf("deleted %v without confirmation", srv.deleted)
+	}
+}
+
+// readManifest returns the items recorded in the manifest at path
+func readManifest(t *testing.T, path string) []DeleteItem {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []DeleteItem
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var it DeleteItem
+		if err := json.Unmarshal(sc.Bytes(), &it); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, it)
+	}
+	return lines
+}
+
+func TestSafeDeleteManifest(t *testing.T) {
+	srv := &deleteServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+	manifest := filepath.Join(t.TempDir(), "deleted.jsonl")
+
+	opts := DeleteOptions{ManifestPath: manifest, Confirm: func(DeletePlan) bool { return true }}
+	if _, err := a.SafeDelete(opts, "fld"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SafeDelete(opts, "f1"); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readManifest(t, manifest)
+	if len(lines) != 5 {
+		t.Fatalf("manifest holds %d lines, want fld, its 3 contents and f1", len(lines))
+	}
+	for _, it := range lines {
+		if it.DeletedAt.IsZero() || it.Status != "ok" {
+			t.Errorf("manifest line %+v has no deletion time or status", it)
+		}
+		want := "fld"
+		if it.ID == "fld" || it.ID == "f1" {
+			want = ""
+		}
+		if it.DeletedWith != want {
+			t.Errorf("%s deleted with %q, want %q", it.ID, it.DeletedWith, want)
+		}
+	}
+	i := slices.IndexFunc(lines, func(it DeleteItem) bool { return it.ID == "deep" })
+	if i < 0 || lines[i].MD5 != "bb" || lines[i].ParentFolder != "sub" || lines[i].Name != "deep.txt" {
+		t.Errorf("deep.txt is not recorded with its name, MD5 and parent: %+v", lines)
+	}
+}
+
+func TestSafeDeleteManifestUnwritable(t *testing.T) {
+	srv := &deleteServer{}
+	a := testApi(t, srv.ServeHTTP, nil)
+
+	opts := DeleteOptions{ManifestPath: filepath.Join(t.TempDir(), "missing", "deleted.jsonl")}
+	if _, err := a.SafeDelete(opts, "f1"); err == nil {
+		t.Error("deletion with an unwritable manifest succeeded")
+	}
+	if len(srv.deleted) != 0 {
+		t.Errorf("deleted %v although the manifest could not be opened", srv.deleted)
+	}
+}
+
+func TestSafeDeleteReportsRefusedItems(t *testing.T) {
+	srv := &deleteServer{refused: map[string]string{"f2": "error-notPremium", "fld": "error-notFound"}}
+	a := testApi(t, srv.ServeHTTP, nil)
+	manifest := filepath.Join(t.TempDir(), "deleted.jsonl")
+
+	opts := DeleteOptions{ManifestPath: manifest, Confirm: func(DeletePlan) bool { return true }}
+	plan, err := a.SafeDelete(opts, "f1", "f2", "fld")
+	if !errors.Is(err, ErrNotDeleted) {
+		t.Fatalf("error %v, want ErrNotDeleted", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "(f2)") || !strings.Contains(msg, "(fld)") || strings.Contains(msg, "(f1)") {
+		t.Errorf("error %q, want f2 and fld reported and not f1", msg)
+	}
+	for _, it := range plan.Items {
+		if deleted := !it.DeletedAt.IsZero(); deleted != (it.ID == "f1") {
+			t.Errorf("%s has status %q and deletion time %v", it.ID, it.Status, it.DeletedAt)
+		}
+		for _, c := range it.Contents {
+			if !c.DeletedAt.IsZero() {
+				t.Errorf("%s of the refused folder %s has a deletion time", c.ID, it.ID)
+			}
+		}
+	}
+	if lines := readManifest(t, manifest); len(lines) != 1 || lines[0].ID != "f1" {
+		t.Errorf("manifest holds %+v, want f1 only", lines)
+	}
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// shareHost is the host of gofile download pages
+const shareHost = "gofile.io"
+
+// ParseShareLink extracts the folder code from a gofile download page link.
+//
+// Accepts links such as "https://gofile.io/d/AbC123", the same link without scheme,
+// or a bare code, which is returned as is.
+//
+// Returns the code or a *ValidationError.
+func ParseShareLink(link string) (string, error) {
+	link = strings.TrimSpace(link)
+	if !strings.Contains(link, "/") {
+		if err := validateID("code", link); err != nil {
+			return "", err
+		}
+		return link, nil
+	}
+
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", &ValidationError{Field: "link", Value: link, Reason: err.Error()}
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if host != shareHost {
+		return "", &ValidationError{Field: "link", Value: link, Reason: "not a " + shareHost + " link"}
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "d" {
+		return "", &ValidationError{Field: "link", Value: link, Reason: "expected a download page link like https://gofile.io/d/<code>"}
+	}
+	if err := validateID("code", parts[1]); err != nil {
+		return "", err
+	}
+	return parts[1], nil
+}
+
+// CreateGuestAccount creates a guest account.
+//
+// If the Api has no token yet, the guest token is used for the following requests.
+//
+// Returns a structured response or an error.
+func (a *Api) CreateGuestAccount() (model.CreateAccountResponse, error) {
+	resp, err := do[model.CreateAccountData](a, "createAccount", func() (*http.Response, error) {
+		return a.client.CreateAccount()
+	})
+	if err != nil {
+		return resp, err
+	}
+	if a.client.APIToken() == "" {
+		a.client.SetAPIToken(resp.Data.Token)
+	}
+	return resp, nil
+}
+
+// ensureToken creates a guest account if the Api has no token, gofile requires one to read any content
+func (a *Api) ensureToken() error {
+	if a.client.APIToken() != "" {
+		return nil
+	}
+	_, err := a.CreateGuestAccount()
+	return err
+}
+
+// OpenShareLink returns the folder behind a download page link (see ParseShareLink).
+//
+// The folder does not need to belong to the account, it only needs to be public.
+// A guest account is created if the Api has no token.
+// password is only needed for password protected folders, leave it empty otherwise.
+//
+// Returns a structured response or an error.
+func (a *Api) OpenShareLink(link string, password string) (model.ContentResponse, error) {
+	code, err := ParseShareLink(link)
+	if err != nil {
+		return model.ContentResponse{}, err
+	}
+	if err := a.ensureToken(); err != nil {
+		return model.ContentResponse{}, err
+	}
+	return a.GetContentWithPassword(code, password)
+}
+
+// DownloadFolder downloads every file of a folder and its subfolders into dir.
+//
+// folder can be a folder ID, a folder code or a download page link, so public folders
+// of other accounts can be downloaded. Subfolders are recreated as directories.
+// password is only needed for password protected folders, leave it empty otherwise.
+//
+// Returns the paths of the downloaded files or an error.
+func (a *Api) DownloadFolder(folder string, password string, dir string) ([]string, error) {
+	id, err := ParseShareLink(folder)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.ensureToken(); err != nil {
+		return nil, err
+	}
+	return a.downloadFolder(id, password, dir)
+}
+
+// downloadFolder downloads the folder with the specified folderID into dir
+func (a *Api) downloadFolder(folderID string, password string, dir string) ([]string, error) {
+	resp, err := a.GetContentWithPassword(folderID, password)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.Type != model.FolderType {
+		return nil, fmt.Errorf("content %s is a %s, not a folder", folderID, resp.Data.Type)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// children are visited by name so the suffixes given to duplicate names are stable
+	children := slices.SortedFunc(maps.Values(resp.Data.Children), func(x, y model.Content) int {
+		return cmp.Or(strings.Compare(x.Name, y.Name), strings.Compare(x.ID, y.ID))
+	})
+	var paths []string
+	used := make(map[string]bool)
+	for _, child := range children {
+		name, err := localName(child.Name)
+		if err != nil {
+			return paths, err
+		}
+		name = uniqueName(name, used)
+		target := filepath.Join(dir, name)
+		if child.Type == model.FolderType {
+			sub, err := a.downloadFolder(child.ID, password, target)
+			paths = append(paths, sub...)
+			if err != nil {
+				return paths, err
+			}
+			continue
+		}
+		if err := a.downloadLink(child.Link, target); err != nil {
+			return paths, fmt.Errorf("download %s failed: %w", child.Name, err)
+		}
+		paths = append(paths, target)
+	}
+	return paths, nil
+}
+
+// localName checks that a remote name can safely be used as a local file name
+func localName(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", &ValidationError{Field: "name", Value: name, Reason: "not a safe local file name"}
+	}
+	return name, nil
+}
+
+// uniqueName returns name, or name with a " (n)" suffix before its extension if it is already in used,
+// and adds the result to used so contents sharing a name do not overwrite each other
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	ext := filepath.Ext(name)
+	for n := 2; used[strings.ToLower(unique)]; n++ {
+		unique = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// downloadLink writes the file behind link to path
+//
+// The file is written to a temporary file next to path and renamed once complete,
+// so a failed download leaves nothing behind.
+func (a *Api) downloadLink(link string, path string) error {
+	if link == "" {
+		return fmt.Errorf("no download link")
+	}
+	r, err := a.Download(link)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	// CreateTemp only grants access to the owner, use the permissions os.Create would
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if _, err := f.ReadFrom(r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
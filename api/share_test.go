@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/plutack/go-gofile/faultinject"
+)
+
+func TestParseShareLink(t *testing.T) {
+	tests := []struct {
+		link string
+		code string // code is empty if the link is rejected
+	}{
+		{"https://gofile.io/d/AbC123", "AbC123"},
+		{"http://www.gofile.io/d/AbC123/", "AbC123"},
+		{"gofile.io/d/AbC123", "AbC123"},
+		{"  AbC123 ", "AbC123"},
+		{"https://GoFile.io/d/x-y_z", "x-y_z"},
+		{"https://example.com/d/AbC123", ""},
+		{"https://gofile.io/f/AbC123", ""},
+		{"https://gofile.io/d/", ""},
+		{"https://gofile.io/d/AbC123/extra", ""},
+		{"https://gofile.io/d/Ab%20C", ""},
+		{"", ""},
+		{"Ab.C", ""},
+	}
+	for _, tt := range tests {
+		code, err := ParseShareLink(tt.link)
+		if tt.code == "" {
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Errorf("ParseShareLink(%q) = %q, %v, want a *ValidationError", tt.link, code, err)
+			}
+			continue
+		}
+		if err != nil || code != tt.code {
+			t.Errorf("ParseShareLink(%q) = %q, %v, want %q", tt.link, code, err, tt.code)
+		}
+	}
+}
+
+func TestLocalName(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`, "../etc"} {
+		if _, err := localName(name); err == nil {
+			t.Errorf("localName(%q) accepted an unsafe name", name)
+		}
+	}
+	for _, name := range []string{"a.txt", "..hidden", "résumé (1).pdf"} {
+		if got, err := localName(name); err != nil || got != name {
+			t.Errorf("localName(%q) = %q, %v, want it unchanged", name, got, err)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	used := make(map[string]bool)
+	var got []string
+	for _, name := range []string{"a.txt", "A.TXT", "a.txt", "a (2).txt", "b", "b"} {
+		got = append(got, uniqueName(name, used))
+	}
+	want := []string{"a.txt", "A (2).TXT", "a (3).txt", "a (2) (2).txt", "b", "b (2)"}
+	if !slices.Equal(got, want) {
+		t.Errorf("unique names are %q, want %q", got, want)
+	}
+}
+
+func TestDownloadFolderRemovesPartialFile(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/contents/fld":
+			writeData(w, map[string]any{"id": "fld", "type": "folder", "name": "fld", "children": map[string]any{
+				"f": map[string]any{"id": "f", "type": "file", "name": "a.txt", "link": "https://store1.gofile.io/download/f/a.txt"},
+			}})
+		default:
+			w.Write([]byte("hello world"))
+		}
+	}
+	// the download is reset half way through its body
+	ft := faultinject.New(&faultinject.Options{
+		Transport: handlerTransport{h: http.HandlerFunc(h)},
+		Rules:     []faultinject.Rule{{Fault: faultinject.ResetBody(5), Probability: 1, PathPrefix: "/download/"}},
+	})
+	a := testApi(t, nil, &Options{Transport: ft})
+
+	dir := t.TempDir()
+	if _, err := a.DownloadFolder("fld", "", dir); !errors.Is(err, faultinject.ErrConnectionReset) {
+		t.Fatalf("error is %v, want the connection reset", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%s holds %s after a failed download, want nothing", dir, entries[0].Name())
+	}
+
+	// without the fault the file lands under its own name
+	a = testApi(t, h, nil)
+	paths, err := a.DownloadFolder("fld", "", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "a.txt")); err != nil || string(data) != "hello world" || len(paths) != 1 {
+		t.Errorf("downloaded %q to %v, %v", data, paths, err)
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// ErrInvalidTag is returned when a tag contains characters gofile cannot store
+var ErrInvalidTag = errors.New("invalid tag")
+
+// maxTagLength is the maximum number of characters allowed in a tag
+const maxTagLength = 64
+
+// ValidateTag checks that tag is non empty and only made of letters, digits, '-', '_' and '.'
+//
+// Commas are rejected since gofile stores tags as a single comma separated string.
+func ValidateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+	}
+	if len(tag) > maxTagLength {
+		return fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTag, tag, maxTagLength)
+	}
+	for _, r := range tag {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return fmt.Errorf("%w: %q contains %q", ErrInvalidTag, tag, r)
+		}
+	}
+	return nil
+}
+
+// validateTags validates every tag in tags
+func validateTags(tags []string) error {
+	for _, t := range tags {
+		if err := ValidateTag(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTags adds tags to the content with the specified contentID while keeping its existing tags.
+//
+// The current tags are read from the content metadata right before writing, tags already set are not duplicated.
+// The read and the write are separate requests, a tag change made by someone else in between is lost.
+//
+// Returns the tags of the content after the change or an error.
+func (a *Api) AddTags(contentID string, tags ...string) ([]string, error) {
+	if err := validateTags(tags); err != nil {
+		return nil, err
+	}
+	return a.changeTags(contentID, tags, func() {}, addTags)
+}
+
+// RemoveTags removes tags from the content with the specified contentID while keeping its other tags.
+//
+// Like AddTags it reads then writes the tags, a tag change made by someone else in between is lost.
+//
+// Returns the tags of the content after the change or an error.
+func (a *Api) RemoveTags(contentID string, tags ...string) ([]string, error) {
+	if err := validateTags(tags); err != nil {
+		return nil, err
+	}
+	return a.changeTags(contentID, tags, func() {}, removeTags)
+}
+
+// addTags returns current with the tags it does not hold yet appended
+func addTags(current []string, tags []string) []string {
+	merged := slices.Clone(current)
+	for _, t := range tags {
+		if !slices.Contains(merged, t) {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// removeTags returns current without tags
+func removeTags(current []string, tags []string) []string {
+	var kept []string
+	for _, t := range current {
+		if !slices.Contains(tags, t) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// changeTags reads the tags of the content, changes them with change and writes them back unless
+// they are unchanged, calling wait before each request.
+func (a *Api) changeTags(contentID string, tags []string, wait func(), change func(current []string, tags []string) []string) ([]string, error) {
+	wait()
+	resp, err := a.GetContent(contentID)
+	if err != nil {
+		return nil, err
+	}
+	current := resp.Data.TagList()
+	after := change(current, tags)
+	if slices.Equal(current, after) {
+		return after, nil
+	}
+	wait()
+	if _, err := a.Update(contentID, model.SetTags(after)); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// ListByTag returns the items in the folder with the specified folderID that have tag.
+//
+// If recursive is true, items of subfolders are searched too.
+//
+// Returns the matching items sorted by name then ID, or an error.
+func (a *Api) ListByTag(folderID string, tag string, recursive bool) ([]model.Content, error) {
+	if err := ValidateTag(tag); err != nil {
+		return nil, err
+	}
+	found, err := a.listByTag(folderID, tag, recursive)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(found, func(x, y model.Content) int {
+		return cmp.Or(strings.Compare(x.Name, y.Name), strings.Compare(x.ID, y.ID))
+	})
+	return found, nil
+}
+
+// listByTag returns the items in the folder that have tag, in no particular order
+func (a *Api) listByTag(folderID string, tag string, recursive bool) ([]model.Content, error) {
+	resp, err := a.GetContent(folderID)
+	if err != nil {
+		return nil, err
+	}
+	var found []model.Content
+	for _, child := range resp.Data.Children {
+		if slices.Contains(child.TagList(), tag) {
+			found = append(found, child)
+		}
+		if recursive && child.Type == model.FolderType {
+			sub, err := a.listByTag(child.ID, tag, recursive)
+			if err != nil {
+				return nil, err
+			}
+			found = append(found, sub...)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateTag(t *testing.T) {
+	tests := []struct {
+		tag   string
+		valid bool
+	}{
+		{"invoice", true},
+		{"v1.2_beta-3", true},
+		{"", false},
+		{"a,b", false},
+		{"two words", false},
+		{"émoji", false},
+		{strings.Repeat("x", maxTagLength), true},
+		{strings.Repeat("x", maxTagLength+1), false},
+	}
+	for _, tt := range tests {
+		err := ValidateTag(tt.tag)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateTag(%q) = %v, want nil", tt.tag, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("ValidateTag(%q) = %v, want ErrInvalidTag", tt.tag, err)
+		}
+	}
+}
+
+func TestAddTagsKeepsExisting(t *testing.T) {
+	store := &tagStore{t: t, tags: map[string]string{"f": "a, b"}}
+	a := testApi(t, store.ServeHTTP, nil)
+
+	tags, err := a.AddTags("f", "b", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tags, []string{"a", "b", "c"}) || store.tags["f"] != "a,b,c" {
+		t.Errorf("tags are %v stored as %q, want [a b c]", tags, store.tags["f"])
+	}
+
+	if _, err := a.AddTags("f", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if store.writes != 1 {
+		t.Errorf("%d tag writes, want 1 since adding a set tag changes nothing", store.writes)
+	}
+}
+
+func TestRemoveTags(t *testing.T) {
+	store := &tagStore{t: t, tags: map[string]string{"f": "a,b,c"}}
+	a := testApi(t, store.ServeHTTP, nil)
+
+	tags, err := a.RemoveTags("f", "b", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tags, []string{"a", "c"}) || store.tags["f"] != "a,c" {
+		t.Errorf("tags are %v stored as %q, want [a c]", tags, store.tags["f"])
+	}
+
+	if _, err := a.RemoveTags("f", "bad tag"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("RemoveTags with an invalid tag = %v, want ErrInvalidTag", err)
+	}
+	if store.writes != 1 {
+		t.Errorf("%d tag writes, want 1", store.writes)
+	}
+}
+
+func TestListByTagSorted(t *testing.T) {
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/contents/root":
+			writeData(w, map[string]any{"id": "root", "type": "folder", "name": "root", "children": map[string]any{
+				"f3":  map[string]any{"id": "f3", "type": "file", "name": "c.txt", "tags": "x"},
+				"f1":  map[string]any{"id": "f1", "type": "file", "name": "a.txt", "tags": "y,x"},
+				"f2":  map[string]any{"id": "f2", "type": "file", "name": "b.txt", "tags": "y"},
+				"sub": map[string]any{"id": "sub", "type": "folder", "name": "sub"},
+			}})
+		case "/contents/sub":
+			writeData(w, map[string]any{"id": "sub", "type": "folder", "name": "sub", "children": map[string]any{
+				"f5": map[string]any{"id": "f5", "type": "file", "name": "b.txt", "tags": "x"},
+				"f4": map[string]any{"id": "f4", "type": "file", "name": "b.txt", "tags": "x"},
+			}})
+		default:
+			writeStatus(w, http.StatusNotFound, "error-notFound")
+		}
+	}, nil)
+
+	found, err := a.ListByTag("root", "x", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range found {
+		ids = append(ids, c.ID)
+	}
+	if want := []string{"f1", "f4", "f5", "f3"}; !slices.Equal(ids, want) {
+		t.Errorf("found %v, want %v", ids, want)
+	}
+}
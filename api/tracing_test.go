@@ -0,0 +1,188 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/plutack/go-gofile/tracing"
+)
+
+// recordedSpan is a span kept by recordingTracer
+type recordedSpan struct {
+	name string
+
+	mu     sync.Mutex
+	attrs  map[string]any
+	events []string
+	ended  bool
+	err    error
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...tracing.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) Event(name string, attrs ...tracing.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, name)
+}
+
+func (s *recordedSpan) End(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended, s.err = true, err
+}
+
+// recordingTracer keeps every span started
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(name string, attrs ...tracing.Attribute) tracing.Span {
+	s := &recordedSpan{name: name, attrs: make(map[string]any)}
+	s.SetAttributes(attrs...)
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return s
+}
+
+// named returns the spans called name in the order they were started
+func (t *recordingTracer) named(name string) []*recordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var spans []*recordedSpan
+	for _, s := range t.spans {
+		if s.name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// serverTransport sends every request to a test server over a real connection, so httptrace hooks run
+type serverTransport struct {
+	srv *httptest.Server
+}
+
+func (t serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(t.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+	return t.srv.Client().Transport.RoundTrip(req)
+}
+
+// tracedApi returns an Api sending its requests to a server answering with h and tracing them
+func tracedApi(t *testing.T, h http.HandlerFunc) (*Api, *recordingTracer) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	tracer := &recordingTracer{}
+	return testApi(t, nil, &Options{Transport: serverTransport{srv: srv}, Tracer: tracer}), tracer
+}
+
+// hasEvents reports whether s recorded the events in want, in order
+func (s *recordedSpan) hasEvents(want ...string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := 0
+	for _, e := range s.events {
+		if i < len(want) && e == want[i] {
+			i++
+		}
+	}
+	return i == len(want)
+}
+
+func TestTraceAPICall(t *testing.T) {
+	a, tracer := tracedApi(t, func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusNotFound, "error-notFound")
+	})
+
+	_, callErr := a.DeleteContent("a")
+
+	apiSpans := tracer.named("gofile.api")
+	if len(apiSpans) != 1 {
+		t.Fatalf("%d gofile.api spans, want 1", len(apiSpans))
+	}
+	s := apiSpans[0]
+	if !s.ended || !errors.Is(s.err, ErrNotFound) || s.err != callErr {
+		t.Errorf("span ended %v with %v, want the error returned by the call", s.ended, s.err)
+	}
+	for k, want := range map[string]any{"endpoint": "deleteContent", "status": "error-notFound", "http.status_code": int64(http.StatusNotFound)} {
+		if s.attrs[k] != want {
+			t.Errorf("attribute %s = %v, want %v", k, s.attrs[k], want)
+		}
+	}
+	if n, ok := s.attrs["bytes"].(int64); !ok || n == 0 {
+		t.Errorf("attribute bytes = %v, want the response size", s.attrs["bytes"])
+	}
+}
+
+func TestTraceHTTPEvents(t *testing.T) {
+	a, tracer := tracedApi(t, func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"id": "acc"})
+	})
+
+	for range 2 {
+		if _, err := a.GetAccountID(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spans := tracer.named("gofile.http")
+	if len(spans) != 2 {
+		t.Fatalf("%d gofile.http spans, want 2", len(spans))
+	}
+	first, second := spans[0], spans[1]
+	if !first.hasEvents("connect.start", "connect.done", "conn.acquired", "request.headers_written", "request.written", "response.first_byte") {
+		t.Errorf("first request events %v, want the connection and request events", first.events)
+	}
+	if slices.Contains(second.events, "connect.start") || !second.hasEvents("conn.acquired", "request.written", "response.first_byte") {
+		t.Errorf("second request events %v, want a reused connection", second.events)
+	}
+	for _, s := range spans {
+		if !s.ended || s.err != nil || s.attrs["http.status_code"] != int64(http.StatusOK) || s.attrs["attempt"] != int64(0) {
+			t.Errorf("span ended %v with %v and attributes %v, want a successful first attempt", s.ended, s.err, s.attrs)
+		}
+	}
+}
+
+func TestTraceUploadBody(t *testing.T) {
+	a, tracer := tracedApi(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		writeData(w, map[string]any{"id": "new", "name": "a.txt"})
+	})
+
+	if _, err := a.UploadReader("store1", "a.txt", strings.NewReader("hello world"), 11, "fld", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tracer.named("gofile.http")
+	if len(spans) != 1 {
+		t.Fatalf("%d gofile.http spans, want 1", len(spans))
+	}
+	s := spans[0]
+	if !s.hasEvents("body.read_start", "body.read_done") {
+		t.Errorf("events %v, want the file being read", s.events)
+	}
+	if s.attrs["server"] != "store1" || s.attrs["size"] != int64(11) || s.attrs["bytes"] != int64(11) {
+		t.Errorf("attributes %v, want the server, size and bytes sent", s.attrs)
+	}
+}
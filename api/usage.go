@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrQuotaExceeded is returned when an operation would go over the limits of the account tier
+	ErrQuotaExceeded = errors.New("account quota exceeded")
+	// ErrNoTierLimits is returned by quota checks when Options.TierLimits has no entry for the account tier
+	ErrNoTierLimits = errors.New("no limits configured for the account tier")
+)
+
+// TierLimits describes the quotas of an account tier, a zero value means unlimited
+type TierLimits struct {
+	Storage     int64 // Storage is the maximum number of bytes stored in the account
+	Traffic30   int64 // Traffic30 is the maximum number of bytes downloaded over 30 days
+	MaxFileSize int64 // MaxFileSize is the maximum size of a single uploaded file in bytes
+}
+
+// Usage reports what an account currently uses and what its tier allows
+type Usage struct {
+	Tier        string     // tier of the account (eg: "standard")
+	FolderCount int        // number of folders in the account
+	FileCount   int        // number of files in the account
+	StorageUsed int64      // bytes stored in the account
+	Traffic30   int64      // bytes downloaded from the account over the last 30 days
+	Limits      TierLimits // limits of the tier, zero unless LimitsSet
+	LimitsSet   bool       // LimitsSet reports whether Options.TierLimits has an entry for the tier
+}
+
+// RemainingStorage returns how many more bytes can be stored.
+//
+// Returns the remaining bytes and false if storage is unlimited.
+func (u Usage) RemainingStorage() (int64, bool) {
+	if u.Limits.Storage == 0 {
+		return 0, false
+	}
+	return max(u.Limits.Storage-u.StorageUsed, 0), true
+}
+
+// RemainingTraffic returns how many more bytes can be downloaded over the current 30 day window.
+//
+// Returns the remaining bytes and false if traffic is unlimited.
+func (u Usage) RemainingTraffic() (int64, bool) {
+	if u.Limits.Traffic30 == 0 {
+		return 0, false
+	}
+	return max(u.Limits.Traffic30-u.Traffic30, 0), true
+}
+
+// CheckUpload reports whether a file of size bytes can be uploaded on top of the current usage.
+//
+// The file must fit the maximum file size and the storage left. gofile restricts accounts
+// over their 30 day traffic limit, so an exhausted traffic quota fails the check as well.
+//
+// Returns an error wrapping ErrQuotaExceeded if it cannot.
+func (u Usage) CheckUpload(size int64) error {
+	if u.Limits.MaxFileSize > 0 && size > u.Limits.MaxFileSize {
+		return fmt.Errorf("%w: file of %d bytes is larger than the %d bytes allowed for tier %s",
+			ErrQuotaExceeded, size, u.Limits.MaxFileSize, u.Tier)
+	}
+	if remaining, limited := u.RemainingStorage(); limited && size > remaining {
+		return fmt.Errorf("%w: file of %d bytes does not fit in the %d bytes of storage left for tier %s",
+			ErrQuotaExceeded, size, remaining, u.Tier)
+	}
+	if remaining, limited := u.RemainingTraffic(); limited && remaining == 0 {
+		return fmt.Errorf("%w: the %d bytes of traffic allowed over 30 days for tier %s are used up",
+			ErrQuotaExceeded, u.Limits.Traffic30, u.Tier)
+	}
+	return nil
+}
+
+// Usage reports the storage and traffic used by the account and the limits of its tier.
+//
+// gofile does not report tier limits through its API, they are taken from Options.TierLimits.
+// Returns the usage or an error.
+func (a *Api) Usage() (Usage, error) {
+	// stats change with every upload so they are always fetched, only the account ID is cached
+	d, err := a.accountInformation()
+	if err != nil {
+		return Usage{}, err
+	}
+	limits, ok := a.tierLimits[d.Tier]
+	return Usage{
+		Tier:        d.Tier,
+		FolderCount: d.StatsCurrent.FolderCount,
+		FileCount:   d.StatsCurrent.FileCount,
+		StorageUsed: d.StatsCurrent.Storage,
+		Traffic30:   d.IPTraffic30,
+		Limits:      limits,
+		LimitsSet:   ok,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// accountServer is a fake gofile answering the account endpoints for any token,
+// the account ID is the token prefixed with "acc-"
+type accountServer struct {
+	storage int64
+	traffic int64
+
+	mu       sync.Mutex
+	requests map[string]int // requests counts the requests per endpoint ("getid" or "info")
+}
+
+func (s *accountServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.requests == nil {
+		s.requests = make(map[string]int)
+	}
+	switch {
+	case r.URL.Path == "/accounts/getid":
+		s.requests["getid"]++
+		writeData(w, map[string]any{"id": "acc-" + token})
+	case r.URL.Path == "/accounts/acc-"+token:
+		s.requests["info"]++
+		writeData(w, map[string]any{
+			"id": "acc-" + token, "tier": "standard", "rootFolder": "root-" + token,
+			"ipTraffic30":  s.traffic,
+			"statsCurrent": map[string]any{"folderCount": 2, "fileCount": 5, "storage": s.storage},
+		})
+	default:
+		writeStatus(w, http.StatusUnauthorized, "error-auth")
+	}
+}
+
+// count returns the number of requests sent to endpoint
+func (s *accountServer) count(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+func TestUsage(t *testing.T) {
+	srv := &accountServer{storage: 600, traffic: 50}
+	limits := map[string]TierLimits{"standard": {Storage: 1000, Traffic30: 100, MaxFileSize: 500}}
+	a := testApi(t, srv.ServeHTTP, &Options{TierLimits: limits})
+
+	u, err := a.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Tier != "standard" || u.StorageUsed != 600 || u.Traffic30 != 50 || u.FileCount != 5 || !u.LimitsSet {
+		t.Errorf("usage is %+v", u)
+	}
+	if left, limited := u.RemainingStorage(); !limited || left != 400 {
+		t.Errorf("remaining storage is %d, %v, want 400", left, limited)
+	}
+	if left, limited := u.RemainingTraffic(); !limited || left != 50 {
+		t.Errorf("remaining traffic is %d, %v, want 50", left, limited)
+	}
+	if srv.count("getid") != 1 || srv.count("info") != 1 {
+		t.Errorf("sent %v, want a single account lookup", srv.requests)
+	}
+
+	// the account ID is cached, the stats are fetched again once
+	if _, err := a.Account(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Usage(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.count("getid") != 1 || srv.count("info") != 2 {
+		t.Errorf("sent %v, want the account ID resolved once and the information fetched twice", srv.requests)
+	}
+}
+
+func TestUsageWithoutLimits(t *testing.T) {
+	a := testApi(t, (&accountServer{storage: 600}).ServeHTTP, nil)
+	u, err := a.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.LimitsSet {
+		t.Error("limits are set although none were configured")
+	}
+	if _, limited := u.RemainingStorage(); limited {
+		t.Error("storage is limited although no limit was configured")
+	}
+	if err := u.CheckUpload(1 << 40); err != nil {
+		t.Errorf("CheckUpload without limits = %v, want nil", err)
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	limits := TierLimits{Storage: 1000, Traffic30: 100, MaxFileSize: 500}
+	tests := []struct {
+		name  string
+		usage Usage
+		size  int64
+		ok    bool
+	}{
+		{"fits", Usage{StorageUsed: 600, Traffic30: 50}, 400, true},
+		{"file too large", Usage{}, 501, false},
+		{"storage full", Usage{StorageUsed: 600}, 401, false},
+		{"traffic used up", Usage{Traffic30: 100}, 1, false},
+		{"traffic over the limit", Usage{Traffic30: 150}, 1, false},
+	}
+	for _, tt := range tests {
+		tt.usage.Limits = limits
+		err := tt.usage.CheckUpload(tt.size)
+		if tt.ok && err != nil {
+			t.Errorf("%s: CheckUpload(%d) = %v, want nil", tt.name, tt.size, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("%s: CheckUpload(%d) = %v, want ErrQuotaExceeded", tt.name, tt.size, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError is returned before any request is sent when an argument is not acceptable
+type ValidationError struct {
+	Field  string // Field is the name of the invalid argument (eg: "contentID")
+	Value  string // Value is the rejected value
+	Reason string // Reason explains why the value was rejected
+}
+
+func (e *ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// validateID checks that id looks like a gofile ID or code, gofile only uses letters, digits, '-' and '_'
+func validateID(field string, id string) error {
+	if id == "" {
+		return &ValidationError{Field: field, Reason: "must not be empty"}
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_':
+		default:
+			return &ValidationError{Field: field, Value: id, Reason: fmt.Sprintf("contains %q", r)}
+		}
+	}
+	return nil
+}
+
+// validateIDs checks that ids holds at least one ID and that every ID is valid
+func validateIDs(field string, ids []string) error {
+	if len(ids) == 0 {
+		return &ValidationError{Field: field, Reason: "at least one ID must be provided"}
+	}
+	for _, id := range ids {
+		if err := validateID(field, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateName checks that a file or folder name is usable
+func validateName(field string, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return &ValidationError{Field: field, Value: name, Reason: "must not be empty"}
+	}
+	if strings.ContainsAny(name, "/\x00") {
+		return &ValidationError{Field: field, Value: name, Reason: "must not contain '/' or NUL"}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestValidateID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"abc123", true},
+		{"a-B_9", true},
+		{"", false},
+		{"a/b", false},
+		{"../root", false},
+		{"a\x00b", false},
+		{"a b", false},
+		{"a.b", false},
+		{"a?x=1", false},
+		{"é", false},
+	}
+	for _, tt := range tests {
+		err := validateID("contentID", tt.id)
+		if tt.valid && err != nil {
+			t.Errorf("validateID(%q) = %v, want nil", tt.id, err)
+		}
+		var verr *ValidationError
+		if !tt.valid && (!errors.As(err, &verr) || verr.Field != "contentID") {
+			t.Errorf("validateID(%q) = %v, want a *ValidationError for contentID", tt.id, err)
+		}
+	}
+}
+
+func TestValidateIDs(t *testing.T) {
+	tests := []struct {
+		ids   []string
+		valid bool
+	}{
+		{[]string{"a"}, true},
+		{[]string{"a", "b-c"}, true},
+		{nil, false},
+		{[]string{}, false},
+		{[]string{"a", ""}, false},
+		{[]string{"a", "b/c"}, false},
+	}
+	for _, tt := range tests {
+		err := validateIDs("contentID", tt.ids)
+		if tt.valid != (err == nil) {
+			t.Errorf("validateIDs(%q) = %v, want valid %v", tt.ids, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"report.pdf", true},
+		{"my folder (2)", true},
+		{"été", true},
+		{"", false},
+		{"   ", false},
+		{"a/b", false},
+		{"a\x00b", false},
+	}
+	for _, tt := range tests {
+		err := validateName("name", tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("validateName(%q) = %v, want nil", tt.name, err)
+		}
+		var verr *ValidationError
+		if !tt.valid && !errors.As(err, &verr) {
+			t.Errorf("validateName(%q) = %v, want a *ValidationError", tt.name, err)
+		}
+	}
+}
+
+func TestValidationBeforeRequest(t *testing.T) {
+	a := testApi(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s %s sent, invalid arguments must be rejected first", r.Method, r.URL.Path)
+	}, nil)
+
+	if _, err := a.DeleteContent(); err == nil {
+		t.Error("DeleteContent without IDs succeeded")
+	}
+	if _, err := a.GetContent("a/b"); err == nil {
+		t.Error("GetContent with a malformed ID succeeded")
+	}
+	if _, err := a.CreateFolder("root", ""); err == nil {
+		t.Error("CreateFolder without a name succeeded")
+	}
+}
@@ -0,0 +1,379 @@
+// package cassette records gofile HTTP interactions to a fixture file and replays them without network
+//
+// A Recorder is an http.RoundTripper meant for api.Options.Transport. In record mode requests are sent
+// through a real transport and every request and response is kept, tokens redacted, until Save writes
+// them to the fixture. In replay mode responses come from the fixture, matched by method, URL path and body:
+//
+//	rec, err := cassette.New("testdata/upload.json", cassette.ModeAuto, nil)
+//	c := api.New(&api.Options{Transport: rec})
+//	// ... exercise c ...
+//	err = rec.Save()
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNoInteraction is returned in replay mode when no recorded interaction matches a request
+var ErrNoInteraction = errors.New("cassette: no recorded interaction matches request")
+
+// Mode selects whether a Recorder records or replays
+type Mode int
+
+const (
+	ModeRecord Mode = iota // send requests over the network and record them
+	ModeReplay             // answer requests from the fixture only
+	ModeAuto               // replay if the fixture exists, record otherwise
+)
+
+// Redacted replaces tokens and password hashes in recorded interactions
+const Redacted = "REDACTED"
+
+// boundary replaces the random multipart boundary of recorded requests so uploads match on replay
+const boundary = "gofile-cassette-boundary"
+
+// Request is a recorded HTTP request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body"`
+}
+
+// Response is a recorded HTTP response
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body"`
+}
+
+// Interaction is a request and the response it received
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Body is a recorded body, stored as text when it is valid UTF-8 and as base64 otherwise
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(map[string]string{"text": string(b)})
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Text   *string `json:"text"`
+		Base64 *string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch {
+	case v.Base64 != nil:
+		decoded, err := base64.StdEncoding.DecodeString(*v.Base64)
+		if err != nil {
+			return err
+		}
+		*b = decoded
+	case v.Text != nil:
+		*b = Body(*v.Text)
+	default:
+		*b = nil
+	}
+	return nil
+}
+
+// Options defines optional configuration for a Recorder.
+type Options struct {
+	Transport http.RoundTripper // Transport sends requests in record mode, defaults to http.DefaultTransport
+	// Match reports whether the recorded interaction i answers req, whose body has been read into body
+	// with its multipart boundary normalized.
+	// Defaults to matching method, URL path and body.
+	Match func(req *http.Request, body []byte, i Interaction) bool
+	// RedactFields are JSON fields of response bodies whose string values are redacted,
+	// defaults to "token" and "guestToken". The Authorization header and accountToken cookie are always redacted.
+	RedactFields []string
+}
+
+// Recorder is an http.RoundTripper recording or replaying interactions
+type Recorder struct {
+	path         string
+	replay       bool
+	transport    http.RoundTripper
+	match        func(req *http.Request, body []byte, i Interaction) bool
+	redactFields []string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool // used[i] reports whether interactions[i] was already replayed
+}
+
+var _ http.RoundTripper = (*Recorder)(nil)
+
+// New creates a recorder backed by the fixture at path.
+//
+// If opts is nil, default settings are used.
+// Returns the recorder or an error if the fixture is needed and can not be read.
+func New(path string, mode Mode, opts *Options) (*Recorder, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	r := &Recorder{
+		path:         path,
+		transport:    http.DefaultTransport,
+		match:        defaultMatch,
+		redactFields: []string{"token", "guestToken"},
+	}
+	if opts.Transport != nil {
+		r.transport = opts.Transport
+	}
+	if opts.Match != nil {
+		r.match = opts.Match
+	}
+	if opts.RedactFields != nil {
+		r.redactFields = opts.RedactFields
+	}
+
+	if mode == ModeAuto {
+		mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			mode = ModeReplay
+		}
+	}
+	if mode != ModeReplay {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette failed: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.interactions); err != nil {
+		return nil, fmt.Errorf("decode cassette failed: %w", err)
+	}
+	r.replay = true
+	r.used = make([]bool, len(r.interactions))
+	return r, nil
+}
+
+// Replaying reports whether the recorder answers from the fixture
+func (r *Recorder) Replaying() bool {
+	return r.replay
+}
+
+// Interactions returns the interactions recorded or loaded so far
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// RoundTrip records or replays req
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	recorded := r.request(req, body)
+
+	if r.replay {
+		return r.replayRequest(req, recorded.Body)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	// redaction can change the body length, the replayed response sets it from the body
+	header := redactHeader(resp.Header)
+	header.Del("Content-Length")
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       r.redactBody(respBody),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replayRequest answers req with the first unused matching interaction
+func (r *Recorder) replayRequest(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.used[i] || !r.match(req, body, in) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL.Path)
+}
+
+// Save writes the recorded interactions to the fixture, it does nothing in replay mode
+func (r *Recorder) Save() error {
+	if r.replay {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("create cassette directory failed: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write cassette failed: %w", err)
+	}
+	return nil
+}
+
+// request returns the recorded form of req, with tokens and password hashes redacted and the multipart boundary normalized
+func (r *Recorder) request(req *http.Request, body []byte) Request {
+	header := redactHeader(req.Header)
+	if mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte(boundary))
+		params["boundary"] = boundary
+		header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	}
+	u := *req.URL
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", Redacted)
+		u.RawQuery = q.Encode()
+	}
+	return Request{
+		Method: req.Method,
+		URL:    u.String(),
+		Header: header,
+		Body:   body,
+	}
+}
+
+// defaultMatch matches method, URL path and body
+func defaultMatch(req *http.Request, body []byte, i Interaction) bool {
+	if req.Method != i.Request.Method {
+		return false
+	}
+	u, err := req.URL.Parse(i.Request.URL)
+	if err != nil || u.Path != req.URL.Path {
+		return false
+	}
+	return bytes.Equal(body, i.Request.Body)
+}
+
+// redactHeader returns a copy of h with the authorization token and accountToken cookie redacted
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	if h == nil {
+		return nil
+	}
+	if h.Get("Authorization") != "" {
+		h.Set("Authorization", "Bearer "+Redacted)
+	}
+	if cookies := h.Values("Cookie"); len(cookies) > 0 {
+		h.Del("Cookie")
+		for _, c := range cookies {
+			h.Add("Cookie", redactCookies(c))
+		}
+	}
+	for i, c := range h.Values("Set-Cookie") {
+		h["Set-Cookie"][i] = redactCookies(c)
+	}
+	return h
+}
+
+// redactCookies redacts the accountToken value in a Cookie or Set-Cookie header value
+func redactCookies(v string) string {
+	parts := strings.Split(v, ";")
+	for i, p := range parts {
+		name, _, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if ok && name == "accountToken" {
+			parts[i] = strings.Replace(p, strings.TrimSpace(p), name+"="+Redacted, 1)
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// redactBody redacts the configured fields of a JSON body, other bodies are returned as is
+func (r *Recorder) redactBody(body []byte) Body {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	if !redactJSON(v, r.redactFields) {
+		return body
+	}
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// redactJSON replaces string values of the named fields anywhere in v and reports whether any was found
+func redactJSON(v any, fields []string) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if _, ok := child.(string); ok && slices.Contains(fields, k) {
+				v[k] = Redacted
+				changed = true
+				continue
+			}
+			changed = redactJSON(child, fields) || changed
+		}
+	case []any:
+		for _, child := range v {
+			changed = redactJSON(child, fields) || changed
+		}
+	}
+	return changed
+}
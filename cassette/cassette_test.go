@@ -0,0 +1,247 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// handlerTransport answers requests with a handler instead of the network
+type handlerTransport struct {
+	h http.HandlerFunc
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.h(w, req)
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// binaryBody is a response body which is not valid UTF-8
+var binaryBody = []byte{0xff, 0xfe, 0x00, 0x01}
+
+// gofile answers like the gofile API: a guest account, an upload echoing the file name and a binary download
+func gofile(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/accounts":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "accountToken=secret-token; Path=/")
+		io.WriteString(w, `{"status":"ok","data":{"id":"acc","token":"secret-token"}}`)
+	case "/contents/uploadfile":
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Close()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"ok","data":{"name":"`+fh.Filename+`"}}`)
+	case "/download/a.bin":
+		w.Write(binaryBody)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// uploadRequest returns a multipart upload of a file named name, each call uses a new random boundary
+func uploadRequest(t *testing.T, name string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "hello world")
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "https://store1.gofile.io/contents/uploadfile", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// requests returns the requests of the round-trip test
+func requests(t *testing.T) []*http.Request {
+	account := httptest.NewRequest(http.MethodPost, "https://api.gofile.io/accounts", nil)
+	account.Header.Set("Authorization", "Bearer secret-token")
+	return []*http.Request{
+		account,
+		uploadRequest(t, "a.txt"),
+		httptest.NewRequest(http.MethodGet, "https://store1.gofile.io/download/a.bin?password=hash", nil),
+	}
+}
+
+// roundTrip sends req through rt and returns the status and body of the response
+func roundTrip(t *testing.T, rt http.RoundTripper, req *http.Request) (int, []byte) {
+	t.Helper()
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+	rec, err := New(path, ModeAuto, &Options{Transport: handlerTransport{h: gofile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Replaying() {
+		t.Fatal("replaying without a fixture")
+	}
+	var recorded [][]byte
+	for _, req := range requests(t) {
+		_, body := roundTrip(t, rec, req)
+		recorded = append(recorded, body)
+	}
+	if !bytes.Contains(recorded[0], []byte("secret-token")) {
+		t.Errorf("recording changed the response returned to the caller: %s", recorded[0])
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret-token")) || bytes.Contains(data, []byte("password=hash")) {
+		t.Errorf("fixture holds a secret:\n%s", data)
+	}
+
+	rec, err = New(path, ModeAuto, &Options{Transport: handlerTransport{h: func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("replay sent %s %s over the transport", r.Method, r.URL)
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Replaying() {
+		t.Fatal("not replaying an existing fixture")
+	}
+	for i, req := range requests(t) {
+		status, body := roundTrip(t, rec, req)
+		if status != http.StatusOK {
+			t.Errorf("%s: status %d", req.URL.Path, status)
+		}
+		want := recorded[i]
+		if i == 0 {
+			want = []byte(`{"data":{"id":"acc","token":"REDACTED"},"status":"ok"}`)
+		}
+		if !bytes.Equal(body, want) {
+			t.Errorf("%s: replayed %q, want %q", req.URL.Path, body, want)
+		}
+	}
+
+	got := rec.Interactions()
+	if len(got) != 3 {
+		t.Fatalf("%d interactions loaded, want 3", len(got))
+	}
+	if h := got[0].Request.Header.Get("Authorization"); h != "Bearer "+Redacted {
+		t.Errorf("recorded Authorization header %q, want it redacted", h)
+	}
+	if c := got[0].Response.Header.Get("Set-Cookie"); !strings.HasPrefix(c, "accountToken="+Redacted+";") {
+		t.Errorf("recorded Set-Cookie header %q, want the token redacted", c)
+	}
+	if !bytes.Equal(got[2].Response.Body, binaryBody) {
+		t.Errorf("binary body replayed as %v, want %v", got[2].Response.Body, binaryBody)
+	}
+}
+
+// fixture writes interactions to a cassette file and returns a recorder replaying it
+func fixture(t *testing.T, opts *Options, interactions ...Interaction) *Recorder {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	data, err := json.Marshal(interactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := New(path, ModeReplay, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestReplayMismatch(t *testing.T) {
+	ok := Interaction{
+		Request:  Request{Method: http.MethodPut, URL: "https://api.gofile.io/contents/f1/update", Body: Body(`{"attribute":"name"}`)},
+		Response: Response{StatusCode: http.StatusOK, Body: Body(`{"status":"ok"}`)},
+	}
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+	}{
+		{"method", http.MethodPost, "https://api.gofile.io/contents/f1/update", `{"attribute":"name"}`},
+		{"path", http.MethodPut, "https://api.gofile.io/contents/f2/update", `{"attribute":"name"}`},
+		{"body", http.MethodPut, "https://api.gofile.io/contents/f1/update", `{"attribute":"tags"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := fixture(t, nil, ok)
+			_, err := rec.RoundTrip(httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+			if !errors.Is(err, ErrNoInteraction) {
+				t.Errorf("error %v, want ErrNoInteraction", err)
+			}
+		})
+	}
+}
+
+func TestReplayUsesInteractionsOnce(t *testing.T) {
+	first := Interaction{
+		Request:  Request{Method: http.MethodGet, URL: "https://api.gofile.io/contents/f1"},
+		Response: Response{StatusCode: http.StatusOK, Body: Body("first")},
+	}
+	second := first
+	second.Response.Body = Body("second")
+	rec := fixture(t, nil, first, second)
+
+	for _, want := range []string{"first", "second"} {
+		if _, body := roundTrip(t, rec, httptest.NewRequest(http.MethodGet, "https://api.gofile.io/contents/f1", nil)); string(body) != want {
+			t.Errorf("replayed %q, want %q", body, want)
+		}
+	}
+	_, err := rec.RoundTrip(httptest.NewRequest(http.MethodGet, "https://api.gofile.io/contents/f1", nil))
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("third request: error %v, want ErrNoInteraction", err)
+	}
+}
+
+func TestReplayCustomMatch(t *testing.T) {
+	i := Interaction{
+		Request:  Request{Method: http.MethodPost, URL: "https://api.gofile.io/accounts", Body: Body("recorded")},
+		Response: Response{StatusCode: http.StatusOK, Body: Body("ok")},
+	}
+	rec := fixture(t, &Options{Match: func(req *http.Request, body []byte, i Interaction) bool {
+		return req.Method == i.Request.Method
+	}}, i)
+
+	if _, body := roundTrip(t, rec, httptest.NewRequest(http.MethodPost, "https://api.gofile.io/other", strings.NewReader("sent"))); string(body) != "ok" {
+		t.Errorf("replayed %q, want the interaction accepted by Match", body)
+	}
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil); err == nil {
+		t.Error("replaying a missing fixture did not fail")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/plutack/go-gofile/api"
+)
+
+// runDelete deletes contents after listing them, folders and large deletions must be confirmed
+func runDelete(args []string) error {
+	fs := newFlagSet("delete")
+	dryRun := fs.Bool("dry-run", false, "only list what would be deleted")
+	yes := fs.Bool("yes", false, "delete without asking for confirmation")
+	threshold := fs.Int("confirm-over", 10, "ask for confirmation when deleting more than `n` items")
+	manifest := fs.String("manifest", "", "append the deleted items to the JSON lines file at `path`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("expected at least one content ID")
+	}
+
+	opts := api.DeleteOptions{
+		DryRun:           *dryRun,
+		ConfirmThreshold: *threshold,
+		ManifestPath:     *manifest,
+		Confirm: func(plan api.DeletePlan) bool {
+			return *yes || confirmDelete(plan, os.Stdin, os.Stderr)
+		},
+	}
+	plan, err := api.New(nil).SafeDelete(opts, fs.Args()...)
+	if errors.Is(err, api.ErrDeleteNotConfirmed) {
+		return errors.New("nothing was deleted")
+	}
+	if err != nil && !errors.Is(err, api.ErrNotDeleted) {
+		return err
+	}
+	if *dryRun {
+		printPlan(os.Stdout, plan)
+		return nil
+	}
+	for _, it := range plan.Items {
+		if it.DeletedAt.IsZero() {
+			fmt.Printf("not deleted %s (%s): %s\n", it.Name, it.ID, it.Status)
+			continue
+		}
+		fmt.Printf("deleted %s (%s)\n", it.Name, it.ID)
+	}
+	return err
+}
+
+// printPlan lists the items of plan and the space they take
+func printPlan(w io.Writer, plan api.DeletePlan) {
+	for _, it := range plan.Items {
+		fmt.Fprintf(w, "%-6s %12d  %s (%s)\n", it.Type, it.Size, it.Name, it.ID)
+		if n := len(it.Contents); n > 0 {
+			fmt.Fprintf(w, "       %12s  and the %d files and folders it holds\n", "", n)
+		}
+	}
+	fmt.Fprintf(w, "%d items, %d bytes\n", len(plan.Items), plan.TotalSize())
+}
+
+// confirmDelete prints plan to out and reports whether the answer read from in is yes
+func confirmDelete(plan api.DeletePlan, in io.Reader, out io.Writer) bool {
+	printPlan(out, plan)
+	fmt.Fprint(out, "delete these items? [y/N] ")
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
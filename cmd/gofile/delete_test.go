@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plutack/go-gofile/api"
+)
+
+func TestConfirmDelete(t *testing.T) {
+	plan := api.DeletePlan{Items: []api.DeleteItem{{ID: "f", Name: "a.txt", Type: "file", Size: 5}}}
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"y\n", true},
+		{" YES \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"maybe\n", false},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if got := confirmDelete(plan, strings.NewReader(tt.answer), &out); got != tt.want {
+			t.Errorf("answer %q confirmed %v, want %v", tt.answer, got, tt.want)
+		}
+		if !strings.Contains(out.String(), "a.txt (f)") {
+			t.Errorf("prompt %q does not list the item", out.String())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/plutack/go-gofile/api"
+)
+
+// runDownload writes a file to disk, asking for its password if it is protected
+func runDownload(args []string) error {
+	fs := newFlagSet("download")
+	out := fs.String("o", "", "write the file to `path`, defaults to its gofile name in the current directory")
+	var pw passwordFlags
+	pw.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("expected a single content ID")
+	}
+	password, err := pw.password()
+	if err != nil {
+		return err
+	}
+
+	_, err = download(api.New(nil), fs.Arg(0), password, *out)
+	if errors.Is(err, api.ErrPasswordRequired) {
+		return fmt.Errorf("%w, pass it with -password-prompt or -password-env", err)
+	}
+	return err
+}
+
+// download writes the file contentID to out, or to its gofile name in the current directory if out is empty.
+//
+// The file is written to a temporary file next to its destination and renamed once complete,
+// so a failed download neither leaves a partial file nor truncates an existing one.
+// Returns the path of the downloaded file.
+func download(a *api.Api, contentID string, password string, out string) (string, error) {
+	dir := "."
+	if out != "" {
+		dir = filepath.Dir(out)
+	}
+	f, err := os.CreateTemp(dir, ".gofile-*.part")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	// CreateTemp only grants access to the owner, use the permissions os.Create would
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return "", err
+	}
+	content, err := a.DownloadContent(contentID, password, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	path := out
+	if path == "" {
+		if path, err = outputName(content.Name); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// outputName returns the local file name of a remote file, only its last element is kept
+// so a name such as "../x" or "/etc/x" can not point outside the current directory
+func outputName(name string) (string, error) {
+	base := filepath.Base(name)
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		return "", fmt.Errorf("remote name %q is not a safe local file name, pass one with -o", name)
+	}
+	return base, nil
+}
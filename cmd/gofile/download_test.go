@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/plutack/go-gofile/api"
+)
+
+// handlerTransport answers requests with a handler instead of the network
+type handlerTransport struct {
+	h http.HandlerFunc
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.h(w, req)
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// fileApi returns an Api answering a file named name whose download link serves body with status
+func fileApi(name string, link string, status int, body string) *api.Api {
+	token, retries := "token", 0
+	return api.New(&api.Options{APIToken: &token, RetryCount: &retries, Transport: handlerTransport{h: func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/contents/f1" {
+			json.NewEncoder(w).Encode(map[string]any{"status": "ok", "data": map[string]any{
+				"id": "f1", "type": "file", "name": name, "link": link,
+			}})
+			return
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}}})
+}
+
+// link is the download link of the file answered by fileApi
+const link = "https://store1.gofile.io/download/web/f1/a.txt"
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"report.pdf", "report.pdf", true},
+		{"../../.bashrc", ".bashrc", true},
+		{"/etc/x", "x", true},
+		{"", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"/", "", false},
+	}
+	for _, tt := range tests {
+		got, err := outputName(tt.name)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("outputName(%q) = %q, %v, want %q and ok %v", tt.name, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDownloadStaysInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	path, err := download(fileApi("../../evil.txt", link, http.StatusOK, "hello"), "f1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "evil.txt" {
+		t.Errorf("downloaded to %s, want evil.txt in the current directory", path)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "evil.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("read %q, %v, want the file content", data, err)
+	}
+}
+
+func TestDownloadFailureKeepsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(out, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, a := range map[string]*api.Api{
+		"failed download": fileApi("a.txt", link, http.StatusInternalServerError, "oops"),
+		"no link":         fileApi("a.txt", "", http.StatusOK, "hello"),
+	} {
+		if _, err := download(a, "f1", "", out); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if data, _ := os.ReadFile(out); string(data) != "previous" {
+			t.Errorf("%s: existing file holds %q, want it untouched", name, data)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("%s: %d files left in the directory, want only the existing one", name, len(entries))
+		}
+	}
+}
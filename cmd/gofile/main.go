@@ -0,0 +1,66 @@
+// command gofile downloads, deletes and serves gofile content from the command line
+//
+// The account token is read from the gofile_api_key environment variable.
+//
+//	gofile download -o report.pdf <contentID>
+//	gofile delete -manifest deleted.jsonl <contentID>...
+//	gofile serve webdav -addr localhost:8080
+//	gofile serve relay -keys keys.txt
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command is a gofile subcommand
+type command struct {
+	name  string
+	usage string                    // usage lists the arguments after the command name
+	run   func(args []string) error // run parses args, which exclude the command name, and runs the command
+}
+
+// commands lists the subcommands in the order they are printed by usage
+var commands = []command{
+	{name: "download", usage: "[-o file] [-password-env name | -password-prompt] <contentID>", run: runDownload},
+	{name: "delete", usage: "[-dry-run] [-yes] [-confirm-over n] [-manifest file] <contentID>...", run: runDelete},
+	{name: "serve", usage: serveUsage(), run: runServe},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gofile <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+		err := c.run(os.Args[2:])
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gofile %s: %v\n", c.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	usage()
+	os.Exit(2)
+}
+
+// newFlagSet returns the flag set of the command name, errors are returned instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("gofile "+name, flag.ContinueOnError)
+}
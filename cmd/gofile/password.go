@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultPasswordEnv is the environment variable the content password is read from by default
+const defaultPasswordEnv = "GOFILE_PASSWORD"
+
+// passwordFlags selects where the content password comes from.
+//
+// The password itself is never accepted on the command line where other users could read it.
+type passwordFlags struct {
+	env    string // env is the environment variable holding the password
+	prompt bool   // prompt asks for the password on the terminal instead
+}
+
+// register adds the password flags to fs
+func (p *passwordFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&p.env, "password-env", defaultPasswordEnv, "read the content password from the environment variable `name`")
+	fs.BoolVar(&p.prompt, "password-prompt", false, "ask for the content password on the terminal")
+}
+
+// password returns the content password, empty if none was supplied
+func (p *passwordFlags) password() (string, error) {
+	if p.prompt {
+		return promptPassword(os.Stdin, os.Stderr)
+	}
+	return os.Getenv(p.env), nil
+}
+
+// promptPassword asks for a password on out and reads it from in, with echo disabled if in is a terminal
+func promptPassword(in *os.File, out io.Writer) (string, error) {
+	fmt.Fprint(out, "content password: ")
+	if setEcho(in, false) {
+		defer func() {
+			setEcho(in, true)
+			fmt.Fprintln(out)
+		}()
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("read password failed: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// setEcho turns the terminal echo of in on or off with stty and reports whether it succeeded,
+// it fails when in is not a terminal
+func setEcho(in *os.File, on bool) bool {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = in
+	return cmd.Run() == nil
+}
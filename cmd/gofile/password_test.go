@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestPasswordFromEnv(t *testing.T) {
+	t.Setenv("CUSTOM_PASSWORD", "secret")
+	p := passwordFlags{env: "CUSTOM_PASSWORD"}
+	if got, err := p.password(); err != nil || got != "secret" {
+		t.Errorf("password() = %q, %v, want secret", got, err)
+	}
+}
+
+func TestPromptPassword(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w.WriteString("secret\r\n")
+	w.Close()
+
+	// a pipe is not a terminal so stty fails and the password is read as is
+	got, err := promptPassword(r, io.Discard)
+	if err != nil || got != "secret" {
+		t.Errorf("promptPassword() = %q, %v, want secret", got, err)
+	}
+}
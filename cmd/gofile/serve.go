@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/gofiledav"
+	"github.com/plutack/go-gofile/metrics"
+	"github.com/plutack/go-gofile/relay"
+)
+
+// servers lists the services started by the serve command
+var servers = []command{
+	{name: "webdav", usage: "[-addr host:port] [-metrics host:port] [-folder id] [-prefix path] [-server name | -zone eu|na]", run: runServeWebDAV},
+	{name: "relay", usage: "[-addr host:port] [-metrics host:port] [-keys file] [-tls-cert file -tls-key file [-client-ca file]] [-folder id] [-route [client]:[pattern]=folderID]... [-server name | -zone eu|na]", run: runServeRelay},
+}
+
+// runServe starts the service named by the first argument
+func runServe(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected a service: " + serverNames())
+	}
+	for _, s := range servers {
+		if s.name == args[0] {
+			return s.run(args[1:])
+		}
+	}
+	return fmt.Errorf("unknown service %q, expected one of: %s", args[0], serverNames())
+}
+
+// serverNames returns the names of the services separated by commas
+func serverNames() string {
+	var names []string
+	for _, s := range servers {
+		names = append(names, s.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// serveUsage returns the usage of the serve command
+func serveUsage() string {
+	u := "<service> [flags]"
+	for _, s := range servers {
+		u += "\n      " + s.name + " " + s.usage
+	}
+	return u
+}
+
+// metricsUsage is the usage of the -metrics flag of the services
+const metricsUsage = "serve Prometheus metrics at /metrics on `host:port`, separately from the service"
+
+// newAPI returns the Api of a service, its requests and transfers are served as Prometheus metrics
+// on metricsAddr unless it is empty.
+//
+// Returns the Api and a channel receiving the error which stopped the metrics server, nil if
+// metricsAddr is empty, or an error if metricsAddr can not be listened on.
+func newAPI(metricsAddr string) (*api.Api, <-chan error, error) {
+	if metricsAddr == "" {
+		return api.New(nil), nil, nil
+	}
+	ln, err := net.Listen("tcp", metricsAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("serving metrics on http://%s/metrics\n", ln.Addr())
+	reg, errc := serveMetrics(ln)
+	return api.New(&api.Options{Metrics: reg}), errc, nil
+}
+
+// serveMetrics serves the metrics of the returned registry at /metrics on ln in the background.
+//
+// The error which stopped the server is sent on the returned channel.
+func serveMetrics(ln net.Listener) (*metrics.Registry, <-chan error) {
+	reg := metrics.NewRegistry(nil)
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", reg)
+	srv := &http.Server{Handler: mux}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+	return reg, errc
+}
+
+// serveUntil runs serve until it returns or the metrics server reporting on metricsErr fails.
+//
+// Returns the error of whichever stopped first.
+func serveUntil(metricsErr <-chan error, serve func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serve()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case err := <-metricsErr:
+		return fmt.Errorf("metrics server: %w", err)
+	}
+}
+
+// runServeWebDAV serves a gofile folder over WebDAV until the server fails
+func runServeWebDAV(args []string) error {
+	fs := newFlagSet("serve webdav")
+	addr := fs.String("addr", "localhost:8080", "listen on `host:port`")
+	metricsAddr := fs.String("metrics", "", metricsUsage)
+	folder := fs.String("folder", "", "serve the folder with this `id` instead of the account's root folder")
+	prefix := fs.String("prefix", "", "URL path `prefix` stripped from WebDAV paths")
+	server := fs.String("server", "", "upload to the server `name` instead of picking one")
+	zone := fs.String("zone", "", "pick upload servers in the `zone` eu or na")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	a, metricsErr, err := newAPI(*metricsAddr)
+	if err != nil {
+		return err
+	}
+	h, err := gofiledav.NewHandler(a, &gofiledav.Options{
+		FolderID: *folder,
+		Server:   *server,
+		Zone:     *zone,
+		Prefix:   *prefix,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("serving WebDAV on http://%s%s/\n", *addr, *prefix)
+	return serveUntil(metricsErr, func() error {
+		return http.ListenAndServe(*addr, h)
+	})
+}
+
+// runServeRelay relays uploads of authenticated clients to gofile until the server fails
+func runServeRelay(args []string) error {
+	fs := newFlagSet("serve relay")
+	addr := fs.String("addr", "localhost:8080", "listen on `host:port`")
+	metricsAddr := fs.String("metrics", "", metricsUsage)
+	keys := fs.String("keys", "", "accept the API keys listed in `file`, one \"<client> <key>\" pair per line")
+	certFile := fs.String("tls-cert", "", "serve HTTPS with the certificate in `file`")
+	keyFile := fs.String("tls-key", "", "private key of the -tls-cert certificate in `file`")
+	clientCA := fs.String("client-ca", "", "accept client certificates signed by the CAs in `file`, requires -tls-cert")
+	folder := fs.String("folder", "", "upload into the folder with this `id` when no route matches, defaults to the account's root folder")
+	var routes []relay.Route
+	fs.Func("route", "send uploads matching the `route` [client]:[pattern]=folderID to its folder, can be repeated and the first match wins", func(v string) error {
+		r, err := parseRoute(v)
+		routes = append(routes, r)
+		return err
+	})
+	server := fs.String("server", "", "upload to the server `name` instead of picking one")
+	zone := fs.String("zone", "", "pick upload servers in the `zone` eu or na")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be used together")
+	}
+	if *clientCA != "" && *certFile == "" {
+		return errors.New("-client-ca requires -tls-cert")
+	}
+
+	opts := &relay.Options{
+		ClientCerts: *clientCA != "",
+		FolderID:    *folder,
+		Routes:      routes,
+		Server:      *server,
+		Zone:        *zone,
+		Logger:      slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	if *keys != "" {
+		f, err := os.Open(*keys)
+		if err != nil {
+			return err
+		}
+		opts.APIKeys, err = readKeys(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", *keys, err)
+		}
+	}
+	a, metricsErr, err := newAPI(*metricsAddr)
+	if err != nil {
+		return err
+	}
+	h, err := relay.New(a, opts)
+	if errors.Is(err, relay.ErrNoAuth) {
+		return errors.New("no clients can authenticate, pass -keys or -client-ca")
+	}
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: h}
+	if *certFile == "" {
+		fmt.Printf("relaying uploads on http://%s/\n", *addr)
+		return serveUntil(metricsErr, srv.ListenAndServe)
+	}
+	if *clientCA != "" {
+		pem, err := os.ReadFile(*clientCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: no PEM certificate found", *clientCA)
+		}
+		srv.TLSConfig = relay.TLSConfig(pool, len(opts.APIKeys) > 0)
+	}
+	fmt.Printf("relaying uploads on https://%s/\n", *addr)
+	return serveUntil(metricsErr, func() error {
+		return srv.ListenAndServeTLS(*certFile, *keyFile)
+	})
+}
+
+// readKeys reads "<client> <key>" pairs, one per line, blank lines and lines starting with # are skipped
+func readKeys(r io.Reader) (map[string]string, error) {
+	keys := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<client> <key>\"", n)
+		}
+		if _, ok := keys[fields[1]]; ok {
+			return nil, fmt.Errorf("line %d: key already given to client %s", n, keys[fields[1]])
+		}
+		keys[fields[1]] = fields[0]
+	}
+	return keys, sc.Err()
+}
+
+// parseRoute parses a route written as "[client]:[pattern]=folderID"
+func parseRoute(v string) (relay.Route, error) {
+	match, folder, ok := strings.Cut(v, "=")
+	client, name, ok2 := strings.Cut(match, ":")
+	if !ok || !ok2 || folder == "" {
+		return relay.Route{}, fmt.Errorf("route %q: expected [client]:[pattern]=folderID", v)
+	}
+	return relay.Route{Client: client, Name: name, FolderID: folder}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/plutack/go-gofile/relay"
+)
+
+func TestReadKeys(t *testing.T) {
+	keys, err := readKeys(strings.NewReader("# clients\nbackup  k1\n\nscanner k2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"k1": "backup", "k2": "scanner"}; !maps.Equal(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+
+	for _, in := range []string{"backup\n", "backup k1 extra\n", "backup k1\nscanner k1\n"} {
+		if _, err := readKeys(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		in   string
+		want relay.Route
+		ok   bool
+	}{
+		{"backup:*.tar=tars", relay.Route{Client: "backup", Name: "*.tar", FolderID: "tars"}, true},
+		{":*.log=logs", relay.Route{Name: "*.log", FolderID: "logs"}, true},
+		{"backup:=backups", relay.Route{Client: "backup", FolderID: "backups"}, true},
+		{"*.log=logs", relay.Route{}, false},
+		{"backup:*.tar", relay.Route{}, false},
+		{"backup:*.tar=", relay.Route{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseRoute(tt.in)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("parseRoute(%q) = %+v, %v, want %+v and ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	reg, _ := serveMetrics(ln)
+	reg.IncRetry("GET", "503")
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `gofile_retries_total{method="GET",reason="503"} 1`) {
+		t.Errorf("status %d, body:\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestServeUntilMetricsFail(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, metricsErr := serveMetrics(ln)
+	// the metrics listener dies while the service keeps running
+	ln.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	err = serveUntil(metricsErr, func() error {
+		<-stop
+		return nil
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "metrics server: ") {
+		t.Errorf("error %v, want the failure of the metrics server", err)
+	}
+}
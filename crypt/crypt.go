@@ -0,0 +1,251 @@
+// package crypt encrypts files on the client so gofile only ever stores ciphertext
+//
+// Content is encrypted with AES-256-GCM in chunks so it can be streamed in both directions.
+// The key comes either from a passphrase, stretched with PBKDF2-SHA256, or from a key file
+// holding 32 random bytes. Every encrypted file starts with a small header:
+//
+//	magic "GOFE" | version | kdf | PBKDF2 iterations (4) | PBKDF2 salt (16) | chunk size (4) | file nonce (16)
+//
+// A separate key is derived for each file from the file nonce with HKDF, and each chunk is sealed
+// with its index and a final chunk flag as nonce and the header as additional data, so chunks can
+// not be reordered, dropped, truncated or moved between files without the decryption failing.
+//
+// File names can be encrypted as well with EncryptName, they get the NameSuffix extension.
+package crypt
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	ErrNotEncrypted      = errors.New("content is not encrypted")                          // the header magic is missing
+	ErrUnsupported       = errors.New("unsupported encryption format")                     // the header has an unknown version or parameters
+	ErrKeyMismatch       = errors.New("content was encrypted with another kind of key")    // a passphrase key was used for key file content or the reverse
+	ErrAuthentication    = errors.New("decryption failed: wrong key or corrupted content") // a chunk or name failed authentication
+	ErrTruncated         = errors.New("encrypted content is truncated")                    // the content ended before its final chunk
+	ErrInvalidKey        = errors.New("key must be 32 bytes, raw or hex encoded")          // a key or key file has the wrong length
+	ErrEmptyPassphrase   = errors.New("passphrase is empty")                               // NewPassphraseKey was called without a passphrase
+	ErrInvalidIterations = errors.New("PBKDF2 iterations out of range")                    // NewPassphraseKey was called with more than 10 million iterations
+)
+
+const (
+	// DefaultIterations is the PBKDF2 iteration count used when NewPassphraseKey is called with 0
+	DefaultIterations = 600_000
+	// DefaultChunkSize is the size of the plaintext chunks sealed one by one
+	DefaultChunkSize = 64 * 1024
+	// HeaderSize is the size of the header written before the encrypted chunks
+	HeaderSize = 46
+	// Overhead is the authentication tag added to every chunk
+	Overhead = 16
+
+	magic         = "GOFE"
+	version       = 1
+	keySize       = 32
+	saltSize      = 16
+	maxIterations = 10_000_000
+	maxChunkSize  = 16 * 1024 * 1024
+)
+
+// kdf tells how the master key of a file was obtained
+type kdf byte
+
+const (
+	kdfKeyFile kdf = 1 // the master key is read from a key file
+	kdfPBKDF2  kdf = 2 // the master key is derived from a passphrase
+)
+
+// Key is the secret used to encrypt and decrypt content and names, it is safe for concurrent use
+type Key struct {
+	kdf        kdf
+	passphrase string
+	iterations int    // PBKDF2 iterations used for new content
+	salt       []byte // PBKDF2 salt used for new content
+	master     []byte // master key of new content
+
+	mu      sync.Mutex
+	derived map[string][]byte // PBKDF2 iterations and salt to master key, so content from another key is only derived once
+}
+
+// NewKey creates a key from 32 raw bytes.
+//
+// Returns the key or ErrInvalidKey.
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != keySize {
+		return nil, ErrInvalidKey
+	}
+	return &Key{kdf: kdfKeyFile, master: bytes.Clone(raw)}, nil
+}
+
+// LoadKeyFile reads a key from a file holding 32 raw bytes or 64 hex characters, see GenerateKeyFile.
+//
+// Returns the key or an error.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file failed: %w", err)
+	}
+	if len(data) == keySize {
+		return NewKey(data)
+	}
+	raw, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return NewKey(raw)
+}
+
+// GenerateKeyFile writes a new random key as hex to path, readable by the owner only.
+//
+// An existing file is never overwritten.
+// Returns the key or an error.
+func GenerateKeyFile(path string) (*Key, error) {
+	raw := make([]byte, keySize)
+	rand.Read(raw)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create key file failed: %w", err)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(raw) + "\n"); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write key file failed: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("write key file failed: %w", err)
+	}
+	return NewKey(raw)
+}
+
+// NewPassphraseKey creates a key from a passphrase stretched with PBKDF2-SHA256.
+//
+// iterations is the PBKDF2 iteration count for new content, DefaultIterations if 0.
+// Content encrypted with other iteration counts or salts can still be decrypted.
+// Returns the key or an error.
+func NewPassphraseKey(passphrase string, iterations int) (*Key, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	if iterations == 0 {
+		iterations = DefaultIterations
+	}
+	if iterations < 0 || iterations > maxIterations {
+		return nil, ErrInvalidIterations
+	}
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+	master, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive key failed: %w", err)
+	}
+	return &Key{
+		kdf:        kdfPBKDF2,
+		passphrase: passphrase,
+		iterations: iterations,
+		salt:       salt,
+		master:     master,
+		derived:    map[string][]byte{},
+	}, nil
+}
+
+// masterFor returns the master key of content encrypted with the specified kdf parameters
+func (k *Key) masterFor(f kdf, iterations int, salt []byte) ([]byte, error) {
+	if f != k.kdf {
+		return nil, ErrKeyMismatch
+	}
+	if f == kdfKeyFile || (iterations == k.iterations && bytes.Equal(salt, k.salt)) {
+		return k.master, nil
+	}
+	if iterations < 1 || iterations > maxIterations {
+		return nil, ErrUnsupported
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := fmt.Sprintf("%d:%x", iterations, salt)
+	if master, ok := k.derived[id]; ok {
+		return master, nil
+	}
+	master, err := pbkdf2.Key(sha256.New, k.passphrase, salt, iterations, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive key failed: %w", err)
+	}
+	k.derived[id] = master
+	return master, nil
+}
+
+// header is the parsed header of encrypted content
+type header struct {
+	kdf        kdf
+	iterations int
+	salt       []byte
+	chunkSize  int
+	nonce      []byte // file nonce the content key is derived from
+}
+
+// newHeader returns the header of new content encrypted with k
+func (k *Key) newHeader() header {
+	h := header{
+		kdf:        k.kdf,
+		iterations: k.iterations,
+		salt:       k.salt,
+		chunkSize:  DefaultChunkSize,
+		nonce:      make([]byte, saltSize),
+	}
+	if h.salt == nil {
+		h.salt = make([]byte, saltSize)
+	}
+	rand.Read(h.nonce)
+	return h
+}
+
+func (h header) marshal() []byte {
+	b := make([]byte, 0, HeaderSize)
+	b = append(b, magic...)
+	b = append(b, version, byte(h.kdf))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.iterations))
+	b = append(b, h.salt...)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.chunkSize))
+	return append(b, h.nonce...)
+}
+
+// parseHeader parses the first HeaderSize bytes of encrypted content
+func parseHeader(b []byte) (header, error) {
+	if len(b) < HeaderSize || string(b[:4]) != magic {
+		return header{}, ErrNotEncrypted
+	}
+	h := header{
+		kdf:        kdf(b[5]),
+		iterations: int(binary.BigEndian.Uint32(b[6:10])),
+		salt:       bytes.Clone(b[10:26]),
+		chunkSize:  int(binary.BigEndian.Uint32(b[26:30])),
+		nonce:      bytes.Clone(b[30:46]),
+	}
+	if b[4] != version || (h.kdf != kdfKeyFile && h.kdf != kdfPBKDF2) || h.chunkSize < 1 || h.chunkSize > maxChunkSize {
+		return header{}, ErrUnsupported
+	}
+	return h, nil
+}
+
+// contentKey returns the AES key of the content described by h
+func (k *Key) contentKey(h header) ([]byte, error) {
+	master, err := k.masterFor(h.kdf, h.iterations, h.salt)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, master, h.nonce, "gofile content", keySize)
+}
+
+// EncryptedSize returns the size of content of n bytes once encrypted
+func EncryptedSize(n int64) int64 {
+	chunks := (n + DefaultChunkSize - 1) / DefaultChunkSize
+	return HeaderSize + n + max(chunks, 1)*Overhead
+}
@@ -0,0 +1,66 @@
+package crypt
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
+)
+
+// Download opens the encrypted file behind a direct download link and decrypts it on the fly.
+//
+// The caller must close the returned reader.
+// Returns the decrypted content as a stream or an error, see DecryptReader.
+func Download(a *api.Api, link string, key *Key) (io.ReadCloser, error) {
+	r, err := a.Download(link)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := DecryptReader(r, key)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, r}, nil
+}
+
+// DownloadContent writes the decrypted content of the encrypted file with the specified contentID to w.
+//
+// password is only needed for password protected files, leave it empty otherwise.
+// If the name of the file was encrypted, the returned details carry the decrypted name.
+//
+// Returns the details of the downloaded file or an error.
+func DownloadContent(a *api.Api, contentID string, password string, key *Key, w io.Writer) (model.Content, error) {
+	resp, err := a.GetContentWithPassword(contentID, password)
+	if err != nil {
+		return resp.Data, err
+	}
+	content := resp.Data
+	if content.Type != model.FileType {
+		return content, fmt.Errorf("content %s is a %s, only files can be downloaded", contentID, content.Type)
+	}
+	if content.Link == "" {
+		return content, fmt.Errorf("content %s has no download link", contentID)
+	}
+	if IsEncryptedName(content.Name) {
+		name, err := key.DecryptName(content.Name)
+		if err != nil {
+			return content, fmt.Errorf("decrypt name of %s failed: %w", contentID, err)
+		}
+		content.Name = name
+	}
+
+	r, err := Download(a, content.Link, key)
+	if err != nil {
+		return content, err
+	}
+	defer r.Close()
+	if _, err := io.Copy(w, r); err != nil {
+		return content, fmt.Errorf("download %s failed: %w", contentID, err)
+	}
+	return content, nil
+}
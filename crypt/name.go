@@ -0,0 +1,103 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// NameSuffix is appended to encrypted names so they can be told apart from plain ones
+const NameSuffix = ".gofe"
+
+// nameNonceSize is the size of the random nonce of an encrypted name
+const nameNonceSize = 12
+
+// EncryptName encrypts a file or folder name.
+//
+// The result is URL safe base64 with NameSuffix appended, a third longer than name plus about
+// 70 characters for passphrase keys, whose PBKDF2 parameters it carries, and 45 for key file keys.
+// Encrypting a name twice gives different results.
+// Returns the encrypted name or an error.
+func (k *Key) EncryptName(name string) (string, error) {
+	var prefix []byte
+	prefix = append(prefix, byte(k.kdf))
+	if k.kdf == kdfPBKDF2 {
+		prefix = binary.BigEndian.AppendUint32(prefix, uint32(k.iterations))
+		prefix = append(prefix, k.salt...)
+	}
+	aead, err := nameAEAD(k.master)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, nameNonceSize)
+	rand.Read(nonce)
+	b := append(prefix, nonce...)
+	b = aead.Seal(b, nonce, []byte(name), prefix)
+	return base64.RawURLEncoding.EncodeToString(b) + NameSuffix, nil
+}
+
+// DecryptName decrypts a name encrypted with EncryptName.
+//
+// Returns the name or ErrNotEncrypted, ErrKeyMismatch or ErrAuthentication.
+func (k *Key) DecryptName(encrypted string) (string, error) {
+	encoded, ok := strings.CutSuffix(encrypted, NameSuffix)
+	if !ok {
+		return "", ErrNotEncrypted
+	}
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(b) < 1 {
+		return "", ErrNotEncrypted
+	}
+	f := kdf(b[0])
+	prefixSize := 1
+	iterations := 0
+	var salt []byte
+	if f == kdfPBKDF2 {
+		prefixSize += 4 + saltSize
+		if len(b) < prefixSize {
+			return "", ErrNotEncrypted
+		}
+		iterations = int(binary.BigEndian.Uint32(b[1:5]))
+		salt = b[5:prefixSize]
+	}
+	if len(b) < prefixSize+nameNonceSize+Overhead {
+		return "", ErrNotEncrypted
+	}
+	master, err := k.masterFor(f, iterations, salt)
+	if err != nil {
+		return "", err
+	}
+	aead, err := nameAEAD(master)
+	if err != nil {
+		return "", err
+	}
+	nonce := b[prefixSize : prefixSize+nameNonceSize]
+	plain, err := aead.Open(nil, nonce, b[prefixSize+nameNonceSize:], b[:prefixSize])
+	if err != nil {
+		return "", ErrAuthentication
+	}
+	return string(plain), nil
+}
+
+// IsEncryptedName reports whether name looks like the result of EncryptName
+func IsEncryptedName(name string) bool {
+	return strings.HasSuffix(name, NameSuffix)
+}
+
+// nameAEAD returns the AES-GCM cipher of names for the master key
+func nameAEAD(master []byte) (cipher.AEAD, error) {
+	nk, err := hkdf.Key(sha256.New, master, nil, "gofile name", keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(nk)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
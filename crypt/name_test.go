@@ -0,0 +1,45 @@
+package crypt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNameRoundTrip(t *testing.T) {
+	passphrase, err := NewPassphraseKey("correct horse", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []*Key{testKey(t), passphrase} {
+		for _, name := range []string{"a", "report 2024.pdf", "ünïcödé.txt"} {
+			encrypted, err := k.EncryptName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncryptedName(encrypted) {
+				t.Errorf("%q is not recognized as an encrypted name", encrypted)
+			}
+			got, err := k.DecryptName(encrypted)
+			if err != nil {
+				t.Fatalf("decrypt %q: %v", encrypted, err)
+			}
+			if got != name {
+				t.Errorf("decrypted %q, want %q", got, name)
+			}
+		}
+	}
+}
+
+func TestDecryptNameErrors(t *testing.T) {
+	k := testKey(t)
+	encrypted, err := k.EncryptName("secret.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testKey(t).DecryptName(encrypted); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("wrong key: error = %v, want %v", err, ErrAuthentication)
+	}
+	if _, err := k.DecryptName("secret.txt"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("plain name: error = %v, want %v", err, ErrNotEncrypted)
+	}
+}
@@ -0,0 +1,185 @@
+package crypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// EncryptReader returns a reader producing the header and encrypted chunks of the content read from r.
+//
+// The encrypted size is known up front from the plain size, see EncryptedSize.
+// Returns the reader or an error.
+func EncryptReader(r io.Reader, key *Key) (io.Reader, error) {
+	h := key.newHeader()
+	aead, err := newAEAD(key, h)
+	if err != nil {
+		return nil, err
+	}
+	hb := h.marshal()
+	return &encryptReader{
+		src:    bufio.NewReader(r),
+		aead:   aead,
+		header: hb,
+		plain:  make([]byte, h.chunkSize),
+		sealed: make([]byte, 0, h.chunkSize+Overhead),
+		out:    hb,
+	}, nil
+}
+
+// DecryptReader reads the header from r and returns a reader producing the decrypted content.
+//
+// Reads fail with ErrAuthentication if the content was modified or encrypted with another key, and
+// with ErrTruncated if its final chunk is missing, so nothing read before an error should be trusted.
+// Returns the reader or ErrNotEncrypted, ErrUnsupported, ErrKeyMismatch or a read error.
+func DecryptReader(r io.Reader, key *Key) (io.Reader, error) {
+	hb := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, hb); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotEncrypted
+		}
+		return nil, err
+	}
+	h, err := parseHeader(hb)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key, h)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		src:    bufio.NewReader(r),
+		aead:   aead,
+		header: hb,
+		sealed: make([]byte, h.chunkSize+Overhead),
+		plain:  make([]byte, 0, h.chunkSize),
+	}, nil
+}
+
+// newAEAD returns the AES-GCM cipher of the content described by h
+func newAEAD(key *Key, h header) (cipher.AEAD, error) {
+	ck, err := key.contentKey(h)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(ck)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of chunk i, last is set for the final chunk
+func chunkNonce(i uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], i)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// readChunk fills buf from r and reports whether it is the final chunk
+func readChunk(r *bufio.Reader, buf []byte) (int, bool, error) {
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return n, true, nil
+	case err != nil:
+		return n, false, err
+	}
+	if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+		return n, true, nil
+	} else if err != nil {
+		return n, false, err
+	}
+	return n, false, nil
+}
+
+// encryptReader seals the chunks of src one at a time
+type encryptReader struct {
+	src    *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	plain  []byte
+	sealed []byte
+	out    []byte // sealed bytes not read yet
+	chunk  uint64
+	done   bool
+	err    error
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		if e.done {
+			return 0, io.EOF
+		}
+		n, last, err := readChunk(e.src, e.plain)
+		if err != nil {
+			e.err = err
+			return 0, err
+		}
+		e.out = e.aead.Seal(e.sealed[:0], chunkNonce(e.chunk, last), e.plain[:n], e.header)
+		e.chunk++
+		e.done = last
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// decryptReader opens the chunks of src one at a time
+type decryptReader struct {
+	src    *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	sealed []byte
+	plain  []byte
+	out    []byte // opened bytes not read yet
+	chunk  uint64
+	done   bool
+	err    error
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		n, last, err := readChunk(d.src, d.sealed)
+		if err != nil {
+			d.err = err
+			return 0, err
+		}
+		if n == 0 {
+			d.err = ErrTruncated
+			return 0, d.err
+		}
+		d.out, err = d.aead.Open(d.plain[:0], chunkNonce(d.chunk, last), d.sealed[:n], d.header)
+		if err != nil {
+			d.err = ErrAuthentication
+			// a middle chunk found at the end means the content was cut at a chunk boundary
+			if last {
+				if _, err := d.aead.Open(d.plain[:0], chunkNonce(d.chunk, false), d.sealed[:n], d.header); err == nil {
+					d.err = ErrTruncated
+				}
+			}
+			return 0, d.err
+		}
+		d.chunk++
+		d.done = last
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
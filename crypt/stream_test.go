@@ -0,0 +1,158 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testKey returns a random key file key
+func testKey(t *testing.T) *Key {
+	t.Helper()
+	raw := make([]byte, keySize)
+	rand.Read(raw)
+	k, err := NewKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// encrypt returns plain encrypted with k
+func encrypt(t *testing.T, k *Key, plain []byte) []byte {
+	t.Helper()
+	r, err := EncryptReader(bytes.NewReader(plain), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+// decrypt returns sealed decrypted with k
+func decrypt(k *Key, sealed []byte) ([]byte, error) {
+	r, err := DecryptReader(bytes.NewReader(sealed), k)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	passphrase, err := NewPassphraseKey("correct horse", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]*Key{"key file": testKey(t), "passphrase": passphrase}
+	sizes := []int{0, 1, DefaultChunkSize - 1, DefaultChunkSize, DefaultChunkSize + 1, 3*DefaultChunkSize + 7}
+
+	for name, k := range keys {
+		for _, size := range sizes {
+			plain := make([]byte, size)
+			rand.Read(plain)
+
+			sealed := encrypt(t, k, plain)
+			if got, want := int64(len(sealed)), EncryptedSize(int64(size)); got != want {
+				t.Errorf("%s, %d bytes: encrypted size = %d, EncryptedSize = %d", name, size, got, want)
+			}
+			got, err := decrypt(k, sealed)
+			if err != nil {
+				t.Fatalf("%s, %d bytes: decrypt: %v", name, size, err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("%s, %d bytes: decrypted content differs", name, size)
+			}
+		}
+	}
+}
+
+func TestPassphraseKeyDecryptsOtherSalt(t *testing.T) {
+	a, err := NewPassphraseKey("correct horse", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPassphraseKey("correct horse", 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("hello")
+	got, err := decrypt(b, encrypt(t, a, plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("decrypted %q, want %q", got, plain)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	k := testKey(t)
+	plain := make([]byte, 3*DefaultChunkSize+7)
+	rand.Read(plain)
+	sealed := encrypt(t, k, plain)
+	chunk := DefaultChunkSize + Overhead
+
+	tests := []struct {
+		name string
+		size int
+		want error
+	}{
+		{"inside header", HeaderSize / 2, ErrNotEncrypted},
+		{"after header", HeaderSize, ErrTruncated},
+		{"at chunk boundary", HeaderSize + chunk, ErrTruncated},
+		{"final chunk missing", HeaderSize + 3*chunk, ErrTruncated},
+		{"inside chunk", HeaderSize + chunk + 100, ErrAuthentication},
+		{"inside final chunk", len(sealed) - 1, ErrAuthentication},
+	}
+	for _, tt := range tests {
+		_, err := decrypt(k, sealed[:tt.size])
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestModified(t *testing.T) {
+	k := testKey(t)
+	sealed := encrypt(t, k, []byte("hello world"))
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := decrypt(k, tampered); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("modified chunk: error = %v, want %v", err, ErrAuthentication)
+	}
+
+	// the header is authenticated with every chunk
+	tampered = bytes.Clone(sealed)
+	tampered[HeaderSize-1] ^= 1
+	if _, err := decrypt(k, tampered); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("modified header: error = %v, want %v", err, ErrAuthentication)
+	}
+
+	if _, err := decrypt(testKey(t), sealed); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("wrong key: error = %v, want %v", err, ErrAuthentication)
+	}
+}
+
+func TestKeyMismatch(t *testing.T) {
+	passphrase, err := NewPassphraseKey("correct horse", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := encrypt(t, testKey(t), []byte("hello"))
+	if _, err := decrypt(passphrase, sealed); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("error = %v, want %v", err, ErrKeyMismatch)
+	}
+}
+
+func TestNotEncrypted(t *testing.T) {
+	plain := bytes.Repeat([]byte("plain text "), 10)
+	if _, err := decrypt(testKey(t), plain); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("error = %v, want %v", err, ErrNotEncrypted)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
 )
 
 // What will be carried out in this example
@@ -59,16 +60,12 @@ func main() {
 	// pick one of the random eu server returned
 	euServer := s.Data.Servers[0].Name //this will be used to upload files
 
-	accIdresp, err := c.GetAccountID() // this has the  account id nested in it
-	if err != nil {
-		panic(err)
-	}
-	accInfoResp, err := c.GetAccountInformation(accIdresp.Data.ID) // accountId gotten
+	account, err := c.Account() // resolves the account id and root folder id once and caches them
 	if err != nil {
 		panic(err)
 	}
 
-	rootFolderId := accInfoResp.Data.RootFolder
+	rootFolderId := account.RootFolder
 	log.Printf("root folder id: %s\n", rootFolderId)
 
 	folderInfoResp, err := c.CreateFolder(rootFolderId, "test folder")
@@ -91,11 +88,11 @@ func main() {
 		panic(err)
 	}
 	log.Printf("--------------\ntest folder 2 info\nname: %s\nID: %s\n--------------\n", uploadFileResp2.Data.Name, uploadFileResp2.Data.ID)
-	_, err = c.UpdateContent(uploadFileResp1.Data.ID, "name", "testfile1_renamed")
+	_, err = c.Update(uploadFileResp1.Data.ID, model.SetName("testfile1_renamed"))
 	if err != nil {
 		panic(err)
 	}
-	_, err = c.UpdateContent(folderId, "name", "testfolder renamed")
+	_, err = c.Update(folderId, model.SetName("testfolder renamed"))
 	if err != nil {
 		panic(err)
 	}
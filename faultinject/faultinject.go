@@ -0,0 +1,278 @@
+// package faultinject provides an http.RoundTripper simulating a flaky gofile for resilience testing
+//
+// Faults are either scripted, one per request in order, or drawn from rules with a probability.
+// The transport is meant for api.Options.Transport:
+//
+//	ft := faultinject.New(&faultinject.Options{
+//		Script: []faultinject.Fault{faultinject.Status(503), faultinject.Reset()},
+//		Rules:  []faultinject.Rule{{Fault: faultinject.Delay(2 * time.Second), Probability: 0.1}},
+//	})
+//	c := api.New(&api.Options{Transport: ft})
+package faultinject
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrConnectionReset is the error returned for injected connection resets
+var ErrConnectionReset error = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+// Kind is the kind of a fault
+type Kind string
+
+const (
+	KindNone          Kind = "none"           // the request is sent unchanged
+	KindLatency       Kind = "latency"        // the request is sent after a delay
+	KindReset         Kind = "reset"          // the connection is reset before a response is received
+	KindResetBody     Kind = "reset-body"     // the connection is reset after part of the response body
+	KindStatus        Kind = "status"         // an HTTP error status is returned without sending the request
+	KindGofileStatus  Kind = "gofile-status"  // a 200 response with a gofile error status is returned without sending the request
+	KindTruncatedJSON Kind = "truncated-json" // the response body is cut short
+)
+
+// Fault describes what happens to a request
+type Fault struct {
+	Kind         Kind
+	Latency      time.Duration // Latency is the delay before the request is handled, used by every kind
+	StatusCode   int           // StatusCode is the HTTP status returned by KindStatus
+	GofileStatus string        // GofileStatus is the status returned by KindGofileStatus, e.g. "error-rateLimit"
+	After        int           // After is the number of body bytes delivered by KindResetBody and KindTruncatedJSON, half the body if 0
+}
+
+// None returns a fault sending the request unchanged
+func None() Fault {
+	return Fault{Kind: KindNone}
+}
+
+// Delay returns a fault sending the request after d
+func Delay(d time.Duration) Fault {
+	return Fault{Kind: KindLatency, Latency: d}
+}
+
+// Reset returns a fault resetting the connection before a response is received
+func Reset() Fault {
+	return Fault{Kind: KindReset}
+}
+
+// ResetBody returns a fault resetting the connection after after bytes of the response body, half of it if 0
+func ResetBody(after int) Fault {
+	return Fault{Kind: KindResetBody, After: after}
+}
+
+// Status returns a fault answering with the HTTP status code, e.g. 429 or 503
+func Status(code int) Fault {
+	return Fault{Kind: KindStatus, StatusCode: code}
+}
+
+// GofileStatus returns a fault answering 200 with the gofile status, e.g. "error-notFound"
+func GofileStatus(status string) Fault {
+	return Fault{Kind: KindGofileStatus, GofileStatus: status}
+}
+
+// TruncatedJSON returns a fault cutting the response body after after bytes, half of it if 0
+func TruncatedJSON(after int) Fault {
+	return Fault{Kind: KindTruncatedJSON, After: after}
+}
+
+// Rule injects a fault into matching requests with a probability
+type Rule struct {
+	Fault       Fault
+	Probability float64 // Probability is the chance between 0 and 1 that a matching request gets the fault
+	Method      string  // Method limits the rule to an HTTP method, empty matches every method
+	PathPrefix  string  // PathPrefix limits the rule to URL paths starting with it, e.g. "/contents/uploadfile"
+}
+
+// matches reports whether req is covered by r
+func (r Rule) matches(req *http.Request) bool {
+	return (r.Method == "" || r.Method == req.Method) && strings.HasPrefix(req.URL.Path, r.PathPrefix)
+}
+
+// Options defines optional configuration for a Transport.
+type Options struct {
+	Transport http.RoundTripper // Transport sends requests which are not answered by a fault, defaults to http.DefaultTransport
+	Script    []Fault           // Script faults are applied to the first requests in order, before any rule
+	Rules     []Rule            // Rules are tried in order once the script is exhausted, the first rule drawn applies
+	Seed      *uint64           // Seed makes the rule draws reproducible, a random seed is used if nil
+}
+
+// Injection is a fault applied to a request
+type Injection struct {
+	Method string
+	Path   string
+	Fault  Fault
+}
+
+// Transport is an http.RoundTripper injecting faults
+type Transport struct {
+	transport http.RoundTripper
+	rules     []Rule
+
+	mu       sync.Mutex
+	script   []Fault
+	rand     *rand.Rand
+	injected []Injection
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// New creates a fault injecting transport.
+//
+// If opts is nil, requests are sent unchanged.
+func New(opts *Options) *Transport {
+	if opts == nil {
+		opts = &Options{}
+	}
+	seed := rand.Uint64()
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	}
+	t := &Transport{
+		transport: http.DefaultTransport,
+		rules:     opts.Rules,
+		script:    append([]Fault(nil), opts.Script...),
+		rand:      rand.New(rand.NewPCG(seed, seed)),
+	}
+	if opts.Transport != nil {
+		t.transport = opts.Transport
+	}
+	return t
+}
+
+// Injected returns the faults applied so far, requests sent unchanged are not included
+func (t *Transport) Injected() []Injection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Injection(nil), t.injected...)
+}
+
+// next returns the fault for req and records it
+func (t *Transport) next(req *http.Request) Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f := None()
+	if len(t.script) > 0 {
+		f = t.script[0]
+		t.script = t.script[1:]
+	} else {
+		for _, r := range t.rules {
+			if r.matches(req) && t.rand.Float64() < r.Probability {
+				f = r.Fault
+				break
+			}
+		}
+	}
+	if f.Kind != KindNone || f.Latency > 0 {
+		t.injected = append(t.injected, Injection{Method: req.Method, Path: req.URL.Path, Fault: f})
+	}
+	return f
+}
+
+// RoundTrip sends req or answers it with the next fault
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := t.next(req)
+
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	switch f.Kind {
+	case KindNone, KindLatency, "":
+		return t.transport.RoundTrip(req)
+	case KindReset:
+		closeBody(req)
+		return nil, ErrConnectionReset
+	case KindStatus:
+		closeBody(req)
+		resp := response(req, f.StatusCode, fmt.Sprintf(`{"status":"error","data":{"code":%d}}`, f.StatusCode))
+		if f.StatusCode == http.StatusTooManyRequests {
+			resp.Header.Set("Retry-After", "1")
+		}
+		return resp, nil
+	case KindGofileStatus:
+		closeBody(req)
+		return response(req, http.StatusOK, fmt.Sprintf(`{"status":%q,"data":{}}`, f.GofileStatus)), nil
+	case KindResetBody, KindTruncatedJSON:
+		resp, err := t.transport.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		after := f.After
+		if after <= 0 || after > len(body) {
+			after = len(body) / 2
+		}
+		var tail error
+		if f.Kind == KindResetBody {
+			tail = ErrConnectionReset
+		}
+		resp.Body = &cutBody{r: bytes.NewReader(body[:after]), err: tail}
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	default:
+		closeBody(req)
+		return nil, fmt.Errorf("faultinject: unknown fault kind %q", f.Kind)
+	}
+}
+
+// closeBody closes the body of a request which is not sent, as required from a RoundTripper
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// response builds a JSON response to req
+func response(req *http.Request, code int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// cutBody returns the delivered part of a body, then err or io.EOF
+type cutBody struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (b *cutBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if errors.Is(err, io.EOF) && b.err != nil {
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *cutBody) Close() error {
+	return nil
+}
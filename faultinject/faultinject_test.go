@@ -0,0 +1,189 @@
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// okBody is the body returned by the upstream transport of the tests
+const okBody = `{"status":"ok","data":{"id":"acc"}}`
+
+// upstream answers every request with okBody and counts the requests it received
+type upstream struct {
+	sent int
+}
+
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	u.sent++
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, okBody)
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// get sends a GET request for path through t and returns the status, the body read and the error
+func get(t *Transport, path string) (int, string, error) {
+	return send(t, httptest.NewRequest(http.MethodGet, "https://api.gofile.io"+path, nil))
+}
+
+// send sends req through t and returns the status, the body read and the error
+func send(t *Transport, req *http.Request) (int, string, error) {
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+func TestScriptOrder(t *testing.T) {
+	up := &upstream{}
+	ft := New(&Options{
+		Transport: up,
+		Script:    []Fault{Status(http.StatusServiceUnavailable), None(), Reset(), GofileStatus("error-rateLimit")},
+		Rules:     []Rule{{Fault: Status(http.StatusTooManyRequests), Probability: 1}},
+	})
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	var got []result
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		status, body, err := get(ft, path)
+		got = append(got, result{status, body, err})
+	}
+
+	want := []result{
+		{http.StatusServiceUnavailable, `{"status":"error","data":{"code":503}}`, nil},
+		{http.StatusOK, okBody, nil},
+		{0, "", ErrConnectionReset},
+		{http.StatusOK, `{"status":"error-rateLimit","data":{}}`, nil},
+		{http.StatusTooManyRequests, `{"status":"error","data":{"code":429}}`, nil}, // script exhausted, rules apply
+	}
+	for i := range want {
+		if got[i].status != want[i].status || got[i].body != want[i].body || !errors.Is(got[i].err, want[i].err) {
+			t.Errorf("request %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if up.sent != 1 {
+		t.Errorf("%d requests reached the upstream transport, want only the one without a fault", up.sent)
+	}
+
+	injected := ft.Injected()
+	var paths []string
+	var kinds []Kind
+	for _, in := range injected {
+		paths = append(paths, in.Path)
+		kinds = append(kinds, in.Fault.Kind)
+	}
+	if want := []string{"/a", "/c", "/d", "/e"}; !slices.Equal(paths, want) {
+		t.Errorf("injected into %v, want %v", paths, want)
+	}
+	if want := []Kind{KindStatus, KindReset, KindGofileStatus, KindStatus}; !slices.Equal(kinds, want) {
+		t.Errorf("injected %v, want %v", kinds, want)
+	}
+}
+
+func TestRulesMatch(t *testing.T) {
+	ft := New(&Options{
+		Transport: &upstream{},
+		Rules: []Rule{
+			{Fault: Status(http.StatusBadGateway), Probability: 1, Method: http.MethodPost, PathPrefix: "/contents/uploadfile"},
+			{Fault: Status(http.StatusServiceUnavailable), Probability: 0},
+			{Fault: Status(http.StatusInternalServerError), Probability: 1, PathPrefix: "/contents"},
+		},
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "/contents/uploadfile", http.StatusBadGateway},
+		{http.MethodGet, "/contents/uploadfile", http.StatusInternalServerError},
+		{http.MethodGet, "/contents/abc", http.StatusInternalServerError},
+		{http.MethodGet, "/accounts/website", http.StatusOK},
+	}
+	for _, tt := range tests {
+		status, _, err := send(ft, httptest.NewRequest(tt.method, "https://api.gofile.io"+tt.path, nil))
+		if err != nil || status != tt.want {
+			t.Errorf("%s %s: status %d, error %v, want %d", tt.method, tt.path, status, err, tt.want)
+		}
+	}
+}
+
+func TestRulesSeed(t *testing.T) {
+	seed := uint64(42)
+	draws := func() []int {
+		ft := New(&Options{Transport: &upstream{}, Seed: &seed, Rules: []Rule{{Fault: Status(http.StatusServiceUnavailable), Probability: 0.5}}})
+		var statuses []int
+		for range 32 {
+			status, _, _ := get(ft, "/accounts")
+			statuses = append(statuses, status)
+		}
+		return statuses
+	}
+
+	first := draws()
+	if second := draws(); !slices.Equal(first, second) {
+		t.Errorf("the same seed injected %v then %v", first, second)
+	}
+	if !slices.Contains(first, http.StatusOK) || !slices.Contains(first, http.StatusServiceUnavailable) {
+		t.Errorf("a probability of 0.5 injected %v, want a mix", first)
+	}
+}
+
+func TestStatusTooManyRequests(t *testing.T) {
+	ft := New(&Options{Transport: &upstream{}, Script: []Fault{Status(http.StatusTooManyRequests)}})
+	resp, err := ft.RoundTrip(httptest.NewRequest(http.MethodGet, "https://api.gofile.io/accounts", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("429 response without Retry-After")
+	}
+}
+
+func TestCutBodies(t *testing.T) {
+	ft := New(&Options{Transport: &upstream{}, Script: []Fault{ResetBody(5), TruncatedJSON(0)}})
+
+	_, body, err := get(ft, "/accounts")
+	if body != okBody[:5] || !errors.Is(err, ErrConnectionReset) {
+		t.Errorf("reset body: read %q with %v, want %q and a connection reset", body, err, okBody[:5])
+	}
+	_, body, err = get(ft, "/accounts")
+	if body != okBody[:len(okBody)/2] || err != nil {
+		t.Errorf("truncated JSON: read %q with %v, want %q and no error", body, err, okBody[:len(okBody)/2])
+	}
+}
+
+func TestDelay(t *testing.T) {
+	ft := New(&Options{Transport: &upstream{}, Script: []Fault{Delay(20 * time.Millisecond), Delay(time.Hour)}})
+
+	start := time.Now()
+	if status, _, err := get(ft, "/accounts"); err != nil || status != http.StatusOK {
+		t.Fatalf("status %d, error %v", status, err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("delayed request answered after %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "https://api.gofile.io/accounts", nil).WithContext(ctx)
+	if _, _, err := send(ft, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cancelled delay: error %v, want the context error", err)
+	}
+}
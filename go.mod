@@ -1,3 +1,5 @@
 module github.com/plutack/go-gofile
 
 go 1.24.3
+
+require golang.org/x/net v0.50.0
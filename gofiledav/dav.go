@@ -0,0 +1,322 @@
+// package gofiledav serves a gofile account, or one of its folders, over WebDAV
+//
+// PROPFIND lists folders, GET streams downloads, PUT uploads with UploadReader, MKCOL creates
+// folders, DELETE deletes content and MOVE moves and renames content:
+//
+//	h, err := gofiledav.NewHandler(c, nil)
+//	http.ListenAndServe("localhost:8080", h)
+package gofiledav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/gofilefs"
+	"github.com/plutack/go-gofile/model"
+	"golang.org/x/net/webdav"
+)
+
+// Options defines optional configuration for the WebDAV file system.
+type Options struct {
+	FolderID string         // FolderID is the folder exposed as the WebDAV root, defaults to the account's root folder
+	Server   string         // Server is the upload server name, if empty one is picked using GetAvailableServers
+	Zone     string         // Zone is passed to GetAvailableServers when Server is empty, can be "eu" or "na"
+	Prefix   string         // Prefix is the URL path prefix stripped from WebDAV paths, see webdav.Handler
+	CacheTTL *time.Duration // CacheTTL specifies how long a folder listing is reused, see gofilefs.Options
+}
+
+// FileSystem implements webdav.FileSystem on top of a gofile folder
+type FileSystem struct {
+	api  *api.Api
+	root string
+	fsys *gofilefs.FS
+
+	mu     sync.Mutex
+	server string
+	zone   string
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// NewFileSystem creates a WebDAV file system rooted at opts.FolderID.
+//
+// If opts is nil, the account's root folder is exposed.
+// Returns the file system or an error if the root folder could not be resolved.
+func NewFileSystem(a *api.Api, opts *Options) (*FileSystem, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	root := opts.FolderID
+	if root == "" {
+		account, err := a.Account()
+		if err != nil {
+			return nil, err
+		}
+		root = account.RootFolder
+	}
+	return &FileSystem{
+		api:    a,
+		root:   root,
+		fsys:   gofilefs.New(a, root, &gofilefs.Options{CacheTTL: opts.CacheTTL}),
+		server: opts.Server,
+		zone:   opts.Zone,
+	}, nil
+}
+
+// NewHandler creates an http.Handler serving the WebDAV file system with in-memory locks.
+//
+// Returns the handler or an error if the root folder could not be resolved.
+func NewHandler(a *api.Api, opts *Options) (http.Handler, error) {
+	fsys, err := NewFileSystem(a, opts)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if opts != nil {
+		prefix = opts.Prefix
+	}
+	h := &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: fsys,
+		LockSystem: webdav.NewMemLS(),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			// webdav closes the file even when copying the body failed, the writer checks the body to not commit a partial upload
+			body := &putBody{ReadCloser: r.Body, length: r.ContentLength}
+			r = r.WithContext(context.WithValue(r.Context(), putBodyKey{}, body))
+			r.Body = body
+		}
+		h.ServeHTTP(w, r)
+	}), nil
+}
+
+// putBodyKey is the context key of the putBody of a PUT request
+type putBodyKey struct{}
+
+// putBody records how reading the body of a PUT request went
+type putBody struct {
+	io.ReadCloser
+	length int64 // Content-Length of the request, -1 if unknown
+
+	mu   sync.Mutex
+	read int64
+	err  error // first read error other than io.EOF
+}
+
+func (b *putBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	b.mu.Unlock()
+	return n, err
+}
+
+// complete returns an error if the body was not read in full
+func (b *putBody) complete() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	if b.length >= 0 && b.read != b.length {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// fsName converts a WebDAV path ("/a/b") into an io/fs path ("a/b")
+func fsName(name string) string {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// content returns the gofile content at name
+func (d *FileSystem) content(name string) (model.Content, error) {
+	fi, err := d.fsys.Stat(fsName(name))
+	if err != nil {
+		return model.Content{}, err
+	}
+	return fi.Sys().(model.Content), nil
+}
+
+// folder returns the gofile folder at name
+func (d *FileSystem) folder(op string, name string) (model.Content, error) {
+	c, err := d.content(name)
+	if err != nil {
+		return model.Content{}, err
+	}
+	if c.Type != model.FolderType {
+		return model.Content{}, &fs.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+	}
+	return c, nil
+}
+
+// uploadServer returns the configured upload server or picks one
+func (d *FileSystem) uploadServer() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.server != "" {
+		return d.server, nil
+	}
+	resp, err := d.api.GetAvailableServers(d.zone)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Data.Servers) == 0 {
+		return "", errors.New("no upload server available")
+	}
+	d.server = resp.Data.Servers[0].Name
+	return d.server, nil
+}
+
+// Mkdir creates a folder, its parent must exist
+func (d *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if _, err := d.content(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	parent, err := d.folder("mkdir", path.Dir(fsName(name)))
+	if err != nil {
+		return err
+	}
+	defer d.fsys.Invalidate()
+	_, err = d.api.CreateFolder(parent.ID, path.Base(fsName(name)))
+	return err
+}
+
+// OpenFile opens a file or folder for reading, or starts an upload when flag has both os.O_CREATE and os.O_TRUNC.
+//
+// Other write flags open existing content for reading only, webdav opens files with os.O_RDWR for PROPPATCH
+// without writing to them.
+func (d *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_CREATE|os.O_TRUNC) == os.O_CREATE|os.O_TRUNC {
+		return d.create(ctx, name)
+	}
+	f, err := d.fsys.Open(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, name: name}, nil
+}
+
+// create starts streaming a new file to gofile, an existing file with the same name is replaced once the upload succeeds.
+//
+// When ctx comes from a PUT request served by NewHandler, the upload is aborted if the request body was not read in full.
+func (d *FileSystem) create(ctx context.Context, name string) (webdav.File, error) {
+	existing, err := d.content(name)
+	switch {
+	case err == nil && existing.Type == model.FolderType:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	parent, err := d.folder("open", path.Dir(fsName(name)))
+	if err != nil {
+		return nil, err
+	}
+	server, err := d.uploadServer()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := &writer{
+		name: name,
+		pw:   pw,
+		done: make(chan uploadResult, 1),
+		fsys: d,
+	}
+	if existing.ID != "" {
+		w.replaces = existing.ID
+	}
+	if body, ok := ctx.Value(putBodyKey{}).(*putBody); ok {
+		w.body = body
+	}
+	go func() {
+		resp, err := d.api.UploadReader(server, path.Base(fsName(name)), pr, -1, parent.ID, nil)
+		// unblock writes if the upload stopped reading early
+		pr.CloseWithError(errors.Join(err, io.ErrClosedPipe))
+		w.done <- uploadResult{resp: resp, err: err}
+	}()
+	return w, nil
+}
+
+// RemoveAll deletes the file or folder at name
+func (d *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	c, err := d.content(name)
+	if err != nil {
+		return err
+	}
+	if c.ID == d.root {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	defer d.fsys.Invalidate()
+	_, err = d.api.DeleteContent(c.ID)
+	return err
+}
+
+// Rename moves the content at oldName into the folder of newName and renames it if needed.
+//
+// gofile moves and renames with separate requests. If the rename fails after a move, the content
+// is moved back to its folder. Should that fail too, the content is left in the new folder under
+// its old name and the returned error says so.
+func (d *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	c, err := d.content(oldName)
+	if err != nil {
+		return err
+	}
+	if c.ID == d.root {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrPermission}
+	}
+	parent, err := d.folder("rename", path.Dir(fsName(newName)))
+	if err != nil {
+		return err
+	}
+	defer d.fsys.Invalidate()
+
+	moved := parent.ID != c.ParentFolder
+	if moved {
+		if _, err := d.api.MoveContent(parent.ID, c.ID); err != nil {
+			return err
+		}
+	}
+	base := path.Base(fsName(newName))
+	if base == c.Name {
+		return nil
+	}
+	_, err = d.api.Update(c.ID, model.SetName(base))
+	if err == nil || !moved {
+		return err
+	}
+	if _, undoErr := d.api.MoveContent(c.ParentFolder, c.ID); undoErr != nil {
+		return fmt.Errorf("rename %s failed and moving it back failed too, it is left in %s as %s: %w",
+			oldName, path.Dir(fsName(newName)), c.Name, errors.Join(err, undoErr))
+	}
+	return err
+}
+
+// Stat returns a FileInfo describing the content at name
+func (d *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	fi, err := d.fsys.Stat(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{fi}, nil
+}
@@ -0,0 +1,244 @@
+package gofiledav
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/cassette"
+)
+
+// requestLog records the method and path of every request sent through it
+type requestLog struct {
+	rt http.RoundTripper
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (l *requestLog) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	l.sent = append(l.sent, req.Method+" "+req.URL.Path)
+	l.mu.Unlock()
+	return l.rt.RoundTrip(req)
+}
+
+// has reports whether a request with method and path was sent
+func (l *requestLog) has(method string, path string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.sent, method+" "+path)
+}
+
+// interaction returns a recorded gofile response with status "ok" and data
+func interaction(method string, path string, data any) cassette.Interaction {
+	body, _ := json.Marshal(map[string]any{"status": "ok", "data": data})
+	return cassette.Interaction{
+		Request: cassette.Request{Method: method, URL: "https://api.gofile.io" + path},
+		Response: cassette.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       body,
+		},
+	}
+}
+
+// matchPath matches interactions on method and URL path only, uploads have a random multipart body
+func matchPath(req *http.Request, body []byte, i cassette.Interaction) bool {
+	u, err := url.Parse(i.Request.URL)
+	return err == nil && req.Method == i.Request.Method && req.URL.Path == u.Path
+}
+
+// replay returns a WebDAV handler over a gofile account answering from interactions, each answering a single request
+func replay(t *testing.T, interactions ...cassette.Interaction) (http.Handler, *requestLog) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	data, err := json.Marshal(interactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := cassette.New(path, cassette.ModeReplay, &cassette.Options{Match: matchPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &requestLog{rt: rec}
+	token, retries := "token", 0
+	a := api.New(&api.Options{APIToken: &token, RetryCount: &retries, Transport: log})
+	h, err := NewHandler(a, &Options{FolderID: "root", Server: "store1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, log
+}
+
+// rootListing returns the listing of a root folder holding the file a.txt
+func rootListing() cassette.Interaction {
+	return interaction(http.MethodGet, "/contents/root", map[string]any{
+		"id": "root", "type": "folder", "name": "root",
+		"children": map[string]any{
+			"f1": map[string]any{"id": "f1", "type": "file", "name": "a.txt", "size": 5, "md5": "5d41402abc4b2a76b9719d911017c592", "parentFolder": "root"},
+		},
+	})
+}
+
+// serve sends r to h and returns the recorded response
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestProppatchDoesNotUpload(t *testing.T) {
+	h, log := replay(t, rootListing(), rootListing())
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:example">
+  <D:set><D:prop><Z:color>blue</Z:color></D:prop></D:set>
+</D:propertyupdate>`
+	w := serve(h, httptest.NewRequest("PROPPATCH", "/a.txt", strings.NewReader(body)))
+	if w.Code != http.StatusMultiStatus {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+	if log.has(http.MethodPost, "/contents/uploadfile") || log.has(http.MethodDelete, "/contents") {
+		t.Error("PROPPATCH uploaded or deleted the file")
+	}
+}
+
+func TestPutReplaces(t *testing.T) {
+	h, log := replay(t,
+		rootListing(),
+		interaction(http.MethodPost, "/contents/uploadfile", map[string]any{
+			"id": "f2", "type": "file", "name": "a.txt", "size": 11, "parentFolder": "root",
+		}),
+		interaction(http.MethodDelete, "/contents", map[string]any{"f1": map[string]any{"status": "ok"}}),
+		rootListing(),
+	)
+
+	w := serve(h, httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("hello world")))
+	if w.Code >= 300 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if !log.has(http.MethodDelete, "/contents") {
+		t.Error("the replaced file was not deleted")
+	}
+}
+
+func TestPutAborted(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   io.Reader
+		length int64
+	}{
+		{"short body", strings.NewReader("hello"), 11},
+		{"read error", io.MultiReader(strings.NewReader("hello"), iotest.ErrReader(errors.New("connection reset"))), -1},
+	}
+	for _, tt := range tests {
+		// the upload would succeed if it was committed, only the body check keeps the file
+		h, log := replay(t,
+			rootListing(),
+			interaction(http.MethodPost, "/contents/uploadfile", map[string]any{
+				"id": "f2", "type": "file", "name": "a.txt", "size": 5, "parentFolder": "root",
+			}),
+			interaction(http.MethodDelete, "/contents", map[string]any{"f1": map[string]any{"status": "ok"}}),
+			rootListing(),
+		)
+		r := httptest.NewRequest(http.MethodPut, "/a.txt", tt.body)
+		r.ContentLength = tt.length
+
+		w := serve(h, r)
+		if w.Code < 400 {
+			t.Errorf("%s: status %d, want an error", tt.name, w.Code)
+		}
+		if log.has(http.MethodDelete, "/contents") {
+			t.Errorf("%s: the existing file was deleted by an incomplete upload", tt.name)
+		}
+	}
+}
+
+// failed returns a recorded gofile response with the error status
+func failed(method string, path string, status string) cassette.Interaction {
+	i := interaction(method, path, nil)
+	i.Response.Body, _ = json.Marshal(map[string]any{"status": status, "data": map[string]any{}})
+	return i
+}
+
+// treeListing returns the listing of a root folder holding the file a.txt and the folder sub
+func treeListing() []cassette.Interaction {
+	return []cassette.Interaction{
+		interaction(http.MethodGet, "/contents/root", map[string]any{
+			"id": "root", "type": "folder", "name": "root",
+			"children": map[string]any{
+				"f1":  map[string]any{"id": "f1", "type": "file", "name": "a.txt", "size": 5, "parentFolder": "root"},
+				"sub": map[string]any{"id": "sub", "type": "folder", "name": "sub", "parentFolder": "root"},
+			},
+		}),
+		interaction(http.MethodGet, "/contents/sub", map[string]any{
+			"id": "sub", "type": "folder", "name": "sub", "parentFolder": "root", "children": map[string]any{},
+		}),
+	}
+}
+
+// move returns a MOVE request of src to dst
+func move(src string, dst string) *http.Request {
+	r := httptest.NewRequest("MOVE", src, nil)
+	r.Header.Set("Destination", dst)
+	return r
+}
+
+// count returns the number of requests sent with method and path
+func (l *requestLog) count(method string, path string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, s := range l.sent {
+		if s == method+" "+path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMoveAndRename(t *testing.T) {
+	h, log := replay(t, append(treeListing(),
+		interaction(http.MethodPut, "/contents/move", nil),
+		interaction(http.MethodPut, "/contents/f1/update", map[string]any{"id": "f1", "type": "file", "name": "b.txt"}),
+	)...)
+
+	w := serve(h, move("/a.txt", "/sub/b.txt"))
+	if w.Code >= 300 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if log.count(http.MethodPut, "/contents/move") != 1 || !log.has(http.MethodPut, "/contents/f1/update") {
+		t.Errorf("sent %v, want a move and a rename", log.sent)
+	}
+}
+
+func TestMoveRolledBackWhenRenameFails(t *testing.T) {
+	h, log := replay(t, append(treeListing(),
+		interaction(http.MethodPut, "/contents/move", nil),
+		failed(http.MethodPut, "/contents/f1/update", "error-rateLimit"),
+		interaction(http.MethodPut, "/contents/move", nil),
+	)...)
+
+	w := serve(h, move("/a.txt", "/sub/b.txt"))
+	if w.Code < 400 {
+		t.Errorf("status %d, want an error", w.Code)
+	}
+	if n := log.count(http.MethodPut, "/contents/move"); n != 2 {
+		t.Errorf("%d moves sent, want the move and the move back", n)
+	}
+}
@@ -0,0 +1,167 @@
+package gofiledav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+	"golang.org/x/net/webdav"
+)
+
+// fileInfo adds the MIME type and MD5 gofile already knows to a gofilefs FileInfo,
+// so WebDAV listings do not have to download files to compute them
+type fileInfo struct {
+	fs.FileInfo
+}
+
+// ContentType returns the MIME type reported by gofile
+func (i fileInfo) ContentType(ctx context.Context) (string, error) {
+	c, ok := i.Sys().(model.Content)
+	if !ok || c.MimeType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return c.MimeType, nil
+}
+
+// ETag returns the MD5 of the file reported by gofile
+func (i fileInfo) ETag(ctx context.Context) (string, error) {
+	c, ok := i.Sys().(model.Content)
+	if !ok || c.MD5 == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + c.MD5 + `"`, nil
+}
+
+// file is a file or folder opened for reading
+type file struct {
+	fs.File
+	name string
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	s, ok := f.File.(io.Seeker)
+	if !ok {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return s.Seek(offset, whence)
+}
+
+func (f *file) Readdir(count int) ([]fs.FileInfo, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	entries, err := d.ReadDir(count)
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, fileInfo{fi})
+	}
+	return infos, err
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{fi}, nil
+}
+
+func (f *file) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+}
+
+// uploadResult is the outcome of the upload behind a writer
+type uploadResult struct {
+	resp model.UploadFileResponse
+	err  error
+}
+
+// writer streams what is written to it into an upload started by FileSystem.create
+type writer struct {
+	name     string
+	pw       *io.PipeWriter
+	done     chan uploadResult
+	fsys     *FileSystem
+	replaces string   // ID of the file replaced by this upload, empty if none
+	body     *putBody // body of the PUT request feeding the writer, nil if unknown
+	written  int64
+	closed   bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.pw.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Close finishes the upload and deletes the replaced file once the new one is stored.
+//
+// If the PUT request body was not read in full, the upload is aborted and the replaced file kept.
+func (w *writer) Close() error {
+	if w.closed {
+		return &fs.PathError{Op: "close", Path: w.name, Err: fs.ErrClosed}
+	}
+	w.closed = true
+	if w.body != nil {
+		if err := w.body.complete(); err != nil {
+			err = &fs.PathError{Op: "close", Path: w.name, Err: fmt.Errorf("upload aborted after %d bytes: %w", w.written, err)}
+			w.pw.CloseWithError(err)
+			<-w.done
+			w.fsys.fsys.Invalidate()
+			return err
+		}
+	}
+	w.pw.Close()
+	res := <-w.done
+	defer w.fsys.fsys.Invalidate()
+	if res.err != nil {
+		return res.err
+	}
+	if w.replaces != "" {
+		if _, err := w.fsys.api.DeleteContent(w.replaces); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writer) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: w.name, Err: fs.ErrPermission}
+}
+
+func (w *writer) Seek(offset int64, whence int) (int64, error) {
+	// webdav seeks to the start of new files before copying, anything else is unsupported
+	if offset == 0 && whence == io.SeekStart && w.written == 0 {
+		return 0, nil
+	}
+	return 0, &fs.PathError{Op: "seek", Path: w.name, Err: fs.ErrInvalid}
+}
+
+func (w *writer) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: w.name, Err: fs.ErrInvalid}
+}
+
+func (w *writer) Stat() (fs.FileInfo, error) {
+	return uploadInfo{name: w.name, size: w.written}, nil
+}
+
+// uploadInfo describes a file being uploaded
+type uploadInfo struct {
+	name string
+	size int64
+}
+
+func (i uploadInfo) Name() string       { return i.name }
+func (i uploadInfo) Size() int64        { return i.size }
+func (i uploadInfo) Mode() fs.FileMode  { return 0o644 }
+func (i uploadInfo) ModTime() time.Time { return time.Now() }
+func (i uploadInfo) IsDir() bool        { return false }
+func (i uploadInfo) Sys() any           { return nil }
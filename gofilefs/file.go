@@ -0,0 +1,183 @@
+package gofilefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// fileInfo describes a gofile content as an fs.FileInfo
+type fileInfo struct {
+	name string // name is the base name in the file system, which differs from c.Name for the root and duplicate names
+	c    model.Content
+}
+
+func (i fileInfo) Name() string {
+	return i.name
+}
+
+func (i fileInfo) Size() int64 {
+	if i.IsDir() {
+		return 0
+	}
+	return i.c.Size
+}
+
+func (i fileInfo) Mode() fs.FileMode {
+	if i.IsDir() {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i fileInfo) ModTime() time.Time {
+	if !i.c.ModTime.IsZero() {
+		return i.c.ModTime.Time
+	}
+	return i.c.CreateTime.Time
+}
+
+func (i fileInfo) IsDir() bool {
+	return i.c.Type == model.FolderType
+}
+
+// Sys returns the underlying model.Content
+func (i fileInfo) Sys() any {
+	return i.c
+}
+
+// file is an open remote file.
+//
+// The download is only started on the first Read, and restarted from the new offset after a Seek.
+type file struct {
+	fsys   *FS
+	name   string
+	info   fileInfo
+	offset int64
+	body   io.ReadCloser // current download, nil until read or after a seek
+	closed bool
+}
+
+var _ io.ReadSeekCloser = (*file)(nil)
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// link returns the download link of the file, fetching it if the listing did not include it
+func (f *file) link() (string, error) {
+	if f.info.c.Link != "" {
+		return f.info.c.Link, nil
+	}
+	resp, err := f.fsys.api.GetContent(f.info.c.ID)
+	if err != nil {
+		return "", err
+	}
+	if resp.Data.Link == "" {
+		return "", errors.New("no download link")
+	}
+	f.info.c.Link = resp.Data.Link
+	return f.info.c.Link, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		link, err := f.link()
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		body, err := f.fsys.api.DownloadFrom(link, f.offset)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.offset + offset
+	case io.SeekEnd:
+		abs = f.info.Size() + offset
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if abs < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if abs != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = abs
+	return abs, nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// dir is an open remote directory
+type dir struct {
+	name    string // name is the path the directory was opened with
+	info    fileInfo
+	entries []entry
+	offset  int
+}
+
+var _ fs.ReadDirFile = (*dir)(nil)
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries, or all remaining entries if n <= 0
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n > 0 {
+		if len(remaining) == 0 {
+			return nil, io.EOF
+		}
+		remaining = remaining[:min(n, len(remaining))]
+	}
+	d.offset += len(remaining)
+	entries := make([]fs.DirEntry, 0, len(remaining))
+	for _, e := range remaining {
+		entries = append(entries, fs.FileInfoToDirEntry(e.info()))
+	}
+	return entries, nil
+}
@@ -0,0 +1,234 @@
+// package gofilefs exposes a gofile folder as an io/fs.FS
+//
+// Directory listings come from the contents endpoint and are cached, file reads are streamed
+// from gofile's download servers. This lets fs.WalkDir, http.FS and template.ParseFS work on
+// remote content:
+//
+//	fsys := gofilefs.New(c, folderID, nil)
+//	http.Handle("/", http.FileServer(http.FS(fsys)))
+package gofilefs
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
+)
+
+// defaultCacheTTL is how long a directory listing is reused when Options.CacheTTL is nil
+const defaultCacheTTL = time.Minute
+
+// Options defines optional configuration for an FS.
+type Options struct {
+	CacheTTL *time.Duration // CacheTTL specifies how long a directory listing is reused, defaults to 1 minute
+}
+
+// FS is a read-only file system rooted at a gofile folder.
+//
+// It implements fs.FS, fs.ReadDirFS and fs.StatFS.
+type FS struct {
+	api  *api.Api
+	root string
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]listing // folder ID to its cached listing
+}
+
+// listing is a cached folder listing
+type listing struct {
+	folder  model.Content
+	fetched time.Time
+}
+
+var (
+	_ fs.FS        = (*FS)(nil)
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New creates a file system rooted at the folder with the specified folderID.
+//
+// If opts is nil, default settings are used.
+func New(a *api.Api, folderID string, opts *Options) *FS {
+	ttl := defaultCacheTTL
+	if opts != nil && opts.CacheTTL != nil {
+		ttl = *opts.CacheTTL
+	}
+	return &FS{
+		api:   a,
+		root:  folderID,
+		ttl:   ttl,
+		cache: make(map[string]listing),
+	}
+}
+
+// Invalidate drops every cached listing so the next access sees remote changes
+func (f *FS) Invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	clear(f.cache)
+}
+
+// list returns the folder with the specified folderID along with its children, using the cache when fresh
+func (f *FS) list(folderID string) (model.Content, error) {
+	f.mu.Lock()
+	l, ok := f.cache[folderID]
+	f.mu.Unlock()
+	if ok && time.Since(l.fetched) < f.ttl {
+		return l.folder, nil
+	}
+
+	resp, err := f.api.GetContent(folderID)
+	if err != nil {
+		return model.Content{}, err
+	}
+	f.mu.Lock()
+	f.cache[folderID] = listing{folder: resp.Data, fetched: time.Now()}
+	f.mu.Unlock()
+	return resp.Data, nil
+}
+
+// entry is a child of a folder under the name it has in the file system
+type entry struct {
+	name string
+	c    model.Content
+}
+
+// info describes e as an fs.FileInfo
+func (e entry) info() fileInfo {
+	return fileInfo{name: e.name, c: e.c}
+}
+
+// entries returns the children of a folder sorted by name.
+//
+// gofile allows several children with the same name: the one with the smallest ID keeps it and
+// the others get a " (n)" suffix before their extension. Unlike api.DownloadFolder, which compares
+// names case-insensitively for case-insensitive disks, names differing only in case are distinct
+// paths here and keep their names, so "A.txt" and "a.txt" are not renamed.
+// Children whose name is not a valid path element (empty, ".", ".." or holding '/') are left out
+// since no path could open them.
+func entries(folder model.Content) []entry {
+	list := make([]model.Content, 0, len(folder.Children))
+	used := make(map[string]bool)
+	for _, c := range folder.Children {
+		if c.Name == "." || !fs.ValidPath(c.Name) || strings.Contains(c.Name, "/") {
+			continue
+		}
+		list = append(list, c)
+		used[c.Name] = true
+	}
+	slices.SortFunc(list, func(a, b model.Content) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.ID, b.ID))
+	})
+
+	named := make([]entry, 0, len(list))
+	for i, c := range list {
+		name := c.Name
+		if i > 0 && list[i-1].Name == c.Name {
+			ext := path.Ext(c.Name)
+			for n := 2; used[name]; n++ {
+				name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(c.Name, ext), n, ext)
+			}
+			used[name] = true
+		}
+		named = append(named, entry{name: name, c: c})
+	}
+	slices.SortFunc(named, func(a, b entry) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return named
+}
+
+// resolve walks name from the root and returns the entry it points to, the root is named "."
+func (f *FS) resolve(op string, name string) (entry, error) {
+	if !fs.ValidPath(name) {
+		return entry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	root, err := f.list(f.root)
+	if err != nil {
+		return entry{}, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	current := entry{name: ".", c: root}
+	if name == "." {
+		return current, nil
+	}
+
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		if current.c.Type != model.FolderType {
+			return entry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		list := entries(current.c)
+		idx := slices.IndexFunc(list, func(e entry) bool {
+			return e.name == part
+		})
+		if idx < 0 {
+			return entry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		next := list[idx]
+		if next.c.Type == model.FolderType && i < len(parts)-1 {
+			next.c, err = f.list(next.c.ID)
+			if err != nil {
+				return entry{}, &fs.PathError{Op: op, Path: name, Err: err}
+			}
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// Open opens the named file or directory.
+//
+// Files are streamed from gofile when read, directories implement fs.ReadDirFile.
+func (f *FS) Open(name string) (fs.File, error) {
+	e, err := f.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.c.Type == model.FolderType {
+		folder, err := f.list(e.c.ID)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &dir{name: name, info: fileInfo{name: e.name, c: folder}, entries: entries(folder)}, nil
+	}
+	return &file{fsys: f, name: name, info: e.info()}, nil
+}
+
+// Stat returns a FileInfo describing the named file or directory
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	e, err := f.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info(), nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := f.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.c.Type != model.FolderType {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	folder, err := f.list(e.c.ID)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	list := entries(folder)
+	dirEntries := make([]fs.DirEntry, 0, len(list))
+	for _, child := range list {
+		dirEntries = append(dirEntries, fs.FileInfoToDirEntry(child.info()))
+	}
+	return dirEntries, nil
+}
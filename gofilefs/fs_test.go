@@ -0,0 +1,182 @@
+package gofilefs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/cassette"
+	"github.com/plutack/go-gofile/model"
+)
+
+// interaction returns a recorded gofile response with status "ok" and data
+func interaction(method string, path string, data any) cassette.Interaction {
+	body, _ := json.Marshal(map[string]any{"status": "ok", "data": data})
+	return cassette.Interaction{
+		Request: cassette.Request{Method: method, URL: "https://api.gofile.io" + path},
+		Response: cassette.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       body,
+		},
+	}
+}
+
+// download returns a recorded download of body from the file server path
+func download(path string, body string) cassette.Interaction {
+	return cassette.Interaction{
+		Request: cassette.Request{Method: http.MethodGet, URL: "https://store1.gofile.io" + path},
+		Response: cassette.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       cassette.Body(body),
+		},
+	}
+}
+
+// matchPath matches interactions on method and URL path only
+func matchPath(req *http.Request, body []byte, i cassette.Interaction) bool {
+	u, err := url.Parse(i.Request.URL)
+	return err == nil && req.Method == i.Request.Method && req.URL.Path == u.Path
+}
+
+// fileListing returns the listing of a file holding body
+func fileListing(id string, name string, body string) map[string]any {
+	return map[string]any{
+		"id": id, "type": "file", "name": name, "size": len(body), "parentFolder": "root",
+		"createTime": 1714566600, "link": "https://store1.gofile.io/download/" + id + "/" + name,
+	}
+}
+
+// replayFS returns an FS over a root folder holding two files named a.txt, a file b.txt,
+// a file with a name no path can open and a folder sub holding c.txt
+func replayFS(t *testing.T) *FS {
+	t.Helper()
+	bodies := map[string]string{"a1": "first a", "a2": "second a", "b": "bee", "c": "nested file"}
+	interactions := []cassette.Interaction{
+		interaction(http.MethodGet, "/contents/root", map[string]any{
+			"id": "root", "type": "folder", "name": "My Root",
+			"children": map[string]any{
+				"a2":  fileListing("a2", "a.txt", bodies["a2"]),
+				"a1":  fileListing("a1", "a.txt", bodies["a1"]),
+				"b":   fileListing("b", "b.txt", bodies["b"]),
+				"bad": fileListing("bad", "..", ""),
+				"sub": map[string]any{"id": "sub", "type": "folder", "name": "sub", "parentFolder": "root"},
+			},
+		}),
+		interaction(http.MethodGet, "/contents/sub", map[string]any{
+			"id": "sub", "type": "folder", "name": "sub", "parentFolder": "root",
+			"children": map[string]any{"c": fileListing("c", "c.txt", bodies["c"])},
+		}),
+	}
+	// every open of a file downloads it again, fstest opens each file several times
+	names := map[string]string{"a1": "a.txt", "a2": "a.txt", "b": "b.txt", "c": "c.txt"}
+	for range 20 {
+		for id, body := range bodies {
+			interactions = append(interactions, download("/download/"+id+"/"+names[id], body))
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	data, err := json.Marshal(interactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := cassette.New(path, cassette.ModeReplay, &cassette.Options{Match: matchPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, retries := "token", 0
+	return New(api.New(&api.Options{APIToken: &token, RetryCount: &retries, Transport: rec}), "root", nil)
+}
+
+func TestFS(t *testing.T) {
+	if err := fstest.TestFS(replayFS(t), "a.txt", "a (2).txt", "b.txt", "sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRootStat(t *testing.T) {
+	fi, err := replayFS(t).Stat(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "." || !fi.IsDir() {
+		t.Errorf("root is named %q, want \".\"", fi.Name())
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	fsys := replayFS(t)
+	entries, err := fsys.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"a (2).txt", "a.txt", "b.txt", "sub"}; !slices.Equal(names, want) {
+		t.Errorf("root lists %q, want %q", names, want)
+	}
+
+	// the file with the smallest ID keeps the name
+	for name, want := range map[string]string{"a.txt": "first a", "a (2).txt": "second a"} {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil || string(data) != want {
+			t.Errorf("%s holds %q, %v, want %q", name, data, err, want)
+		}
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	fsys := replayFS(t)
+	for _, name := range []string{"missing.txt", "b.txt/x", "sub/missing", "../b.txt", "/b.txt"} {
+		_, err := fsys.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q) = %v, want a not exist or invalid error", name, err)
+		}
+	}
+}
+
+func TestSeekRestartsDownload(t *testing.T) {
+	f, err := replayFS(t).Open("sub/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s := f.(io.ReadSeeker)
+	if _, err := s.Seek(7, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(s)
+	if err != nil || string(data) != "file" {
+		t.Errorf("read %q, %v after seeking, want \"file\"", data, err)
+	}
+}
+
+func TestEntriesKeepCaseDistinctNames(t *testing.T) {
+	folder := model.Content{Type: model.FolderType, Children: map[string]model.Content{
+		"x": {ID: "x", Type: model.FileType, Name: "A.txt"},
+		"y": {ID: "y", Type: model.FileType, Name: "a.txt"},
+	}}
+	var names []string
+	for _, e := range entries(folder) {
+		names = append(names, e.name)
+	}
+	if want := []string{"A.txt", "a.txt"}; !slices.Equal(names, want) {
+		t.Errorf("names %v, want %v", names, want)
+	}
+}
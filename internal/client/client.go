@@ -3,17 +3,25 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/plutack/go-gofile/metrics"
 	"github.com/plutack/go-gofile/model"
+	"github.com/plutack/go-gofile/tracing"
 )
 
 // HTTP request methods for API interactions
@@ -29,10 +37,14 @@ var baseUrl = "https://api.gofile.io"
 
 // clientConfig contains necessary configuration options to configure a client
 type ClientConfig struct {
-	APIToken   string        // APIToken is the authentication token for the GoFile.io API
-	BaseUrl    string        //BaseUrl is the base url for API request apart from uploadFile API call
-	RetryCount int           // RetryCount specifies the number of times to retry failed API requests
-	Timeout    time.Duration // Timeout specifies the maximum time to wait for an API Request to be resolved
+	APIToken   string            // APIToken is the authentication token for the GoFile.io API
+	BaseUrl    string            //BaseUrl is the base url for API request apart from uploadFile API call
+	RetryCount int               // RetryCount specifies the number of times to retry failed API requests
+	Timeout    time.Duration     // Timeout specifies the maximum time to wait for an API Request to be resolved
+	Metrics    metrics.Recorder  // Metrics receives retries and transfer measurements, nothing is recorded if nil
+	Tracer     tracing.Tracer    // Tracer receives a "gofile.http" span per request, nothing is traced if nil
+	Transport  http.RoundTripper // Transport sends every request including uploads and downloads, http.DefaultTransport is used if nil
+	Context    context.Context   // Context aborts requests and retry waits once it is done, context.Background is used if nil
 }
 
 // ProgressCallback represents a function that receives progress updates.
@@ -41,8 +53,13 @@ type ProgressCallback = func(done int64, total int64)
 
 // Client represents an HTTP client for interacting with the GoFile.io API
 type Client struct {
-	httpClient *http.Client // httpClient is the underlying HTTP client used for API requests
-	config     ClientConfig // config holds the configuration settings for the API client
+	httpClient     *http.Client    // httpClient is the underlying HTTP client used for API requests
+	transferClient *http.Client    // transferClient is used for uploads and downloads which must not time out
+	config         ClientConfig    // config holds the configuration settings for the API client
+	ctx            context.Context // ctx bounds every request and retry wait
+	tokenMu        sync.RWMutex    // tokenMu guards config.APIToken which can change after creation
+	metrics        metrics.Recorder
+	tracer         tracing.Tracer
 }
 
 // progressReader wraps an io.Reader and reports progress as bytes are read.
@@ -61,7 +78,9 @@ func (p *progressReader) Read(buf []byte) (int, error) {
 	n, err := p.Reader.Read(buf)
 	if n > 0 {
 		p.total += int64(n)
-		p.onRead(p.total, p.size)
+		if p.onRead != nil {
+			p.onRead(p.total, p.size)
+		}
 	}
 	return n, err
 }
@@ -87,17 +106,210 @@ func NewDefaultClientConfig() ClientConfig {
 
 // NewClient creates a new Client with the provided configuration
 // It initializes an HTTP client with the specified timeout
+// Uploads and downloads use a separate HTTP client without timeout since they can take arbitrarily long
 func NewClient(c ClientConfig) *Client {
+	ctx := context.Background()
+	if c.Context != nil {
+		ctx = c.Context
+	}
+	var m metrics.Recorder = metrics.Nop{}
+	if c.Metrics != nil {
+		m = c.Metrics
+	}
+	var t tracing.Tracer = tracing.Nop{}
+	if c.Tracer != nil {
+		t = c.Tracer
+	}
 	return &Client{
-		config: c,
+		config:  c,
+		ctx:     ctx,
+		metrics: m,
+		tracer:  t,
 		httpClient: &http.Client{
-			Timeout: c.Timeout,
+			Timeout:   c.Timeout,
+			Transport: c.Transport,
+		},
+		transferClient: &http.Client{
+			Transport: c.Transport,
+		},
+	}
+}
+
+// isRetryable reports whether a request with the given method may be sent again after it failed
+// Only idempotent methods are retried so a retry can never create content twice
+func isRetryable(method string) bool {
+	return method == getMethod || method == putMethod || method == deleteMethod
+}
+
+// shouldRetry reports whether the outcome of an attempt is worth retrying
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// do sends an authenticated request to the API.
+// If body is not nil it is encoded as JSON.
+// Idempotent requests are retried up to RetryCount times on network errors, 429 and 5xx responses.
+// Returns the HTTP response or an error
+func (c *Client) do(method string, u string, body any) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal payload failed: %w", err)
+		}
+	}
+
+	attempts := 1
+	if isRetryable(method) && c.config.RetryCount > 0 {
+		attempts += c.config.RetryCount
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(retryDelay(attempt)); err != nil {
+				return nil, err
+			}
+		}
+		var req *http.Request
+		req, err = http.NewRequestWithContext(c.ctx, method, u, bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("create request failed: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		setAuthorizationHeader(req, c.APIToken())
+
+		var span tracing.Span
+		req, span = c.trace(req, tracing.Int("attempt", int64(attempt)))
+		resp, err = c.httpClient.Do(req)
+		endSpan(span, resp, err)
+		if !shouldRetry(resp, err) || attempt == attempts-1 {
+			break
+		}
+		c.metrics.IncRetry(method, retryReason(resp, err))
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// trace starts a "gofile.http" span for req and returns req with httptrace hooks adding events to it
+func (c *Client) trace(req *http.Request, attrs ...tracing.Attribute) (*http.Request, tracing.Span) {
+	attrs = append([]tracing.Attribute{
+		tracing.String("http.method", req.Method),
+		tracing.String("http.host", req.URL.Host),
+		tracing.String("http.path", req.URL.Path),
+	}, attrs...)
+	span := c.tracer.Start("gofile.http", attrs...)
+	if _, ok := c.tracer.(tracing.Nop); ok {
+		return req, span
+	}
+
+	ct := &httptrace.ClientTrace{
+		DNSStart: func(i httptrace.DNSStartInfo) {
+			span.Event("dns.start", tracing.String("host", i.Host))
+		},
+		DNSDone: func(i httptrace.DNSDoneInfo) {
+			span.Event("dns.done", errAttrs(i.Err)...)
+		},
+		ConnectStart: func(network string, addr string) {
+			span.Event("connect.start", tracing.String("addr", addr))
+		},
+		ConnectDone: func(network string, addr string, err error) {
+			span.Event("connect.done", append(errAttrs(err), tracing.String("addr", addr))...)
+		},
+		TLSHandshakeStart: func() {
+			span.Event("tls.start")
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			span.Event("tls.done", errAttrs(err)...)
+		},
+		GotConn: func(i httptrace.GotConnInfo) {
+			span.Event("conn.acquired", tracing.Bool("reused", i.Reused), tracing.Bool("idle", i.WasIdle))
+		},
+		WroteHeaders: func() {
+			span.Event("request.headers_written")
+		},
+		WroteRequest: func(i httptrace.WroteRequestInfo) {
+			span.Event("request.written", errAttrs(i.Err)...)
 		},
+		GotFirstResponseByte: func() {
+			span.Event("response.first_byte")
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), ct)), span
+}
+
+// errAttrs returns an error attribute for err, or none if it is nil
+func errAttrs(err error) []tracing.Attribute {
+	if err == nil {
+		return nil
+	}
+	return []tracing.Attribute{tracing.String("error", err.Error())}
+}
+
+// endSpan records the response status on span and ends it
+func endSpan(span tracing.Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttributes(tracing.Int("http.status_code", int64(resp.StatusCode)))
+	}
+	span.End(err)
+}
+
+// retryReason describes why a failed attempt is retried, see metrics.Recorder.IncRetry
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		return "network"
 	}
+	return strconv.Itoa(resp.StatusCode)
+}
+
+// wait blocks for d or until the client context is done, in which case its error is returned
+func (c *Client) wait(d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+// retryDelay returns how long to wait before the given retry attempt
+func retryDelay(attempt int) time.Duration {
+	return time.Duration(1<<(attempt-1)) * 500 * time.Millisecond
+}
+
+// APIToken returns the token currently used to authenticate requests
+func (c *Client) APIToken() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.config.APIToken
+}
+
+// SetAPIToken changes the token used to authenticate the following requests
+func (c *Client) SetAPIToken(t string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.config.APIToken = t
 }
 
 // setAuthorizationHeader adds a bearer token to the request's Authorization header
+// No header is set for an empty token so gofile treats the request as anonymous
 func setAuthorizationHeader(r *http.Request, t string) {
+	if t == "" {
+		return
+	}
 	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t))
 }
 
@@ -105,9 +317,9 @@ func getUploadServerURL(server string) string {
 	return fmt.Sprintf("https://%s.gofile.io/contents/uploadfile", server)
 }
 
-// Upload creates a multipart/form-data request body for uploading a file.
+// Upload creates a multipart/form-data request body for uploading the content of r as a file named name.
 // Returns a PipeReader that streams the data.
-func upload(filePath string, folderId string, contentType *string, onProgress ProgressCallback) *io.PipeReader {
+func upload(name string, r io.Reader, size int64, folderId string, contentType *string, onProgress ProgressCallback) *io.PipeReader {
 	pr, pw := io.Pipe()
 	w := multipart.NewWriter(pw)
 	go func() {
@@ -116,24 +328,13 @@ func upload(filePath string, folderId string, contentType *string, onProgress Pr
 			pw.CloseWithError(err)
 			return
 		}
-		f, err := os.Open(filePath)
-		if err != nil {
-			pw.CloseWithError(err)
-			return
-		}
-		defer f.Close()
-		fi, err := f.Stat()
 		progressR := &progressReader{
-			Reader: f,
-			size:   fi.Size(),
+			Reader: r,
+			size:   size,
 			total:  0,
 			onRead: onProgress,
 		}
-		if err != nil {
-			pw.CloseWithError(err)
-			return
-		}
-		part, err := w.CreateFormFile("file", fi.Name())
+		part, err := w.CreateFormFile("file", name)
 		if err != nil {
 			pw.CloseWithError(err)
 			return
@@ -155,7 +356,7 @@ func upload(filePath string, folderId string, contentType *string, onProgress Pr
 func (c *Client) GetAvailableServers(zone string) (*http.Response, error) {
 	u, err := url.Parse(c.config.BaseUrl + "/servers")
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("invalid base url: %w", err)
 	}
 
 	q := u.Query()
@@ -164,33 +365,17 @@ func (c *Client) GetAvailableServers(zone string) (*http.Response, error) {
 		q.Add("zone", zone)
 	}
 	u.RawQuery = q.Encode()
-	req, err := http.NewRequest(getMethod, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	setAuthorizationHeader(req, c.config.APIToken)
-	return c.httpClient.Do(req)
+	return c.do(getMethod, u.String(), nil)
 }
 
 // CreateFolder creates a folder in a folder with the speciifed parentFolderId
-// If name is not specified, a name is auto-generated
+// name must not be empty, callers validate it before sending the request
 // Returns the HTTP response or an error
 func (c *Client) CreateFolder(parentFolderID string, name string) (*http.Response, error) {
 	u := c.config.BaseUrl + "/contents/createFolder"
 
 	payload := model.NewFolderPayload(parentFolderID, name)
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(postMethod, u, bytes.NewBuffer(data))
-	if err != nil {
-		return nil, err
-	}
-	setAuthorizationHeader(req, c.config.APIToken)
-	req.Header.Set("Content-Type", "application/json")
-	return c.httpClient.Do(req)
+	return c.do(postMethod, u, payload)
 }
 
 // DeleteContent deletes files and folder  the speciifed contentID(s)
@@ -198,139 +383,221 @@ func (c *Client) CreateFolder(parentFolderID string, name string) (*http.Respons
 func (c *Client) DeleteContent(IDs []string) (*http.Response, error) {
 	u := c.config.BaseUrl + "/contents"
 
-	payload := model.DeleteContentPayload(IDs)
-	data, err := json.Marshal(payload)
+	payload, err := model.DeleteContentPayload(IDs)
 	if err != nil {
 		return nil, err
 	}
+	return c.do(deleteMethod, u, payload)
+}
 
-	req, err := http.NewRequest(deleteMethod, u, bytes.NewBuffer(data))
+// GetContent gets the details of a file or folder with the specified contentID
+// For folders the details of its direct children are included
+// passwordHash is the SHA-256 hex digest of the content password, empty if the content is not protected
+// Returns the HTTP response or an error
+func (c *Client) GetContent(contentID string, passwordHash string) (*http.Response, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/contents/%s", c.config.BaseUrl, contentID))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid base url: %w", err)
 	}
-	setAuthorizationHeader(req, c.config.APIToken)
-	req.Header.Set("Content-Type", "application/json")
-	return c.httpClient.Do(req)
+	if passwordHash != "" {
+		q := u.Query()
+		q.Set("password", passwordHash)
+		u.RawQuery = q.Encode()
+	}
+	return c.do(getMethod, u.String(), nil)
 }
 
-// GetAccountId  gets the user ID
+// Download gets the file behind a direct download link starting at byte offset
+// The token is sent as the accountToken cookie which gofile expects on its file servers
 // Returns the HTTP response or an error
-func (c *Client) GetAccountId() (*http.Response, error) {
-	u := c.config.BaseUrl + "/accounts/getid"
-
-	req, err := http.NewRequest(getMethod, u, nil)
+func (c *Client) Download(link string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, getMethod, link, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.config.APIToken)
-	return c.httpClient.Do(req)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if t := c.APIToken(); t != "" {
+		req.AddCookie(&http.Cookie{Name: "accountToken", Value: t})
+	}
+	server := serverName(req.URL.Host)
+	req, span := c.trace(req, tracing.String("server", server), tracing.Int("offset", offset))
+	resp, err := c.transferClient.Do(req)
+	if err != nil {
+		endSpan(span, nil, err)
+		return nil, err
+	}
+	// the span lasts until the body is closed so it covers the whole transfer
+	span.SetAttributes(tracing.Int("http.status_code", int64(resp.StatusCode)))
+	var read atomic.Int64
+	c.metrics.AddActiveTransfers(metrics.Download, 1)
+	resp.Body = &meteredBody{
+		ReadCloser: resp.Body,
+		onRead: func(n int64) {
+			read.Add(n)
+			c.metrics.AddBytes(server, metrics.Download, n)
+		},
+		onClose: func() {
+			c.metrics.AddActiveTransfers(metrics.Download, -1)
+			span.SetAttributes(tracing.Int("bytes", read.Load()))
+			span.End(nil)
+		},
+	}
+	return resp, nil
 }
 
-// GetAccountInformation gets the account information of the specifed user ID
-// Returns the HTTP response or an error
-func (c *Client) GetAccountInformation(id string) (*http.Response, error) {
-	u := fmt.Sprintf("%s/accounts/%s", c.config.BaseUrl, id)
+// serverName returns the gofile server name of host, e.g. "store1" for "store1.gofile.io"
+func serverName(host string) string {
+	name, _, _ := strings.Cut(host, ".")
+	return name
+}
 
-	req, err := http.NewRequest(getMethod, u, nil)
-	if err != nil {
-		return nil, err
+// meteredReader reports the number of bytes returned by each read and the end of the content
+type meteredReader struct {
+	io.Reader
+	onRead func(n int64)
+	onEOF  func() // onEOF is called when the reader returns io.EOF, may be nil
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+	n, err := m.Reader.Read(p)
+	if n > 0 {
+		m.onRead(int64(n))
 	}
-	setAuthorizationHeader(req, c.config.APIToken)
-	return c.httpClient.Do(req)
+	if err == io.EOF && m.onEOF != nil {
+		m.onEOF()
+	}
+	return n, err
 }
 
-// UpdateContent changes the attribute of a file or folder.
-// Returns the HTTP response or an error
-func (c *Client) UpdateContent(contentID string, attribute string, value interface{}) (*http.Response, error) {
-	u := fmt.Sprintf("%s/contents/%s/update", c.config.BaseUrl, contentID)
+// meteredBody reports the bytes read from a response body and when it is closed
+type meteredBody struct {
+	io.ReadCloser
+	onRead  func(n int64)
+	onClose func()
+	once    sync.Once
+}
 
-	payload := model.NewUpdateContentPayload()
-	var err error
+func (m *meteredBody) Read(p []byte) (int, error) {
+	n, err := m.ReadCloser.Read(p)
+	if n > 0 {
+		m.onRead(int64(n))
+	}
+	return n, err
+}
 
-	switch attribute {
-	case "name":
-		nameStr, ok := value.(string)
-		if !ok {
-			return nil, fmt.Errorf("name must be string, got %T", value)
-		}
-		err = payload.WithName(nameStr)
+func (m *meteredBody) Close() error {
+	m.once.Do(m.onClose)
+	return m.ReadCloser.Close()
+}
 
-	case "description":
-		descStr, ok := value.(string)
-		if !ok {
-			return nil, fmt.Errorf("description must be string, got %T", value)
-		}
-		err = payload.WithDescription(descStr)
+// CopyContent copies files and folders with the specified contentID(s) into folderID
+// This is a premium only feature
+// Returns the HTTP response or an error
+func (c *Client) CopyContent(IDs []string, folderID string) (*http.Response, error) {
+	u := c.config.BaseUrl + "/contents/copy"
 
-	case "tags":
-		slice, ok := value.([]string)
-		if !ok {
-			return nil, fmt.Errorf("description must be string, got %T", value)
-		}
-		tagString := strings.Join(slice, ",")
-		err = payload.WithTags(tagString)
+	payload := model.CopyContentPayload(IDs, folderID)
+	return c.do(postMethod, u, payload)
+}
 
-	case "public":
-		pubBool, ok := value.(bool)
-		if !ok {
-			return nil, fmt.Errorf("public must be boolean, got %T", value)
-		}
-		err = payload.WithPublic(pubBool)
+// MoveContent moves files and folders with the specified contentID(s) into folderID
+// Returns the HTTP response or an error
+func (c *Client) MoveContent(IDs []string, folderID string) (*http.Response, error) {
+	u := c.config.BaseUrl + "/contents/move"
 
-	case "expiry":
-		expiryStr, ok := value.(string)
-		if !ok {
-			return nil, fmt.Errorf("expiry must be string in RFC3339 format, got %T", value)
-		}
-		err = payload.WithExpiry(expiryStr)
+	payload := model.MoveContentPayload(IDs, folderID)
+	return c.do(putMethod, u, payload)
+}
 
-	case "password":
-		passStr, ok := value.(string)
-		if !ok {
-			return nil, fmt.Errorf("password must be string, got %T", value)
-		}
-		err = payload.WithPassword(passStr)
+// GetAccountId  gets the user ID
+// Returns the HTTP response or an error
+func (c *Client) GetAccountId() (*http.Response, error) {
+	u := c.config.BaseUrl + "/accounts/getid"
+	return c.do(getMethod, u, nil)
+}
 
-	default:
-		return nil, fmt.Errorf("unsupported attribute: %s", attribute)
-	}
+// CreateAccount creates a guest account
+// Returns the HTTP response or an error
+func (c *Client) CreateAccount() (*http.Response, error) {
+	u := c.config.BaseUrl + "/accounts"
+	return c.do(postMethod, u, nil)
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to set attribute %s: %w", attribute, err)
-	}
+// GetAccountInformation gets the account information of the specifed user ID
+// Returns the HTTP response or an error
+func (c *Client) GetAccountInformation(id string) (*http.Response, error) {
+	u := fmt.Sprintf("%s/accounts/%s", c.config.BaseUrl, id)
+	return c.do(getMethod, u, nil)
+}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("marshal payload failed: %w", err)
-	}
-	req, err := http.NewRequest(http.MethodPut, u, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
+// UpdateContent changes the attribute of a file or folder set by opt.
+// Returns the HTTP response or an error
+func (c *Client) UpdateContent(contentID string, opt model.UpdateOption) (*http.Response, error) {
+	u := fmt.Sprintf("%s/contents/%s/update", c.config.BaseUrl, contentID)
+
+	payload := model.NewUpdateContentPayload()
+	if err := opt(payload); err != nil {
+		return nil, fmt.Errorf("failed to set attribute %s: %w", payload.Attribute, err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	setAuthorizationHeader(req, c.config.APIToken)
-	return c.httpClient.Do(req)
+	return c.do(putMethod, u, payload)
 }
 
 // UploadFile uploads a file to a specified folder.
 // If folderID is empty, a new public folder is created automatically.
+// If the client has no token, gofile creates a guest account and returns its token in the response.
 // The base URL for the client changes to `https://{server}.gofile.io`
 // Returns the HTTP response or an error
 func (c *Client) UploadFile(server string, filePath string, folderID string, callbackUpdate ProgressCallback) (*http.Response, error) {
-	u := getUploadServerURL(server)
-	var ct string // gets the content type from upload function
-	pr := upload(filePath, folderID, &ct, callbackUpdate)
-	c.httpClient.Timeout = 0
-	req, err := http.NewRequest(postMethod, u, pr)
+	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
-	setAuthorizationHeader(req, c.config.APIToken)
-	req.Header.Set("Content-Type", ct)
-	response, err := c.httpClient.Do(req)
+	defer f.Close()
+	fi, err := f.Stat()
 	if err != nil {
 		return nil, err
 	}
-	c.httpClient.Timeout = 1 * time.Minute
-	return response, nil
+	return c.UploadReader(server, fi.Name(), f, fi.Size(), folderID, callbackUpdate)
+}
+
+// UploadReader uploads the content read from r as a file named name, see UploadFile.
+// The content is streamed, size is only used for progress updates and can be -1 if unknown.
+// Returns the HTTP response or an error
+func (c *Client) UploadReader(server string, name string, r io.Reader, size int64, folderID string, callbackUpdate ProgressCallback) (*http.Response, error) {
+	u := getUploadServerURL(server)
+	c.metrics.AddActiveTransfers(metrics.Upload, 1)
+	defer c.metrics.AddActiveTransfers(metrics.Upload, -1)
+
+	req, err := http.NewRequestWithContext(c.ctx, postMethod, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req, span := c.trace(req, tracing.String("server", server), tracing.Int("size", size))
+
+	// the multipart writer goroutine reads r, these events show whether it or the server is slow
+	var sent atomic.Int64
+	r = &meteredReader{
+		Reader: r,
+		onRead: func(n int64) {
+			if sent.Add(n) == n {
+				span.Event("body.read_start")
+			}
+			c.metrics.AddBytes(server, metrics.Upload, n)
+		},
+		onEOF: func() {
+			span.Event("body.read_done", tracing.Int("bytes", sent.Load()))
+		},
+	}
+	var ct string // gets the content type from upload function
+	pr := upload(name, r, size, folderID, &ct, callbackUpdate)
+	req.Body = pr
+	req.ContentLength = -1
+	setAuthorizationHeader(req, c.APIToken())
+	req.Header.Set("Content-Type", ct)
+	resp, err := c.transferClient.Do(req)
+	span.SetAttributes(tracing.Int("bytes", sent.Load()))
+	endSpan(span, resp, err)
+	return resp, err
 }
@@ -0,0 +1,42 @@
+// package metrics defines the measurements recorded by the client pipeline and a Prometheus exporter
+//
+// Pass a Recorder in api.Options.Metrics to record API requests, retries, transferred bytes and
+// active transfers. Registry is a Recorder which serves the recorded values in the Prometheus text format:
+//
+//	reg := metrics.NewRegistry(nil)
+//	c := api.New(&api.Options{Metrics: reg})
+//	http.Handle("/metrics", reg)
+package metrics
+
+import "time"
+
+// Direction is the direction of a file transfer
+type Direction string
+
+const (
+	Upload   Direction = "upload"   // file sent to a gofile server
+	Download Direction = "download" // file fetched from a gofile server
+)
+
+// Recorder receives measurements from the client pipeline.
+//
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// ObserveRequest records a finished API call. status is the gofile status of the response,
+	// "error" if no response was received or "invalid-response" if it could not be decoded.
+	ObserveRequest(endpoint string, status string, d time.Duration)
+	// IncRetry records a request sent again after a failed attempt, reason is "network" or the HTTP status code.
+	IncRetry(method string, reason string)
+	// AddBytes records n bytes transferred to or from server.
+	AddBytes(server string, dir Direction, n int64)
+	// AddActiveTransfers changes the number of transfers in progress by delta.
+	AddActiveTransfers(dir Direction, delta int)
+}
+
+// Nop is a Recorder discarding every measurement, used when no Recorder is set
+type Nop struct{}
+
+func (Nop) ObserveRequest(string, string, time.Duration) {}
+func (Nop) IncRetry(string, string)                      {}
+func (Nop) AddBytes(string, Direction, int64)            {}
+func (Nop) AddActiveTransfers(Direction, int)            {}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the request duration histogram buckets in seconds used when Options.Buckets is nil
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Options defines optional configuration for a Registry.
+type Options struct {
+	Buckets []float64 // Buckets are the upper bounds of the request duration histogram in seconds, sorted ascending
+}
+
+// Registry is a Recorder keeping measurements in memory and serving them in the Prometheus text format.
+//
+// The following metrics are exposed:
+//
+//	gofile_requests_total{endpoint,status}              counter
+//	gofile_request_duration_seconds{endpoint}           histogram
+//	gofile_retries_total{method,reason}                 counter
+//	gofile_transfer_bytes_total{server,direction}       counter
+//	gofile_active_transfers{direction}                  gauge
+type Registry struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[[2]string]uint64  // endpoint and status to request count
+	durations map[string]*histogram // endpoint to request durations
+	retries   map[[2]string]uint64  // method and reason to retry count
+	bytes     map[[2]string]int64   // server and direction to transferred bytes
+	active    map[Direction]int64   // direction to transfers in progress
+}
+
+// histogram holds the cumulative counts of a histogram
+type histogram struct {
+	counts []uint64 // counts[i] is the number of observations <= buckets[i]
+	sum    float64
+	count  uint64
+}
+
+var (
+	_ Recorder     = (*Registry)(nil)
+	_ http.Handler = (*Registry)(nil)
+)
+
+// NewRegistry creates an empty registry.
+//
+// If opts is nil, DefaultBuckets are used.
+func NewRegistry(opts *Options) *Registry {
+	buckets := DefaultBuckets
+	if opts != nil && opts.Buckets != nil {
+		buckets = opts.Buckets
+	}
+	return &Registry{
+		buckets:   slices.Clone(buckets),
+		requests:  make(map[[2]string]uint64),
+		durations: make(map[string]*histogram),
+		retries:   make(map[[2]string]uint64),
+		bytes:     make(map[[2]string]int64),
+		active:    make(map[Direction]int64),
+	}
+}
+
+func (r *Registry) ObserveRequest(endpoint string, status string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[[2]string{endpoint, status}]++
+	h, ok := r.durations[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.buckets))}
+		r.durations[endpoint] = h
+	}
+	s := d.Seconds()
+	for i, b := range r.buckets {
+		if s <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += s
+	h.count++
+}
+
+func (r *Registry) IncRetry(method string, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries[[2]string{method, reason}]++
+}
+
+func (r *Registry) AddBytes(server string, dir Direction, n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytes[[2]string{server, string(dir)}] += n
+}
+
+func (r *Registry) AddActiveTransfers(dir Direction, delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active[dir] += int64(delta)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format to w
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	header(cw, "gofile_requests_total", "counter", "Requests sent to the gofile API by endpoint and response status.")
+	for _, k := range slices.SortedFunc(maps.Keys(r.requests), comparePair) {
+		fmt.Fprintf(cw, "gofile_requests_total{endpoint=%s,status=%s} %d\n", label(k[0]), label(k[1]), r.requests[k])
+	}
+
+	header(cw, "gofile_request_duration_seconds", "histogram", "Duration of gofile API requests by endpoint.")
+	for _, endpoint := range slices.Sorted(maps.Keys(r.durations)) {
+		h := r.durations[endpoint]
+		for i, b := range r.buckets {
+			fmt.Fprintf(cw, "gofile_request_duration_seconds_bucket{endpoint=%s,le=%s} %d\n", label(endpoint), label(formatFloat(b)), h.counts[i])
+		}
+		fmt.Fprintf(cw, "gofile_request_duration_seconds_bucket{endpoint=%s,le=\"+Inf\"} %d\n", label(endpoint), h.count)
+		fmt.Fprintf(cw, "gofile_request_duration_seconds_sum{endpoint=%s} %s\n", label(endpoint), formatFloat(h.sum))
+		fmt.Fprintf(cw, "gofile_request_duration_seconds_count{endpoint=%s} %d\n", label(endpoint), h.count)
+	}
+
+	header(cw, "gofile_retries_total", "counter", "Requests sent again after a failed attempt by method and reason.")
+	for _, k := range slices.SortedFunc(maps.Keys(r.retries), comparePair) {
+		fmt.Fprintf(cw, "gofile_retries_total{method=%s,reason=%s} %d\n", label(k[0]), label(k[1]), r.retries[k])
+	}
+
+	header(cw, "gofile_transfer_bytes_total", "counter", "Bytes uploaded to or downloaded from gofile servers.")
+	for _, k := range slices.SortedFunc(maps.Keys(r.bytes), comparePair) {
+		fmt.Fprintf(cw, "gofile_transfer_bytes_total{server=%s,direction=%s} %d\n", label(k[0]), label(k[1]), r.bytes[k])
+	}
+
+	header(cw, "gofile_active_transfers", "gauge", "Uploads and downloads in progress.")
+	for _, dir := range []Direction{Upload, Download} {
+		fmt.Fprintf(cw, "gofile_active_transfers{direction=%s} %d\n", label(string(dir)), r.active[dir])
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// header writes the HELP and TYPE lines of a metric
+func header(w io.Writer, name string, typ string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// labelEscaper escapes label values as required by the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// label returns v as a quoted label value
+func label(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func comparePair(a, b [2]string) int {
+	if c := strings.Compare(a[0], b[0]); c != 0 {
+		return c
+	}
+	return strings.Compare(a[1], b[1])
+}
+
+// countingWriter counts written bytes and keeps the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
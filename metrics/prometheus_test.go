@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ Recorder = Nop{}
+
+// exposition returns the lines written by r.WriteTo
+func exposition(t *testing.T, r *Registry) []string {
+	t.Helper()
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("WriteTo returned %d bytes, wrote %d", n, b.Len())
+	}
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+}
+
+// contains reports whether lines holds every line of want, in order
+func contains(lines []string, want ...string) bool {
+	for _, l := range lines {
+		if len(want) > 0 && l == want[0] {
+			want = want[1:]
+		}
+	}
+	return len(want) == 0
+}
+
+func TestRegistryCounters(t *testing.T) {
+	r := NewRegistry(nil)
+	r.ObserveRequest("getContent", "ok", time.Second)
+	r.ObserveRequest("getContent", "ok", time.Second)
+	r.ObserveRequest("deleteContent", "error-notFound", time.Second)
+	r.IncRetry("GET", "503")
+	r.IncRetry("GET", "network")
+	r.IncRetry("GET", "503")
+	r.AddBytes("store1", Upload, 10)
+	r.AddBytes("store1", Upload, 5)
+	r.AddBytes("store2", Download, 7)
+	r.AddActiveTransfers(Upload, 2)
+	r.AddActiveTransfers(Upload, -1)
+
+	lines := exposition(t, r)
+	want := []string{
+		"# HELP gofile_requests_total Requests sent to the gofile API by endpoint and response status.",
+		"# TYPE gofile_requests_total counter",
+		`gofile_requests_total{endpoint="deleteContent",status="error-notFound"} 1`,
+		`gofile_requests_total{endpoint="getContent",status="ok"} 2`,
+		"# TYPE gofile_retries_total counter",
+		`gofile_retries_total{method="GET",reason="503"} 2`,
+		`gofile_retries_total{method="GET",reason="network"} 1`,
+		"# TYPE gofile_transfer_bytes_total counter",
+		`gofile_transfer_bytes_total{server="store1",direction="upload"} 15`,
+		`gofile_transfer_bytes_total{server="store2",direction="download"} 7`,
+		"# TYPE gofile_active_transfers gauge",
+		`gofile_active_transfers{direction="upload"} 1`,
+		`gofile_active_transfers{direction="download"} 0`,
+	}
+	if !contains(lines, want...) {
+		t.Errorf("exposition:\n%s\nwant in order:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRegistryHistogram(t *testing.T) {
+	r := NewRegistry(&Options{Buckets: []float64{0.1, 1}})
+	r.ObserveRequest("getContent", "ok", 50*time.Millisecond)
+	r.ObserveRequest("getContent", "ok", 500*time.Millisecond)
+	r.ObserveRequest("getContent", "error", 2*time.Second)
+
+	lines := exposition(t, r)
+	want := []string{
+		"# TYPE gofile_request_duration_seconds histogram",
+		`gofile_request_duration_seconds_bucket{endpoint="getContent",le="0.1"} 1`,
+		`gofile_request_duration_seconds_bucket{endpoint="getContent",le="1"} 2`,
+		`gofile_request_duration_seconds_bucket{endpoint="getContent",le="+Inf"} 3`,
+		`gofile_request_duration_seconds_sum{endpoint="getContent"} 2.55`,
+		`gofile_request_duration_seconds_count{endpoint="getContent"} 3`,
+	}
+	if !contains(lines, want...) {
+		t.Errorf("exposition:\n%s\nwant in order:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRegistryEscapesLabels(t *testing.T) {
+	r := NewRegistry(nil)
+	r.IncRetry("GET", "say \"hi\"\\\n")
+
+	want := `gofile_retries_total{method="GET",reason="say \"hi\"\\\n"} 1`
+	if lines := exposition(t, r); !contains(lines, want) {
+		t.Errorf("exposition:\n%s\nwant %s", strings.Join(lines, "\n"), want)
+	}
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	r := NewRegistry(nil)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				r.AddBytes("store1", Download, 1)
+				r.AddActiveTransfers(Download, 1)
+				r.AddActiveTransfers(Download, -1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := exposition(t, r)
+	if !contains(lines, `gofile_transfer_bytes_total{server="store1",direction="download"} 800`, `gofile_active_transfers{direction="download"} 0`) {
+		t.Errorf("exposition:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	r := NewRegistry(nil)
+	r.ObserveRequest("getContent", "ok", time.Second)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %s, want the Prometheus text format", ct)
+	}
+	if !strings.Contains(w.Body.String(), `gofile_requests_total{endpoint="getContent",status="ok"} 1`) {
+		t.Errorf("body does not hold the request count:\n%s", w.Body)
+	}
+}
@@ -3,6 +3,7 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"strings"
 	"time"
 )
@@ -12,6 +13,12 @@ type deleteContent struct {
 	ContentsID string `json:"contentsId"` // array of ID of contents to be deleted
 }
 
+// copyContent represents the payload to copy files or folders into another folder
+type copyContent struct {
+	ContentsID string `json:"contentsId"` // comma separated IDs of contents to be copied
+	FolderID   string `json:"folderId"`   // ID of the destination folder
+}
+
 // newFolder represents the payload for creating a new folder.
 //
 // It contains the ID of the parent folder and the name of the new folder.
@@ -64,22 +71,11 @@ func (u *updateContent) WithPublic(p bool) error {
 	u.AttributeValue = value
 	return nil
 }
-func isValidTimeString(timeStr string) (int64, error) {
-	// Try to parse the time string
-	t, err := time.Parse(time.RFC3339, timeStr)
-	if err != nil {
-		return 0, err
-	}
-	return t.Unix(), nil
-}
 
-func (u *updateContent) WithExpiry(t string) error {
+// WithExpiry makes the content expire at t, a zero t removes the expiry
+func (u *updateContent) WithExpiry(t time.Time) error {
 	u.Attribute = "expiry"
-	unixTime, err := isValidTimeString(t)
-	if err != nil {
-		return err
-	}
-	value, err := json.Marshal(unixTime)
+	value, err := json.Marshal(NewUnixTime(t))
 	if err != nil {
 		return err
 	}
@@ -99,17 +95,34 @@ func (u *updateContent) WithPassword(p string) error {
 
 // DeleteContentPayload creates an instance of deleteContent
 //
-// Returns delete content
-func DeleteContentPayload(IDs []string) deleteContent {
+// Returns delete content or an error if no ID is provided
+func DeleteContentPayload(IDs []string) (deleteContent, error) {
 	if len(IDs) == 0 {
-		panic("at least one ID must be provided")
+		return deleteContent{}, errors.New("at least one ID must be provided")
 	}
 	s := strings.Join(IDs, ",")
 	return deleteContent{
 		ContentsID: s,
+	}, nil
+}
+
+// CopyContentPayload creates an instance of copyContent
+//
+// Returns copyContent
+func CopyContentPayload(IDs []string, folderID string) copyContent {
+	return copyContent{
+		ContentsID: strings.Join(IDs, ","),
+		FolderID:   folderID,
 	}
 }
 
+// MoveContentPayload creates an instance of copyContent for moving contents, both endpoints share the same payload
+//
+// Returns copyContent
+func MoveContentPayload(IDs []string, folderID string) copyContent {
+	return CopyContentPayload(IDs, folderID)
+}
+
 // NewFolderPayload creates an instance of newFolder
 //
 // Returns newFolder
@@ -132,7 +145,7 @@ func NewFolderPayload(p string, f string) newFolder {
 //
 // 4. type public = bool
 //
-// 5. type expiry = string
+// 5. type expiry = unix seconds, see UnixTime
 //
 // 6. type password = string
 //
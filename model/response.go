@@ -1,5 +1,10 @@
 package model
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 type ContentType string
 
 const (
@@ -7,11 +12,11 @@ const (
 	FolderType ContentType = "folder"
 )
 
-// currentStats represents information about the user root folder
-type currentStats struct {
-	FolderCount int `json:"folderCount"` // number of folders in user's root folder
-	FileCount   int `json:"fileCount"`   // number of files in user's root folder
-	Storage     int `json:"storage"`     //
+// CurrentStats represents information about the user root folder
+type CurrentStats struct {
+	FolderCount int   `json:"folderCount"` // number of folders in user's root folder
+	FileCount   int   `json:"fileCount"`   // number of files in user's root folder
+	Storage     int64 `json:"storage"`     // bytes stored in the account
 }
 
 // server represents a server with its name and zone
@@ -20,106 +25,183 @@ type server struct {
 	Zone string `json:"zone"` // zone where the server is located
 }
 
+// Response is the envelope wrapping every gofile API response
+//
+// Status is "ok" on success, otherwise it holds the gofile error code (eg: "error-notFound")
+type Response[T any] struct {
+	Status string `json:"status"`
+	Data   T      `json:"data"`
+}
+
+// AccountIDData holds the user's ID
+type AccountIDData struct {
+	ID string `json:"id"` //ID of user account
+}
+
 // AccountIDResponse represents the response structure for the user's ID
 //
 // Contains status and the user ID
-type AccountIDResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		ID string `json:"id"` //ID of user account
-	} `json:"data"`
+type AccountIDResponse = Response[AccountIDData]
+
+// CreateAccountData holds the guest account created by gofile
+type CreateAccountData struct {
+	ID    string `json:"id"`    // ID of the guest account
+	Token string `json:"token"` // bearer token of the guest account
 }
 
-type DeleteContentResponse struct {
+// CreateAccountResponse represents the response structure for a guest account creation
+type CreateAccountResponse = Response[CreateAccountData]
+
+// DeleteStatus is the outcome of deleting a single content
+type DeleteStatus struct {
 	Status string `json:"status"`
-	Data   map[string]struct {
-		Status string `json:"status"`
-		// Data   interface{} `json:"data"` // since this field looks to be always empty why not exclude it
-	} `json:"data"`
+	// Data   interface{} `json:"data"` // since this field looks to be always empty why not exclude it
+}
+
+// DeleteContentData maps each deleted content ID to the outcome of its deletion
+type DeleteContentData map[string]DeleteStatus
+
+// DeleteContentResponse represents the response structure for deleting contents
+type DeleteContentResponse = Response[DeleteContentData]
+
+// AccountInformationData holds information about a user account
+type AccountInformationData struct {
+	IPTraffic30  int64        `json:"ipTraffic30"` // bytes downloaded from the account over the last 30 days
+	ID           string       `json:"id"`          // Id of user account
+	CreateTime   UnixTime     `json:"createTime"`  // date:time account was created
+	Email        string       `json:"email"`       // email address of user
+	Tier         string       `json:"tier"`        // tier of user account
+	Token        string       `json:"token"`       // bearer token for Authorization header
+	RootFolder   string       `json:"rootFolder"`  // ID of user's root folder
+	StatsCurrent CurrentStats `json:"statsCurrent"`
 }
 
 // AccountInformationResponse represent the response structure for a user account information
 //
 // Contains status and data about the user account
-type AccountInformationResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		IPTraffic30  int64        `json:"ipTraffic30"` //
-		ID           string       `json:"id"`          // Id of user account
-		CreateTime   int64        `json:"createTime"`  // date:time account was created
-		Email        string       `json:"email"`       // email address of user
-		Tier         string       `json:"tier"`        // tier of user account
-		Token        string       `json:"token"`       // bearer token for Authorization header
-		RootFolder   string       `json:"rootFolder"`  // ID of user's root folder
-		StatsCurrent currentStats `json:"statsCurrent"`
-	} `json:"data"`
+type AccountInformationResponse = Response[AccountInformationData]
+
+// AvailableServerData holds the servers files can be uploaded to
+type AvailableServerData struct {
+	Servers        []server `json:"servers"`        // servers in the specified zone
+	ServersAllZone []server `json:"serversAllZone"` // servers across all zones
 }
 
 // AvailableServerResponse represents the response structure for available servers.
 //
 // Contains status and data about servers in all zones.
-type AvailableServerResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		Servers        []server `json:"servers"`        // servers in the specified zone
-		ServersAllZone []server `json:"serversAllZone"` // servers across all zones
-	} `json:"data"`
+type AvailableServerResponse = Response[AvailableServerData]
+
+// CreateFolderData holds information about a created folder
+type CreateFolderData struct {
+	ID           string   `json:"id"`           // ID of the folder
+	Owner        string   `json:"owner"`        // ID of the creator of the folder
+	Type         string   `json:"type"`         // this is always folder
+	Name         string   `json:"name"`         // name of the folder
+	ParentFolder string   `json:"parentFolder"` // ID of the parent folder
+	CreateTime   UnixTime `json:"createTime"`   // date:time the folder was created
+	ModTime      UnixTime `json:"modTime"`      //
+	Code         string   `json:"code"`         // short code of the folder?
 }
 
 // CreateFolderResponse represents the response structure for a successful folder creation
 //
 // Contains status and data about the created folder
-type CreateFolderResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		ID           string `json:"id"`           // ID of the folder
-		Owner        string `json:"owner"`        // ID of the creator of the folder
-		Type         string `json:"type"`         // this is always folder
-		Name         string `json:"name"`         // name of the folder
-		ParentFolder string `json:"parentFolder"` // ID of the parent folder
-		CreateTime   string `json:"createTime"`   // date:time the folder was created
-		ModTime      string `json:"modTime"`      //
-		Code         string `json:"code"`         // short code of the folder?
-	} `json:"data"`
+type CreateFolderResponse = Response[CreateFolderData]
+
+// UploadFileData holds information about an uploaded file
+type UploadFileData struct {
+	CreateTime       UnixTime    `json:"createTime"`       // time the file was uploaded
+	DownloadPage     string      `json:"downloadPage"`     // gofile.io download link page for the file
+	ID               string      `json:"id"`               // ID of the file on the gofile server
+	MD5              string      `json:"md5"`              // MD5 hash of the file
+	Mimetype         string      `json:"mimetype"`         // type of the file (eg: "application/zip")
+	ModTime          UnixTime    `json:"modTime"`          //
+	Name             string      `json:"name"`             // name of the file uploaded
+	ParentFolder     string      `json:"parentFolder"`     // ID of parent folder
+	ParentFolderCode string      `json:"parentFolderCode"` //code of parent folder
+	Servers          []string    `json:"servers"`          // array of name of servers the uploaded file is on
+	Size             int64       `json:"size"`             // size of the file in bytes
+	Type             ContentType `json:"type"`             // type of file (eg: "file")
+	GuestToken       string      `json:"guestToken"`       // token of the guest account created when uploading without a token
 }
 
 // UploadFileResponse represent the response structure for a successful file upload
 //
 // Contains status and data about uploaded file
-type UploadFileResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		CreateTime       int64       `json:"createTime"`       // time the file was uploaded
-		DownloadPage     string      `json:"downloadPage"`     // gofile.io download link page for the file
-		ID               string      `json:"id"`               // ID of the file on the gofile server
-		MD5              string      `json:"md5"`              // MD5 hash of the file
-		Mimetype         string      `json:"mimetype"`         // type of the file (eg: "application/zip")
-		ModTime          int64       `json:"modTime"`          //
-		Name             string      `json:"name"`             // name of the file uploaded
-		ParentFolder     string      `json:"parentFolder"`     // ID of parent folder
-		ParentFolderCode string      `json:"parentFolderCode"` //code of parent folder
-		Servers          []string    `json:"servers"`          // array of name of servers the uploaded file is on
-		Size             int64       `json:"size"`             // size of the file in bytes
-		Type             ContentType `json:"type"`             // type of file (eg: "file")
-	} `json:"data"`
+type UploadFileResponse = Response[UploadFileData]
+
+// UpdateContentData holds information about an updated file or folder
+type UpdateContentData struct {
+	ID           string      `json:"id"`
+	Type         ContentType `json:"type"`
+	Name         string      `json:"name"`
+	CreateTime   UnixTime    `json:"createTime"`
+	ModTime      UnixTime    `json:"modTime"`
+	ParentFolder string      `json:"parentFolder"`
+
+	// File-specific fields
+	MimeType *string `json:"mimetype,omitempty"`
+	MD5      *string `json:"md5,omitempty"`
+	Size     *int64  `json:"size,omitempty"`
 }
 
 // UpdateContentResponse represent the response structure for a successful attribute change of a file or folder
 //
 // Contains status and data about specified  file or folder
-type UpdateContentResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		ID           string      `json:"id"`
-		Type         ContentType `json:"type"`
-		Name         string      `json:"name"`
-		CreateTime   int64       `json:"createTime"`
-		ModTime      int64       `json:"modTime"`
-		ParentFolder string      `json:"parentFolder"`
-
-		// File-specific fields
-		MimeType *string `json:"mimetype,omitempty"`
-		MD5      *string `json:"md5,omitempty"`
-		Size     *int64  `json:"size,omitempty"`
-	} `json:"data"`
+type UpdateContentResponse = Response[UpdateContentData]
+
+// Content represents a file or a folder as returned by the contents endpoint
+//
+// Fields which only apply to files or folders are left empty for the other type
+type Content struct {
+	ID           string      `json:"id"`           // ID of the file or folder
+	Type         ContentType `json:"type"`         // type of the content (eg: "file")
+	Name         string      `json:"name"`         // name of the file or folder
+	ParentFolder string      `json:"parentFolder"` // ID of the parent folder
+	CreateTime   UnixTime    `json:"createTime"`   // time the content was created
+	ModTime      UnixTime    `json:"modTime"`      // time the content was last modified
+	Public       bool        `json:"public"`       // whether the content can be accessed without the owner's token
+	Description  string      `json:"description"`  // description of the content
+	Tags         string      `json:"tags"`         // comma separated list of tags
+	Expiry       UnixTime    `json:"expire"`       // time the content expires, zero if it never does
+
+	Password       bool   `json:"password"`       // whether the content is protected by a password
+	PasswordStatus string `json:"passwordStatus"` // "passwordRequired" or "passwordWrong" when the content could not be unlocked
+
+	// File-specific fields
+	MD5      string   `json:"md5"`      // MD5 hash of the file
+	Size     int64    `json:"size"`     // size of the file in bytes
+	MimeType string   `json:"mimetype"` // type of the file (eg: "application/zip")
+	Link     string   `json:"link"`     // direct download link of the file
+	Servers  []string `json:"servers"`  // array of name of servers the file is on
+
+	// Folder-specific fields
+	Code          string             `json:"code"`          // short code of the folder used in download pages
+	ChildrenCount int                `json:"childrenCount"` // number of direct children of the folder
+	TotalSize     int64              `json:"totalSize"`     // size of all files in the folder in bytes
+	Children      map[string]Content `json:"children"`      // direct children of the folder keyed by their ID
+}
+
+// ContentResponse represents the response structure for the details of a file or folder
+//
+// Contains status and data about the content
+type ContentResponse = Response[Content]
+
+// CopyContentResponse represents the response structure for copying contents into a folder
+type CopyContentResponse = Response[json.RawMessage]
+
+// MoveContentResponse represents the response structure for moving contents into a folder
+type MoveContentResponse = Response[json.RawMessage]
+
+// TagList returns the tags of the content as a slice
+func (c Content) TagList() []string {
+	var tags []string
+	for _, t := range strings.Split(c.Tags, ",") {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
 }
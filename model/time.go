@@ -0,0 +1,105 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// UnixTime is a time.Time sent by gofile as unix seconds.
+//
+// Decoding also accepts numeric strings, unix milliseconds and RFC3339 strings
+// in case the server changes how it represents time. The zero value means no time was set.
+type UnixTime struct {
+	time.Time
+}
+
+// millisecondThreshold is the smallest value treated as unix milliseconds instead of seconds
+// (it is reached in seconds in the year 33658)
+const millisecondThreshold = 1e12
+
+// NewUnixTime wraps t in a UnixTime
+func NewUnixTime(t time.Time) UnixTime {
+	return UnixTime{Time: t}
+}
+
+// fromUnix converts unix seconds or milliseconds to a UnixTime
+func fromUnix(n int64) UnixTime {
+	if n == 0 {
+		return UnixTime{}
+	}
+	if n >= millisecondThreshold || n <= -millisecondThreshold {
+		return UnixTime{Time: time.UnixMilli(n)}
+	}
+	return UnixTime{Time: time.Unix(n, 0)}
+}
+
+// MarshalJSON encodes t as unix seconds, the zero time is encoded as 0
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("0"), nil
+	}
+	return []byte(strconv.FormatInt(t.Unix(), 10)), nil
+}
+
+// UnmarshalJSON decodes a number, a numeric string or an RFC3339 string into t
+func (t *UnixTime) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*t = UnixTime{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := parseTimeString(s)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid time %s: %w", data, err)
+	}
+	parsed, err := parseNumber(string(n))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// parseTimeString parses an empty, numeric or RFC3339 string
+func parseTimeString(s string) (UnixTime, error) {
+	if s == "" {
+		return UnixTime{}, nil
+	}
+	if parsed, err := parseNumber(s); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return UnixTime{}, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	return UnixTime{Time: parsed}, nil
+}
+
+// parseNumber parses an integer or a float of unix seconds or milliseconds
+func parseNumber(s string) (UnixTime, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return fromUnix(n), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return UnixTime{}, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	return fromUnix(int64(f)), nil
+}
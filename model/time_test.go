@@ -0,0 +1,77 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUnixTimeUnmarshal(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		json string
+		want time.Time
+	}{
+		{"seconds", "1714566600", want},
+		{"float seconds", "1714566600.0", want},
+		{"numeric string", `"1714566600"`, want},
+		{"milliseconds", "1714566600000", want},
+		{"millisecond string", `"1714566600000"`, want},
+		{"RFC3339", `"2024-05-01T12:30:00Z"`, want},
+		{"RFC3339 with offset", `"2024-05-01T14:30:00+02:00"`, want},
+		{"null", "null", time.Time{}},
+		{"zero", "0", time.Time{}},
+		{"empty string", `""`, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got UnixTime
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || got.IsZero() != tt.want.IsZero() {
+				t.Errorf("decoded %s as %v, want %v", tt.json, got.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnixTimeUnmarshalInvalid(t *testing.T) {
+	for _, data := range []string{`"yesterday"`, "true", "{}"} {
+		var got UnixTime
+		if err := json.Unmarshal([]byte(data), &got); err == nil {
+			t.Errorf("decoded %s as %v, want an error", data, got.Time)
+		}
+	}
+}
+
+func TestUnixTimeMarshal(t *testing.T) {
+	tests := []struct {
+		t    UnixTime
+		want string
+	}{
+		{UnixTime{}, "0"},
+		{NewUnixTime(time.Unix(1714566600, 999)), "1714566600"},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("encoded %v as %s, want %s", tt.t.Time, got, tt.want)
+		}
+	}
+
+	// a field decoded from null is encoded back as the zero time
+	var c struct {
+		Expiry UnixTime `json:"expire"`
+	}
+	if err := json.Unmarshal([]byte(`{"expire":null}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(c); string(got) != `{"expire":0}` {
+		t.Errorf("round trip of null gave %s, want {\"expire\":0}", got)
+	}
+}
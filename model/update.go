@@ -0,0 +1,146 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UpdateOption sets a single attribute of a file or folder in an update payload
+type UpdateOption func(u *updateContent) error
+
+// Attribute returns the name of the attribute set by o.
+//
+// Options name their attribute before validating the value, so the name is returned even if o fails
+// and errors about an invalid value can say which attribute it was for.
+func (o UpdateOption) Attribute() string {
+	u := NewUpdateContentPayload()
+	o(u)
+	return u.Attribute
+}
+
+// SetName renames the content
+func SetName(n string) UpdateOption {
+	return func(u *updateContent) error {
+		return u.WithName(n)
+	}
+}
+
+// SetDescription changes the description of the content
+func SetDescription(d string) UpdateOption {
+	return func(u *updateContent) error {
+		return u.WithDescription(d)
+	}
+}
+
+// SetTags replaces all the tags of the content
+func SetTags(t []string) UpdateOption {
+	return func(u *updateContent) error {
+		return u.WithTags(strings.Join(t, ","))
+	}
+}
+
+// SetPublic makes the content public or private
+func SetPublic(p bool) UpdateOption {
+	return func(u *updateContent) error {
+		return u.WithPublic(p)
+	}
+}
+
+// SetExpiry makes the content expire at t, a zero t removes the expiry
+func SetExpiry(t time.Time) UpdateOption {
+	return func(u *updateContent) error {
+		return u.WithExpiry(t)
+	}
+}
+
+// SetExpiryIn makes the content expire once d has elapsed from now
+func SetExpiryIn(d time.Duration) UpdateOption {
+	return func(u *updateContent) error {
+		return u.WithExpiry(time.Now().Add(d))
+	}
+}
+
+// SetPassword protects the content with the password p
+func SetPassword(p string) UpdateOption {
+	return func(u *updateContent) error {
+		return u.WithPassword(p)
+	}
+}
+
+// AttributeOption converts an attribute name and its value into an UpdateOption
+//
+// # Attribute can be any of the following with their expected type for the new value
+//
+// 1. name = string
+//
+// 2. type description = string
+//
+// 3. type tags = []string
+//
+// 4. type public = bool
+//
+// 5. type expiry = time.Time, time.Duration or string in RFC3339 format
+//
+// 6. type password = string
+//
+// Returns the option or an error if the attribute is unknown or the value has the wrong type
+func AttributeOption(attribute string, value any) (UpdateOption, error) {
+	switch attribute {
+	case "name":
+		nameStr, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("name must be string, got %T", value)
+		}
+		return SetName(nameStr), nil
+
+	case "description":
+		descStr, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("description must be string, got %T", value)
+		}
+		return SetDescription(descStr), nil
+
+	case "tags":
+		slice, ok := value.([]string)
+		if !ok {
+			return nil, fmt.Errorf("tags must be []string, got %T", value)
+		}
+		return SetTags(slice), nil
+
+	case "public":
+		pubBool, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("public must be boolean, got %T", value)
+		}
+		return SetPublic(pubBool), nil
+
+	case "expiry":
+		switch v := value.(type) {
+		case time.Time:
+			return SetExpiry(v), nil
+		case UnixTime:
+			return SetExpiry(v.Time), nil
+		case time.Duration:
+			return SetExpiryIn(v), nil
+		case string:
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("expiry must be in RFC3339 format: %w", err)
+			}
+			return SetExpiry(t), nil
+		default:
+			return nil, fmt.Errorf("expiry must be time.Time, time.Duration or string in RFC3339 format, got %T", value)
+		}
+
+	case "password":
+		passStr, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("password must be string, got %T", value)
+		}
+		return SetPassword(passStr), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported attribute: %s", attribute)
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpdateOptionAttribute(t *testing.T) {
+	tests := []struct {
+		opt  UpdateOption
+		want string
+	}{
+		{SetName("a.txt"), "name"},
+		{SetDescription("d"), "description"},
+		{SetTags([]string{"a", "b"}), "tags"},
+		{SetPublic(true), "public"},
+		{SetExpiry(time.Now()), "expiry"},
+		{SetExpiryIn(time.Hour), "expiry"},
+		{SetPassword("p"), "password"},
+		// an option rejecting its value still names the attribute it was for
+		{func(u *updateContent) error {
+			u.Attribute = "name"
+			return errors.New("invalid name")
+		}, "name"},
+	}
+	for _, tt := range tests {
+		if got := tt.opt.Attribute(); got != tt.want {
+			t.Errorf("Attribute() = %q, want %q", got, tt.want)
+		}
+	}
+}
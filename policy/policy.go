@@ -0,0 +1,139 @@
+// package policy decides when uploaded content expires based on rules matching its path, tags and size
+package policy
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Action is what a rule does with the content it matches
+type Action string
+
+const (
+	Expire Action = "expire" // content expires After its creation
+	Keep   Action = "keep"   // content never expires
+	Delete Action = "delete" // content is deleted by the sweeper once it is older than After
+)
+
+// Rule matches content and decides its retention.
+//
+// Every condition that is set must hold for the rule to match.
+type Rule struct {
+	Name    string        // Name identifies the rule in sweep reports
+	Path    string        // Path is a glob matched against the slash separated path of the content, "**" matches any number of directories. A pattern without '/' is matched against the base name
+	Tags    []string      // Tags the content must all have
+	MinSize int64         // MinSize is the minimum size in bytes, 0 means no minimum
+	MaxSize int64         // MaxSize is the maximum size in bytes, 0 means no maximum
+	Action  Action        // Action to take on matching content
+	After   time.Duration // After is the lifetime of the content for Expire and Delete, counted from its creation
+}
+
+// Item describes content a policy is evaluated against
+type Item struct {
+	Path    string    // slash separated path of the content relative to the swept folder (eg: "ci/build-42/app.zip")
+	Tags    []string  // tags of the content
+	Size    int64     // size of the content in bytes
+	Created time.Time // time the content was created
+}
+
+// Policy is an ordered list of rules, the first matching rule applies
+type Policy struct {
+	Rules []Rule
+}
+
+// Validate checks that every rule is usable
+func (p Policy) Validate() error {
+	for i, r := range p.Rules {
+		switch r.Action {
+		case Expire, Delete:
+			if r.After <= 0 {
+				return fmt.Errorf("rule %d (%s): %s needs a positive After", i, r.Name, r.Action)
+			}
+		case Keep:
+		default:
+			return fmt.Errorf("rule %d (%s): unknown action %q", i, r.Name, r.Action)
+		}
+		if r.Path != "" {
+			if _, err := path.Match(strings.ReplaceAll(r.Path, "**", "*"), ""); err != nil {
+				return fmt.Errorf("rule %d (%s): invalid path pattern: %w", i, r.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule matching it.
+//
+// Returns the rule and whether one matched.
+func (p Policy) Match(it Item) (Rule, bool) {
+	for _, r := range p.Rules {
+		if r.matches(it) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Expiry returns when content created at created should expire under r.
+//
+// Returns the expiry and false if the content should never expire.
+func (r Rule) Expiry(created time.Time) (time.Time, bool) {
+	if r.Action != Expire {
+		return time.Time{}, false
+	}
+	return created.Add(r.After), true
+}
+
+// matches reports whether every condition of r holds for it
+func (r Rule) matches(it Item) bool {
+	if r.Path != "" && !matchPath(r.Path, it.Path) {
+		return false
+	}
+	for _, t := range r.Tags {
+		if !slices.Contains(it.Tags, t) {
+			return false
+		}
+	}
+	if r.MinSize > 0 && it.Size < r.MinSize {
+		return false
+	}
+	if r.MaxSize > 0 && it.Size > r.MaxSize {
+		return false
+	}
+	return true
+}
+
+// matchPath matches a glob pattern supporting "**" against a slash separated path
+func matchPath(pattern string, p string) bool {
+	p = strings.TrimPrefix(p, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(p))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(p, "/"))
+}
+
+// matchSegments matches pattern segments against path segments
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
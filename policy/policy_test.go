@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.zip", "ci/build-1/app.zip", true},
+		{"*.zip", "app.tar", false},
+		{"ci/*", "ci/app.zip", true},
+		{"ci/*", "ci/build-1/app.zip", false},
+		{"ci/**", "ci/build-1/app.zip", true},
+		{"ci/**", "ci", true},
+		{"ci/**/*.zip", "ci/app.zip", true},
+		{"ci/**/*.zip", "ci/a/b/c/app.zip", true},
+		{"ci/**/*.zip", "ci/a/b/c/app.tar", false},
+		{"**/release/*", "x/y/release/v1.zip", true},
+		{"**/release/*", "release/v1.zip", true},
+		{"/ci/*", "/ci/app.zip", true},
+		{"ci/*", "other/ci/app.zip", false},
+		{"ci/build-?/*", "ci/build-7/app.zip", true},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"expire", Rule{Action: Expire, After: time.Hour}, true},
+		{"keep", Rule{Action: Keep}, true},
+		{"delete", Rule{Action: Delete, After: time.Hour, Path: "ci/**"}, true},
+		{"expire without lifetime", Rule{Action: Expire}, false},
+		{"delete with negative lifetime", Rule{Action: Delete, After: -time.Hour}, false},
+		{"unknown action", Rule{Action: "archive"}, false},
+		{"no action", Rule{}, false},
+		{"bad pattern", Rule{Action: Keep, Path: "ci/[a"}, false},
+	}
+	for _, tt := range tests {
+		err := Policy{Rules: []Rule{{Action: Keep}, tt.rule}}.Validate()
+		if tt.valid != (err == nil) {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestMatchFirstRule(t *testing.T) {
+	p := Policy{Rules: []Rule{
+		{Name: "release", Path: "release/**", Action: Keep},
+		{Name: "tagged", Tags: []string{"ci", "tmp"}, Action: Expire, After: time.Hour},
+		{Name: "large", MinSize: 100, MaxSize: 1000, Action: Expire, After: 2 * time.Hour},
+		{Name: "ci", Path: "ci/**", Action: Expire, After: 14 * 24 * time.Hour},
+	}}
+	tests := []struct {
+		item Item
+		want string // want is empty if no rule matches
+	}{
+		{Item{Path: "release/v1/app.zip", Tags: []string{"ci", "tmp"}, Size: 500}, "release"},
+		{Item{Path: "ci/app.zip", Tags: []string{"tmp", "ci"}}, "tagged"},
+		{Item{Path: "ci/app.zip", Tags: []string{"ci"}, Size: 500}, "large"},
+		{Item{Path: "ci/app.zip", Size: 1001}, "ci"},
+		{Item{Path: "ci/app.zip", Size: 99}, "ci"},
+		{Item{Path: "docs/readme.md"}, ""},
+	}
+	for _, tt := range tests {
+		r, ok := p.Match(tt.item)
+		if ok != (tt.want != "") || r.Name != tt.want {
+			t.Errorf("Match(%+v) = %q, %v, want %q", tt.item, r.Name, ok, tt.want)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if at, ok := (Rule{Action: Expire, After: time.Hour}).Expiry(created); !ok || !at.Equal(created.Add(time.Hour)) {
+		t.Errorf("expire rule gives %v, %v, want an hour after creation", at, ok)
+	}
+	for _, a := range []Action{Keep, Delete} {
+		if _, ok := (Rule{Action: a, After: time.Hour}).Expiry(created); ok {
+			t.Errorf("%s rule sets an expiry", a)
+		}
+	}
+}
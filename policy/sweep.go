@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
+)
+
+// ChangeKind is the kind of change a sweep makes to a content
+type ChangeKind string
+
+const (
+	SetExpiry   ChangeKind = "set-expiry"   // the expiry of the content is set to Change.Expiry
+	ClearExpiry ChangeKind = "clear-expiry" // the expiry of the content is removed
+	Remove      ChangeKind = "delete"       // the content is deleted
+)
+
+// Change is a change made, or planned in a dry run, by a sweep
+type Change struct {
+	ContentID string     // ID of the content
+	Path      string     // path of the content relative to the swept folder
+	Rule      string     // name of the rule that caused the change
+	Kind      ChangeKind // kind of change
+	Expiry    time.Time  // new expiry for SetExpiry
+	Err       error      // error if the change failed, nil on success or in a dry run
+}
+
+// ApplyUpload sets the expiry of a freshly uploaded file according to p.
+//
+// relPath is the path the rules are matched against. It must be the path of the file relative to
+// the folder given to Sweep, so both match the same rules. Delete rules are ignored at upload time.
+//
+// A fresh upload has no tags, tags is what the caller sets on the file right after uploading it.
+// Rules with Tags only match if they are passed here, otherwise a later rule may match at upload
+// and the next Sweep switches the file to the tagged rule.
+//
+// Returns the matched rule, whether one matched, or an error if p is invalid or the expiry could not be set.
+func (p Policy) ApplyUpload(a *api.Api, data model.UploadFileData, relPath string, tags ...string) (Rule, bool, error) {
+	if err := p.Validate(); err != nil {
+		return Rule{}, false, err
+	}
+	// the file was just uploaded, so now is a good estimate of a missing creation time
+	created := data.CreateTime.Time
+	if created.IsZero() {
+		created = time.Now()
+	}
+	r, ok := p.Match(Item{
+		Path:    relPath,
+		Tags:    tags,
+		Size:    data.Size,
+		Created: created,
+	})
+	if !ok {
+		return Rule{}, false, nil
+	}
+	expiry, expires := r.Expiry(created)
+	if !expires {
+		return r, true, nil
+	}
+	if _, err := a.Update(data.ID, model.SetExpiry(expiry)); err != nil {
+		return r, true, fmt.Errorf("apply rule %s: %w", r.Name, err)
+	}
+	return r, true, nil
+}
+
+// Sweep scans the folder with the specified folderID and its subfolders and brings every file
+// in line with p: expiries are set or removed where they differ from the matching rule,
+// and files matched by a Delete rule and older than its After are deleted.
+//
+// Files matching no rule are left untouched, as are files without a creation time matched by
+// an Expire or Delete rule. If dryRun is true nothing is changed.
+//
+// Returns the changes made or planned, or an error if a folder could not be listed.
+func (p Policy) Sweep(a *api.Api, folderID string, dryRun bool) ([]Change, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	if err := p.plan(a, folderID, "", time.Now(), &changes); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return changes, nil
+	}
+
+	var deletions []string
+	for i, c := range changes {
+		switch c.Kind {
+		case SetExpiry, ClearExpiry:
+			_, changes[i].Err = a.Update(c.ContentID, model.SetExpiry(c.Expiry))
+		case Remove:
+			deletions = append(deletions, c.ContentID)
+		}
+	}
+	if len(deletions) == 0 {
+		return changes, nil
+	}
+
+	resp, err := a.DeleteContent(deletions...)
+	for i, c := range changes {
+		if c.Kind != Remove {
+			continue
+		}
+		if err != nil {
+			changes[i].Err = err
+			continue
+		}
+		if st, ok := resp.Data[c.ContentID]; ok && st.Status != "ok" {
+			changes[i].Err = fmt.Errorf("delete failed with status %q", st.Status)
+		}
+	}
+	return changes, nil
+}
+
+// plan lists the changes needed in a folder, dir is the path of the folder relative to the swept folder
+func (p Policy) plan(a *api.Api, folderID string, dir string, now time.Time, changes *[]Change) error {
+	resp, err := a.GetContent(folderID)
+	if err != nil {
+		return err
+	}
+	for _, child := range resp.Data.Children {
+		childPath := path.Join(dir, child.Name)
+		if child.Type == model.FolderType {
+			if err := p.plan(a, child.ID, childPath, now, changes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r, ok := p.Match(Item{
+			Path:    childPath,
+			Tags:    child.TagList(),
+			Size:    child.Size,
+			Created: child.CreateTime.Time,
+		})
+		if !ok {
+			continue
+		}
+		if r.Action != Keep && child.CreateTime.IsZero() {
+			// the lifetime can not be counted without a creation time
+			continue
+		}
+		c := Change{ContentID: child.ID, Path: childPath, Rule: r.Name}
+		current := child.Expiry.Time
+		switch r.Action {
+		case Delete:
+			if now.Sub(child.CreateTime.Time) < r.After {
+				continue
+			}
+			c.Kind = Remove
+		case Keep:
+			if current.IsZero() {
+				continue
+			}
+			c.Kind = ClearExpiry
+		case Expire:
+			want, _ := r.Expiry(child.CreateTime.Time)
+			if current.Unix() == want.Unix() {
+				continue
+			}
+			c.Kind = SetExpiry
+			c.Expiry = want
+		}
+		*changes = append(*changes, c)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package policy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
+)
+
+// handlerTransport answers every request with h instead of sending it over the network
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.h.ServeHTTP(w, req)
+	return w.Result(), nil
+}
+
+// writeData answers with a gofile response with status "ok" and data
+func writeData(w http.ResponseWriter, data any) {
+	json.NewEncoder(w).Encode(map[string]any{"status": "ok", "data": data})
+}
+
+// folders is a fake gofile serving folder listings and recording expiry updates and deletions
+type folders struct {
+	t       *testing.T
+	listing map[string]map[string]any // listing maps a folder ID to its children keyed by ID
+
+	mu      sync.Mutex
+	expiry  map[string]int64 // expiry maps content IDs to the expiry they were updated to
+	deleted []string
+}
+
+func (f *folders) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodDelete:
+		var body struct {
+			ContentsID string `json:"contentsId"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		data := map[string]any{}
+		for _, id := range strings.Split(body.ContentsID, ",") {
+			f.deleted = append(f.deleted, id)
+			data[id] = map[string]any{"status": "ok"}
+		}
+		writeData(w, data)
+	case strings.HasSuffix(r.URL.Path, "/update"):
+		var body struct {
+			Attribute      string `json:"attribute"`
+			AttributeValue int64  `json:"attributeValue"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Attribute != "expiry" {
+			f.t.Errorf("update %s with %+v, %v, want an expiry", r.URL.Path, body, err)
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/contents/"), "/update")
+		if f.expiry == nil {
+			f.expiry = make(map[string]int64)
+		}
+		f.expiry[id] = body.AttributeValue
+		writeData(w, map[string]any{"id": id, "type": "file"})
+	default:
+		id := strings.TrimPrefix(r.URL.Path, "/contents/")
+		writeData(w, map[string]any{"id": id, "type": "folder", "name": id, "children": f.listing[id]})
+	}
+}
+
+// testApi returns an Api whose requests are answered by h
+func testApi(h http.Handler) *api.Api {
+	token, retries := "token", 0
+	return api.New(&api.Options{APIToken: &token, RetryCount: &retries, Transport: handlerTransport{h: h}})
+}
+
+// file returns the listing of a file created at created, expiring at expiry unless it is zero
+func file(id string, name string, tags string, created time.Time, expiry time.Time) map[string]any {
+	f := map[string]any{"id": id, "type": "file", "name": name, "tags": tags, "createTime": created.Unix()}
+	if !expiry.IsZero() {
+		f["expire"] = expiry.Unix()
+	}
+	return f
+}
+
+const day = 24 * time.Hour
+
+// ciPolicy keeps releases, deletes temporary CI builds after 14 days and expires other CI builds after 14 days
+var ciPolicy = Policy{Rules: []Rule{
+	{Name: "release", Path: "release/**", Action: Keep},
+	{Name: "tmp", Path: "ci/**", Tags: []string{"tmp"}, Action: Delete, After: 14 * day},
+	{Name: "ci", Path: "ci/**", Action: Expire, After: 14 * day},
+}}
+
+// sweepFolders returns a root folder with a release, four CI builds and an unmatched file
+func sweepFolders(t *testing.T, now time.Time) *folders {
+	recent := now.Add(-2 * day).Truncate(time.Second)
+	return &folders{t: t, listing: map[string]map[string]any{
+		"root": {
+			"rel":  map[string]any{"id": "rel", "type": "folder", "name": "release"},
+			"ci":   map[string]any{"id": "ci", "type": "folder", "name": "ci"},
+			"docs": file("docs", "readme.md", "", recent, time.Time{}),
+		},
+		"rel": {"v1": file("v1", "v1.zip", "", recent, now.Add(day))},
+		"ci": {
+			"old":     file("old", "old.zip", "tmp", now.Add(-20*day), time.Time{}),
+			"fresh":   file("fresh", "fresh.zip", "tmp", recent, time.Time{}),
+			"new":     file("new", "new.zip", "", recent, time.Time{}),
+			"current": file("current", "current.zip", "", recent, recent.Add(14*day)),
+		},
+	}}
+}
+
+// kinds returns the change kind of every content in changes
+func kinds(changes []Change) map[string]ChangeKind {
+	got := make(map[string]ChangeKind)
+	for _, c := range changes {
+		got[c.ContentID] = c.Kind
+	}
+	return got
+}
+
+func TestSweepDryRun(t *testing.T) {
+	now := time.Now()
+	f := sweepFolders(t, now)
+	changes, err := ciPolicy.Sweep(testApi(f), "root", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ChangeKind{"v1": ClearExpiry, "old": Remove, "new": SetExpiry}
+	got := kinds(changes)
+	if len(got) != len(want) {
+		t.Errorf("planned %v, want %v", got, want)
+	}
+	for id, k := range want {
+		if got[id] != k {
+			t.Errorf("planned %s for %s, want %s", got[id], id, k)
+		}
+	}
+	if len(f.expiry) != 0 || len(f.deleted) != 0 {
+		t.Errorf("dry run changed expiries %v and deleted %v", f.expiry, f.deleted)
+	}
+}
+
+func TestSweepApply(t *testing.T) {
+	now := time.Now()
+	f := sweepFolders(t, now)
+	changes, err := ciPolicy.Sweep(testApi(f), "root", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Err != nil {
+			t.Errorf("%s of %s failed: %v", c.Kind, c.Path, c.Err)
+		}
+	}
+	created := now.Add(-2 * day).Truncate(time.Second)
+	if got := f.expiry["new"]; got != created.Add(14*day).Unix() {
+		t.Errorf("new.zip expires at %d, want %d", got, created.Add(14*day).Unix())
+	}
+	if got, ok := f.expiry["v1"]; !ok || got != 0 {
+		t.Errorf("release expiry set to %d, %v, want it cleared", got, ok)
+	}
+	if !slices.Equal(f.deleted, []string{"old"}) {
+		t.Errorf("deleted %v, want only old.zip", f.deleted)
+	}
+}
+
+func TestSweepInvalidPolicy(t *testing.T) {
+	f := sweepFolders(t, time.Now())
+	p := Policy{Rules: []Rule{{Name: "broken", Action: Expire}}}
+	if _, err := p.Sweep(testApi(f), "root", true); err == nil {
+		t.Error("sweep with an invalid policy succeeded")
+	}
+}
+
+func TestApplyUploadTags(t *testing.T) {
+	p := Policy{Rules: []Rule{
+		{Name: "tmp", Tags: []string{"tmp"}, Action: Expire, After: day},
+		{Name: "ci", Path: "ci/**", Action: Expire, After: 14 * day},
+	}}
+	created := time.Now().Truncate(time.Second)
+	data := model.UploadFileData{ID: "up", Name: "app.zip", CreateTime: model.NewUnixTime(created)}
+
+	f := &folders{t: t}
+	r, ok, err := p.ApplyUpload(testApi(f), data, "ci/app.zip", "tmp")
+	if err != nil || !ok || r.Name != "tmp" {
+		t.Fatalf("ApplyUpload with tags matched %q, %v, %v, want tmp", r.Name, ok, err)
+	}
+	if f.expiry["up"] != created.Add(day).Unix() {
+		t.Errorf("expiry set to %d, want a day after the upload", f.expiry["up"])
+	}
+
+	r, ok, err = p.ApplyUpload(testApi(f), data, "ci/app.zip")
+	if err != nil || !ok || r.Name != "ci" {
+		t.Errorf("ApplyUpload without tags matched %q, %v, %v, want ci", r.Name, ok, err)
+	}
+}
@@ -0,0 +1,264 @@
+// package relay runs an HTTP upload relay so services without the gofile token can upload
+//
+// Trusted clients authenticate with an API key or a TLS client certificate and send a file either
+// as multipart/form-data or as the raw request body. The file is streamed to gofile without being
+// written to disk, stored in the folder picked by the routing rules, and the UploadFileData of the
+// new file is returned as JSON:
+//
+//	s, err := relay.New(c, &relay.Options{APIKeys: map[string]string{key: "backup-job"}})
+//	http.ListenAndServe("localhost:8080", s)
+//
+// Raw uploads take the file name from the "name" query parameter or the Content-Disposition header:
+//
+//	curl -H "Authorization: Bearer $KEY" --data-binary @db.tar "http://localhost:8080/?name=db.tar"
+package relay
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
+)
+
+var (
+	// ErrNoAuth is returned by New when neither API keys nor client certificates are enabled
+	ErrNoAuth = errors.New("relay: no authentication method configured")
+
+	errUnauthorized = errors.New("missing or invalid credentials")
+	errNoFile       = errors.New("no file in request")
+	errNoName       = errors.New("missing file name")
+)
+
+// Options defines configuration for the relay server.
+type Options struct {
+	APIKeys     map[string]string // APIKeys maps accepted API keys to client names, sent as "Authorization: Bearer <key>" or "X-Api-Key"
+	ClientCerts bool              // ClientCerts accepts clients presenting a certificate verified by the TLS server, named by their common name
+	FolderID    string            // FolderID receives uploads no route matches, the account's root folder is used if empty
+	Routes      []Route           // Routes send uploads to folders by client and file name
+	Server      string            // Server is the upload server name, if empty one is picked using GetAvailableServers
+	Zone        string            // Zone is passed to GetAvailableServers when Server is empty, can be "eu" or "na"
+	Logger      *slog.Logger      // Logger receives a log entry per upload. Logs are discarded if nil
+}
+
+// Server is an http.Handler relaying uploads to gofile
+type Server struct {
+	api    *api.Api
+	opts   Options
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	server string
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// New creates a relay server uploading with the token of a.
+//
+// If opts.FolderID is empty, the account's root folder is resolved so uploads are never sent without a folder,
+// which would make gofile create a new public folder for each of them.
+// Returns the server, ErrNoAuth if no authentication method is enabled or an error if a route is invalid
+// or the root folder could not be resolved.
+func New(a *api.Api, opts *Options) (*Server, error) {
+	if opts == nil || (len(opts.APIKeys) == 0 && !opts.ClientCerts) {
+		return nil, ErrNoAuth
+	}
+	if err := validateRoutes(opts.Routes); err != nil {
+		return nil, err
+	}
+	o := *opts
+	if o.FolderID == "" {
+		account, err := a.Account()
+		if err != nil {
+			return nil, err
+		}
+		o.FolderID = account.RootFolder
+	}
+	logger := slog.New(slog.DiscardHandler)
+	if opts.Logger != nil {
+		logger = opts.Logger
+	}
+	return &Server{
+		api:    a,
+		opts:   o,
+		logger: logger,
+		server: opts.Server,
+	}, nil
+}
+
+// TLSConfig returns a TLS server configuration verifying client certificates signed by clientCAs,
+// for use with Options.ClientCerts.
+//
+// Set optional when Options.APIKeys are accepted too, clients without a certificate can then connect
+// and authenticate with a key. Otherwise the handshake fails for clients without a valid certificate.
+func TLSConfig(clientCAs *x509.CertPool, optional bool) *tls.Config {
+	clientAuth := tls.RequireAndVerifyClientCert
+	if optional {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	return &tls.Config{
+		ClientAuth: clientAuth,
+		ClientCAs:  clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// ServeHTTP authenticates the client and relays the uploaded file
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	client, ok := s.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+
+	var data model.UploadFileData
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		data, err = s.relayMultipart(r, client)
+	} else {
+		data, err = s.relayRaw(r, client)
+	}
+	if err != nil {
+		s.logger.Error("relay upload failed", "client", client, "error", err)
+		writeError(w, statusOf(err), err)
+		return
+	}
+	s.logger.Info("relayed upload", "client", client, "name", data.Name, "id", data.ID, "folder", data.ParentFolder, "size", data.Size)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// authenticate returns the name of the client sending r
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	if s.opts.ClientCerts && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+	}
+	key := r.Header.Get("X-Api-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	if key == "" {
+		return "", false
+	}
+	// compare against every key so the time taken does not reveal a match
+	var name string
+	for k, n := range s.opts.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			name = n
+		}
+	}
+	return name, name != ""
+}
+
+// relayMultipart streams the first file part of a multipart/form-data request
+func (s *Server) relayMultipart(r *http.Request, client string) (model.UploadFileData, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return model.UploadFileData{}, &requestError{err}
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return model.UploadFileData{}, &requestError{errNoFile}
+		}
+		if err != nil {
+			return model.UploadFileData{}, &requestError{err}
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		return s.relay(client, filepath.Base(part.FileName()), part, -1)
+	}
+}
+
+// relayRaw streams the request body, named by the "name" query parameter or Content-Disposition
+func (s *Server) relayRaw(r *http.Request, client string) (model.UploadFileData, error) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil {
+			name = params["filename"]
+		}
+	}
+	if name == "" {
+		return model.UploadFileData{}, &requestError{errNoName}
+	}
+	return s.relay(client, filepath.Base(name), r.Body, r.ContentLength)
+}
+
+// relay uploads r as name into the folder routed for client
+func (s *Server) relay(client string, name string, r io.Reader, size int64) (model.UploadFileData, error) {
+	server, err := s.uploadServer()
+	if err != nil {
+		return model.UploadFileData{}, err
+	}
+	resp, err := s.api.UploadReader(server, name, r, size, s.route(client, name), nil)
+	if err != nil {
+		return model.UploadFileData{}, err
+	}
+	return resp.Data, nil
+}
+
+// uploadServer returns the configured upload server or picks one
+func (s *Server) uploadServer() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.server != "" {
+		return s.server, nil
+	}
+	resp, err := s.api.GetAvailableServers(s.opts.Zone)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Data.Servers) == 0 {
+		return "", errors.New("no upload server available")
+	}
+	s.server = resp.Data.Servers[0].Name
+	return s.server, nil
+}
+
+// requestError is an error caused by a malformed request
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
+
+// statusOf returns the HTTP status reported to the client for err
+func statusOf(err error) int {
+	var reqErr *requestError
+	var valErr *api.ValidationError
+	switch {
+	case errors.As(err, &reqErr), errors.As(err, &valErr):
+		return http.StatusBadRequest
+	case errors.Is(err, api.ErrRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// writeError writes err as a JSON error document
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,417 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
+)
+
+// upload is a file received by gofileServer
+type upload struct {
+	folder  string
+	name    string
+	content string
+}
+
+// gofileServer answers gofile upload requests and records the uploaded files
+type gofileServer struct {
+	mu      sync.Mutex
+	uploads []upload
+}
+
+func (g *gofileServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != "/contents/uploadfile" {
+		return nil, errors.New("unexpected request " + req.Method + " " + req.URL.String())
+	}
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	var u upload
+	mr := multipart.NewReader(req.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		switch part.FormName() {
+		case "folderId":
+			u.folder = string(data)
+		case "file":
+			u.name, u.content = part.FileName(), string(data)
+		}
+	}
+	g.mu.Lock()
+	g.uploads = append(g.uploads, u)
+	g.mu.Unlock()
+
+	body, _ := json.Marshal(map[string]any{"status": "ok", "data": map[string]any{
+		"id": "new", "type": "file", "name": u.name, "size": len(u.content), "parentFolder": u.folder,
+	}})
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// last returns the last uploaded file
+func (g *gofileServer) last(t *testing.T) upload {
+	t.Helper()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.uploads) == 0 {
+		t.Fatal("nothing was uploaded")
+	}
+	return g.uploads[len(g.uploads)-1]
+}
+
+// testServer returns a relay uploading to a gofileServer into the folder "root" by default
+func testServer(t *testing.T, opts Options) (*Server, *gofileServer) {
+	t.Helper()
+	g := &gofileServer{}
+	token, retries := "token", 0
+	a := api.New(&api.Options{APIToken: &token, RetryCount: &retries, Transport: g})
+	opts.FolderID = "root"
+	opts.Server = "store1"
+	s, err := New(a, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, g
+}
+
+// serve sends r to s and returns the recorded response
+func serve(s *Server, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// raw returns a raw upload request of content named name
+func raw(name string, content string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/?name="+name, strings.NewReader(content))
+}
+
+// withKey sets the API key of r in the Authorization header
+func withKey(r *http.Request, key string) *http.Request {
+	r.Header.Set("Authorization", "Bearer "+key)
+	return r
+}
+
+// withCert makes r look like it was sent over TLS with a verified client certificate named cn
+func withCert(r *http.Request, cn string) *http.Request {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return r
+}
+
+func TestNewRequiresAuth(t *testing.T) {
+	if _, err := New(api.New(nil), &Options{FolderID: "root"}); !errors.Is(err, ErrNoAuth) {
+		t.Errorf("New without keys or certificates: got %v, want ErrNoAuth", err)
+	}
+	_, err := New(api.New(nil), &Options{FolderID: "root", APIKeys: map[string]string{"k": "c"}, Routes: []Route{{Name: "[", FolderID: "f"}}})
+	if err == nil {
+		t.Error("New accepted an invalid route pattern")
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	s, _ := testServer(t, Options{APIKeys: map[string]string{"secret-1": "backup", "secret-2": "scanner"}})
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"bearer", withKey(raw("a.txt", "a"), "secret-1"), http.StatusOK},
+		{"header", func() *http.Request {
+			r := raw("a.txt", "a")
+			r.Header.Set("X-Api-Key", "secret-2")
+			return r
+		}(), http.StatusOK},
+		{"no key", raw("a.txt", "a"), http.StatusUnauthorized},
+		{"wrong key", withKey(raw("a.txt", "a"), "secret-3"), http.StatusUnauthorized},
+		{"prefix of a key", withKey(raw("a.txt", "a"), "secret"), http.StatusUnauthorized},
+		{"key with suffix", withKey(raw("a.txt", "a"), "secret-10"), http.StatusUnauthorized},
+		{"certificate not enabled", withCert(raw("a.txt", "a"), "backup"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(s, tt.req); w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestClientCertAuth(t *testing.T) {
+	s, g := testServer(t, Options{ClientCerts: true, Routes: []Route{{Client: "scanner", FolderID: "scans"}}})
+
+	if w := serve(s, withCert(raw("a.pdf", "a"), "scanner")); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if u := g.last(t); u.folder != "scans" {
+		t.Errorf("uploaded into %s, want the folder routed for the common name", u.folder)
+	}
+	// a certificate the TLS server did not verify is ignored
+	r := raw("a.pdf", "a")
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "scanner"}}}}
+	if w := serve(s, r); w.Code != http.StatusUnauthorized {
+		t.Errorf("unverified certificate: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := serve(s, raw("a.pdf", "a")); w.Code != http.StatusUnauthorized {
+		t.Errorf("plain HTTP: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s, g := testServer(t, Options{
+		APIKeys: map[string]string{"k1": "backup", "k2": "web"},
+		Routes: []Route{
+			{Client: "backup", Name: "*.tar", FolderID: "tars"},
+			{Name: "*.log", FolderID: "logs"},
+			{Client: "backup", FolderID: "backups"},
+		},
+	})
+
+	tests := []struct {
+		key  string
+		name string
+		want string
+	}{
+		{"k1", "db.tar", "tars"},
+		{"k2", "db.tar", "root"},
+		{"k1", "app.log", "logs"},
+		{"k2", "app.log", "logs"},
+		{"k1", "notes.txt", "backups"},
+		{"k2", "notes.txt", "root"},
+	}
+	for _, tt := range tests {
+		if w := serve(s, withKey(raw(tt.name, "x"), tt.key)); w.Code != http.StatusOK {
+			t.Fatalf("%s by %s: status %d: %s", tt.name, tt.key, w.Code, w.Body)
+		}
+		if u := g.last(t); u.folder != tt.want {
+			t.Errorf("%s by %s: uploaded into %s, want %s", tt.name, tt.key, u.folder, tt.want)
+		}
+	}
+}
+
+func TestRawUpload(t *testing.T) {
+	s, g := testServer(t, Options{APIKeys: map[string]string{"k": "c"}})
+
+	w := serve(s, withKey(raw("../db.tar", "database"), "k"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var data model.UploadFileData
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if u := g.last(t); u.name != "db.tar" || u.content != "database" || data.Name != "db.tar" {
+		t.Errorf("uploaded %+v and returned %+v, want db.tar holding the body", u, data)
+	}
+
+	r := withKey(httptest.NewRequest(http.MethodPut, "/", strings.NewReader("report")), "k")
+	r.Header.Set("Content-Disposition", `attachment; filename="report.pdf"`)
+	if w := serve(s, r); w.Code != http.StatusOK {
+		t.Fatalf("Content-Disposition: status %d: %s", w.Code, w.Body)
+	}
+	if u := g.last(t); u.name != "report.pdf" || u.content != "report" {
+		t.Errorf("Content-Disposition: uploaded %+v, want report.pdf", u)
+	}
+
+	r = withKey(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x")), "k")
+	if w := serve(s, r); w.Code != http.StatusBadRequest {
+		t.Errorf("no name: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+// form returns a multipart/form-data upload request with a text field followed by files, given as name and content pairs
+func form(t *testing.T, files ...string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("comment", "nightly"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(files); i += 2 {
+		fw, err := mw.CreateFormFile("file", files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, files[i+1])
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return withKey(r, "k")
+}
+
+func TestMultipartUpload(t *testing.T) {
+	s, g := testServer(t, Options{APIKeys: map[string]string{"k": "c"}})
+
+	if w := serve(s, form(t, "dir/photo.jpg", "jpeg", "other.jpg", "other")); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if u := g.last(t); u.name != "photo.jpg" || u.content != "jpeg" {
+		t.Errorf("uploaded %+v, want the first file part named photo.jpg", u)
+	}
+	if w := serve(s, form(t)); w.Code != http.StatusBadRequest {
+		t.Errorf("no file part: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+// clientCA returns a pool holding a new CA and a client certificate named cn signed by it
+func clientCA(t *testing.T, cn string) (*x509.CertPool, tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return pool, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// tlsServer serves s over TLS with TLSConfig(pool, optional)
+func tlsServer(t *testing.T, s *Server, pool *x509.CertPool, optional bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(s)
+	srv.TLS = TLSConfig(pool, optional)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// post sends a raw upload of a.pdf to srv, with the client certificate cert if it is not nil and the API key if not empty
+func post(srv *httptest.Server, cert *tls.Certificate, key string) (int, error) {
+	tr := srv.Client().Transport.(*http.Transport).Clone()
+	if cert != nil {
+		tr.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+	}
+	defer tr.CloseIdleConnections()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/?name=a.pdf", strings.NewReader("a"))
+	if err != nil {
+		return 0, err
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestClientCertsAndAPIKeys(t *testing.T) {
+	pool, cert := clientCA(t, "scanner")
+	s, g := testServer(t, Options{
+		ClientCerts: true,
+		APIKeys:     map[string]string{"k": "backup"},
+		Routes:      []Route{{Client: "scanner", FolderID: "scans"}, {Client: "backup", FolderID: "backups"}},
+	})
+	srv := tlsServer(t, s, pool, true)
+
+	tests := []struct {
+		name   string
+		cert   *tls.Certificate
+		key    string
+		status int
+		folder string
+	}{
+		{"certificate", &cert, "", http.StatusOK, "scans"},
+		{"API key", nil, "k", http.StatusOK, "backups"},
+		{"neither", nil, "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		status, err := post(srv, tt.cert, tt.key)
+		if err != nil || status != tt.status {
+			t.Errorf("%s: status %d, error %v, want %d", tt.name, status, err, tt.status)
+			continue
+		}
+		if tt.folder != "" {
+			if u := g.last(t); u.folder != tt.folder {
+				t.Errorf("%s: uploaded into %s, want %s", tt.name, u.folder, tt.folder)
+			}
+		}
+	}
+}
+
+func TestClientCertsRequired(t *testing.T) {
+	pool, cert := clientCA(t, "scanner")
+	s, _ := testServer(t, Options{ClientCerts: true})
+	srv := tlsServer(t, s, pool, false)
+
+	if status, err := post(srv, &cert, ""); err != nil || status != http.StatusOK {
+		t.Errorf("certificate: status %d, error %v, want %d", status, err, http.StatusOK)
+	}
+	if _, err := post(srv, nil, ""); err == nil {
+		t.Error("the handshake succeeded without a client certificate")
+	}
+}
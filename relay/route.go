@@ -0,0 +1,50 @@
+package relay
+
+import (
+	"fmt"
+	"path"
+)
+
+// Route sends uploads matching it into a folder
+//
+// An empty Client or Name matches everything, routes are tried in order and the first match wins.
+type Route struct {
+	Client   string // Client is the name of the client, either its API key name or certificate common name
+	Name     string // Name is a path.Match pattern matched against the uploaded file name, e.g. "*.log"
+	FolderID string // FolderID is the folder receiving matching uploads
+}
+
+// matches reports whether the upload of name by client matches r
+func (r Route) matches(client string, name string) bool {
+	if r.Client != "" && r.Client != client {
+		return false
+	}
+	if r.Name == "" {
+		return true
+	}
+	ok, _ := path.Match(r.Name, name)
+	return ok
+}
+
+// validateRoutes checks that every route has a folder and a valid pattern
+func validateRoutes(routes []Route) error {
+	for i, r := range routes {
+		if r.FolderID == "" {
+			return fmt.Errorf("route %d: folder ID is required", i)
+		}
+		if _, err := path.Match(r.Name, ""); err != nil {
+			return fmt.Errorf("route %d: invalid name pattern %q: %w", i, r.Name, err)
+		}
+	}
+	return nil
+}
+
+// route returns the folder receiving the upload of name by client
+func (s *Server) route(client string, name string) string {
+	for _, r := range s.opts.Routes {
+		if r.matches(client, name) {
+			return r.FolderID
+		}
+	}
+	return s.opts.FolderID
+}
@@ -0,0 +1,126 @@
+package s3gateway
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errMalformedChunk is returned when an aws-chunked body can not be decoded
+var errMalformedChunk = errors.New("malformed aws-chunked body")
+
+// chunkedReader decodes the aws-chunked encoding S3 clients use for streaming uploads.
+//
+// Each chunk is "<hex size>[;chunk-signature=...]\r\n<data>\r\n", the last chunk has size 0
+// and may be followed by trailing headers. Signatures and trailing checksums are not verified.
+type chunkedReader struct {
+	r         *bufio.Reader
+	remaining int64 // bytes left in the current chunk
+	done      bool
+}
+
+// requestBody returns the decoded body of r and its size, or -1 if unknown
+func requestBody(r *http.Request) (io.Reader, int64) {
+	if !isChunked(r) {
+		return r.Body, r.ContentLength
+	}
+	size := int64(-1)
+	if v := r.Header.Get("X-Amz-Decoded-Content-Length"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			size = n
+		}
+	}
+	return &chunkedReader{r: bufio.NewReader(r.Body)}, size
+}
+
+// isChunked reports whether the body of r uses the aws-chunked encoding
+func isChunked(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") ||
+		strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked")
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining == 0 && err == nil {
+		err = c.expectCRLF()
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextChunk reads the next chunk header, or the trailer after the last chunk
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeField, _, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("%w: invalid chunk size %q", errMalformedChunk, sizeField)
+	}
+	if size > 0 {
+		c.remaining = size
+		return nil
+	}
+	// skip trailing headers up to the final empty line, some clients end the body without it
+	for {
+		line, err := c.readLine()
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+	}
+	c.done = true
+	return nil
+}
+
+// readLine reads a CRLF terminated line without its terminator
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// expectCRLF consumes the CRLF ending a chunk's data
+func (c *chunkedReader) expectCRLF() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return fmt.Errorf("%w: missing chunk terminator", errMalformedChunk)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package s3gateway
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chunkedRequest returns a PUT request whose aws-chunked body is body
+func chunkedRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader(body))
+	r.Header.Set("X-Amz-Content-Sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD")
+	r.Header.Set("X-Amz-Decoded-Content-Length", "11")
+	return r
+}
+
+func TestChunkedDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"signed chunks", "6;chunk-signature=aa\r\nhello \r\n5;chunk-signature=bb\r\nworld\r\n0;chunk-signature=cc\r\n\r\n"},
+		{"unsigned chunks", "6\r\nhello \r\n5\r\nworld\r\n0\r\n\r\n"},
+		{"trailing checksum", "b\r\nhello world\r\n0\r\nx-amz-checksum-crc32:DUoRhQ==\r\n\r\n"},
+		{"no final empty line", "b\r\nhello world\r\n0\r\n"},
+	}
+	for _, tt := range tests {
+		body, size := requestBody(chunkedRequest(tt.body))
+		if size != 11 {
+			t.Errorf("%s: size = %d, want 11", tt.name, size)
+		}
+		got, err := io.ReadAll(body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != "hello world" {
+			t.Errorf("%s: decoded %q, want %q", tt.name, got, "hello world")
+		}
+	}
+}
+
+func TestChunkedMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{"invalid size", "zz\r\nhello\r\n0\r\n\r\n", errMalformedChunk},
+		{"negative size", "-5\r\nhello\r\n0\r\n\r\n", errMalformedChunk},
+		{"missing terminator", "5\r\nhelloX\r\n0\r\n\r\n", errMalformedChunk},
+		{"truncated data", "b\r\nhello", io.ErrUnexpectedEOF},
+		{"no last chunk", "5\r\nhello\r\n", io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		body, _ := requestBody(chunkedRequest(tt.body))
+		if _, err := io.ReadAll(body); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPlainBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("5\r\nhello\r\n0\r\n\r\n"))
+	body, size := requestBody(r)
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(got)) || string(got) != "5\r\nhello\r\n0\r\n\r\n" {
+		t.Errorf("a body without aws-chunked encoding was decoded: %q (%d bytes)", got, size)
+	}
+}
@@ -0,0 +1,288 @@
+// package s3gateway serves a subset of the S3 API in front of a gofile account
+//
+// Top-level folders are exposed as buckets and object keys map to paths below them, folders
+// missing from a key are created on upload. Supported operations are ListBuckets, CreateBucket,
+// HeadBucket, DeleteBucket, ListObjectsV2, PutObject, GetObject, HeadObject, DeleteObject and
+// multipart uploads, whose parts are buffered to temporary files and streamed to gofile on completion.
+//
+// Only path-style requests are supported and request signatures are not verified, the gateway
+// uses the token of its *api.Api for every request and is meant to run on a trusted network:
+//
+//	g, err := s3gateway.New(c, nil)
+//	http.ListenAndServe("localhost:9000", g)
+package s3gateway
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/gofilefs"
+	"github.com/plutack/go-gofile/model"
+)
+
+// Options defines optional configuration for the gateway.
+type Options struct {
+	FolderID string         // FolderID is the folder whose subfolders are exposed as buckets, defaults to the account's root folder
+	Server   string         // Server is the upload server name, if empty one is picked using GetAvailableServers
+	Zone     string         // Zone is passed to GetAvailableServers when Server is empty, can be "eu" or "na"
+	TempDir  string         // TempDir is where multipart upload parts are buffered, defaults to os.TempDir()
+	CacheTTL *time.Duration // CacheTTL specifies how long a folder listing is reused, see gofilefs.Options
+	// UploadExpiry is how long a multipart upload is kept without activity before its buffered parts
+	// are deleted, defaults to 24 hours. Parts left in TempDir by earlier runs are deleted after it as well.
+	UploadExpiry *time.Duration
+}
+
+// Gateway is an http.Handler translating S3 requests into gofile API calls
+type Gateway struct {
+	api          *api.Api
+	root         string
+	fsys         *gofilefs.FS
+	tempDir      string
+	uploadExpiry time.Duration
+
+	mu     sync.Mutex
+	server string
+	zone   string
+
+	uploadsMu sync.Mutex
+	uploads   map[string]*multipartUpload // upload ID to its in-progress multipart upload
+}
+
+var _ http.Handler = (*Gateway)(nil)
+
+// New creates a gateway exposing the subfolders of opts.FolderID as buckets.
+//
+// If opts is nil, the subfolders of the account's root folder are exposed.
+// Returns the gateway or an error if the root folder could not be resolved.
+func New(a *api.Api, opts *Options) (*Gateway, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	root := opts.FolderID
+	if root == "" {
+		account, err := a.Account()
+		if err != nil {
+			return nil, err
+		}
+		root = account.RootFolder
+	}
+	tempDir := opts.TempDir
+	if tempDir == "" {
+		tempDir = os.TempDir()
+	}
+	expiry := defaultUploadExpiry
+	if opts.UploadExpiry != nil {
+		expiry = *opts.UploadExpiry
+	}
+	removeStaleTempDirs(tempDir, expiry)
+	return &Gateway{
+		api:          a,
+		root:         root,
+		fsys:         gofilefs.New(a, root, &gofilefs.Options{CacheTTL: opts.CacheTTL}),
+		tempDir:      tempDir,
+		uploadExpiry: expiry,
+		server:       opts.Server,
+		zone:         opts.Zone,
+		uploads:      make(map[string]*multipartUpload),
+	}, nil
+}
+
+// ServeHTTP routes a path-style S3 request to the matching operation
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+
+	switch {
+	case bucket == "":
+		if r.Method != http.MethodGet {
+			writeError(w, r, errNotImplemented)
+			return
+		}
+		g.listBuckets(w, r)
+	case key == "":
+		switch r.Method {
+		case http.MethodGet:
+			g.listObjects(w, r, bucket)
+		case http.MethodHead:
+			g.headBucket(w, r, bucket)
+		case http.MethodPut:
+			g.createBucket(w, r, bucket)
+		case http.MethodDelete:
+			g.deleteBucket(w, r, bucket)
+		default:
+			writeError(w, r, errNotImplemented)
+		}
+	default:
+		switch {
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			g.createMultipartUpload(w, r, bucket, key)
+		case r.Method == http.MethodPost && q.Has("uploadId"):
+			g.completeMultipartUpload(w, r, bucket, key, q.Get("uploadId"))
+		case r.Method == http.MethodPut && q.Has("uploadId"):
+			g.uploadPart(w, r, q.Get("uploadId"), q.Get("partNumber"))
+		case r.Method == http.MethodDelete && q.Has("uploadId"):
+			g.abortMultipartUpload(w, r, q.Get("uploadId"))
+		case r.Method == http.MethodPut:
+			g.putObject(w, r, bucket, key)
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			g.getObject(w, r, bucket, key)
+		case r.Method == http.MethodDelete:
+			g.deleteObject(w, r, bucket, key)
+		default:
+			writeError(w, r, errNotImplemented)
+		}
+	}
+}
+
+// uploadServer returns the configured upload server or picks one
+func (g *Gateway) uploadServer() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.server != "" {
+		return g.server, nil
+	}
+	resp, err := g.api.GetAvailableServers(g.zone)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Data.Servers) == 0 {
+		return "", errors.New("no upload server available")
+	}
+	g.server = resp.Data.Servers[0].Name
+	return g.server, nil
+}
+
+// content returns the gofile content at the slash separated name, relative to the root folder
+func (g *Gateway) content(name string) (model.Content, error) {
+	fi, err := g.fsys.Stat(name)
+	if err != nil {
+		return model.Content{}, err
+	}
+	return fi.Sys().(model.Content), nil
+}
+
+// bucket returns the folder backing bucket
+func (g *Gateway) bucket(bucket string) (model.Content, error) {
+	if !fs.ValidPath(bucket) || strings.Contains(bucket, "/") || bucket == "." {
+		return model.Content{}, errNoSuchBucket
+	}
+	c, err := g.content(bucket)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && c.Type != model.FolderType) {
+		return model.Content{}, errNoSuchBucket
+	}
+	return c, err
+}
+
+// mkdirAll returns the folder at dir inside bucket, creating the missing folders
+func (g *Gateway) mkdirAll(bucket string, dir string) (model.Content, error) {
+	current, err := g.bucket(bucket)
+	if err != nil {
+		return model.Content{}, err
+	}
+	name := bucket
+	for _, part := range strings.Split(dir, "/") {
+		if part == "" {
+			continue
+		}
+		name = path.Join(name, part)
+		next, err := g.content(name)
+		switch {
+		case err == nil && next.Type != model.FolderType:
+			return model.Content{}, &s3Error{Code: "InvalidArgument", Message: name + " is a file", Status: http.StatusBadRequest}
+		case errors.Is(err, fs.ErrNotExist):
+			resp, err := g.api.CreateFolder(current.ID, part)
+			if err != nil {
+				return model.Content{}, err
+			}
+			g.fsys.Invalidate()
+			next = model.Content{ID: resp.Data.ID, Type: model.FolderType, Name: part}
+		case err != nil:
+			return model.Content{}, err
+		}
+		current = next
+	}
+	return current, nil
+}
+
+func (g *Gateway) listBuckets(w http.ResponseWriter, r *http.Request) {
+	entries, err := g.fsys.ReadDir(".")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result := listAllMyBucketsResult{Xmlns: s3Namespace, Owner: owner{ID: g.root}}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		result.Buckets = append(result.Buckets, bucketXML{Name: e.Name(), CreationDate: s3Time(fi.ModTime())})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (g *Gateway) headBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	if _, err := g.bucket(bucket); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) createBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !fs.ValidPath(bucket) || bucket == "." {
+		writeError(w, r, &s3Error{Code: "InvalidBucketName", Message: "invalid bucket name", Status: http.StatusBadRequest})
+		return
+	}
+	// only a missing bucket is created, a failed lookup must not create a duplicate folder
+	switch _, err := g.bucket(bucket); {
+	case err == nil:
+		writeError(w, r, &s3Error{Code: "BucketAlreadyOwnedByYou", Message: "bucket already exists", Status: http.StatusConflict})
+		return
+	case !errors.Is(err, errNoSuchBucket):
+		writeError(w, r, err)
+		return
+	}
+	if _, err := g.api.CreateFolder(g.root, bucket); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	g.fsys.Invalidate()
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	c, err := g.bucket(bucket)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	entries, err := g.fsys.ReadDir(bucket)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(entries) > 0 {
+		writeError(w, r, &s3Error{Code: "BucketNotEmpty", Message: "the bucket is not empty", Status: http.StatusConflict})
+		return
+	}
+	if _, err := g.api.DeleteContent(c.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	g.fsys.Invalidate()
+	w.WriteHeader(http.StatusNoContent)
+}
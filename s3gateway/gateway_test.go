@@ -0,0 +1,415 @@
+package s3gateway
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/cassette"
+)
+
+// requestLog records the method and path of every request sent through it
+type requestLog struct {
+	rt http.RoundTripper
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (l *requestLog) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	l.sent = append(l.sent, req.Method+" "+req.URL.Path)
+	l.mu.Unlock()
+	return l.rt.RoundTrip(req)
+}
+
+// has reports whether a request with method and path was sent
+func (l *requestLog) has(method string, path string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.sent, method+" "+path)
+}
+
+// count returns the number of requests sent with method and path
+func (l *requestLog) count(method string, path string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, s := range l.sent {
+		if s == method+" "+path {
+			n++
+		}
+	}
+	return n
+}
+
+// interaction returns a recorded gofile response with status "ok" and data
+func interaction(method string, path string, data any) cassette.Interaction {
+	body, _ := json.Marshal(map[string]any{"status": "ok", "data": data})
+	return cassette.Interaction{
+		Request: cassette.Request{Method: method, URL: "https://api.gofile.io" + path},
+		Response: cassette.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       body,
+		},
+	}
+}
+
+// matchPath matches interactions on method and URL path only, uploads have a random multipart body
+func matchPath(req *http.Request, body []byte, i cassette.Interaction) bool {
+	u, err := url.Parse(i.Request.URL)
+	return err == nil && req.Method == i.Request.Method && req.URL.Path == u.Path
+}
+
+// replay returns a gateway over a gofile account answering from interactions, each answering a single request
+func replay(t *testing.T, opts *Options, interactions ...cassette.Interaction) (*Gateway, *requestLog) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	data, err := json.Marshal(interactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := cassette.New(path, cassette.ModeReplay, &cassette.Options{Match: matchPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &requestLog{rt: rec}
+	token, retries := "token", 0
+	a := api.New(&api.Options{APIToken: &token, RetryCount: &retries, Transport: log})
+
+	if opts == nil {
+		opts = &Options{}
+	}
+	opts.FolderID = "root"
+	opts.Server = "store1"
+	if opts.TempDir == "" {
+		opts.TempDir = t.TempDir()
+	}
+	g, err := New(a, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, log
+}
+
+// account returns the listings of a root folder holding the bucket "photos" with the files a.txt and b.txt
+func account() []cassette.Interaction {
+	return []cassette.Interaction{
+		interaction(http.MethodGet, "/contents/root", map[string]any{
+			"id": "root", "type": "folder", "name": "root",
+			"children": map[string]any{
+				"bkt": map[string]any{"id": "bkt", "type": "folder", "name": "photos", "parentFolder": "root"},
+			},
+		}),
+		interaction(http.MethodGet, "/contents/bkt", map[string]any{
+			"id": "bkt", "type": "folder", "name": "photos", "parentFolder": "root",
+			"children": map[string]any{
+				"f1": map[string]any{"id": "f1", "type": "file", "name": "a.txt", "size": 5, "md5": "5d41402abc4b2a76b9719d911017c592", "parentFolder": "bkt"},
+				"f2": map[string]any{"id": "f2", "type": "file", "name": "b.txt", "size": 5, "md5": "7d793037a0760186574b0282f2f435e7", "parentFolder": "bkt"},
+			},
+		}),
+	}
+}
+
+// serve sends r to g and returns the recorded response
+func serve(g *Gateway, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	return w
+}
+
+// list sends a ListObjectsV2 request with query and decodes the result
+func list(t *testing.T, g *Gateway, query string) listBucketResult {
+	t.Helper()
+	w := serve(g, httptest.NewRequest(http.MethodGet, "/photos?list-type=2&"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list %s: status %d: %s", query, w.Code, w.Body)
+	}
+	var result listBucketResult
+	if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestListObjectsMaxKeys(t *testing.T) {
+	g, _ := replay(t, nil, account()...)
+
+	result := list(t, g, "max-keys=0")
+	if result.KeyCount != 0 || len(result.Contents) != 0 || !result.IsTruncated || result.NextContinuationToken != "" {
+		t.Errorf("max-keys=0: got %d keys, truncated %v, token %q, want no keys, truncated and no token",
+			result.KeyCount, result.IsTruncated, result.NextContinuationToken)
+	}
+
+	result = list(t, g, "max-keys=1")
+	if len(result.Contents) != 1 || result.Contents[0].Key != "a.txt" || !result.IsTruncated {
+		t.Fatalf("max-keys=1: got %+v, want a.txt and truncated", result.Contents)
+	}
+	result = list(t, g, "max-keys=1&continuation-token="+result.NextContinuationToken)
+	if len(result.Contents) != 1 || result.Contents[0].Key != "b.txt" || result.IsTruncated {
+		t.Errorf("second page: got %+v, want b.txt and not truncated", result.Contents)
+	}
+}
+
+// put returns a PutObject request storing body at key with the Content-MD5 header set to digest if not empty
+func put(key string, body string, digest string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/photos/"+key, strings.NewReader(body))
+	if digest != "" {
+		r.Header.Set("Content-MD5", digest)
+	}
+	return r
+}
+
+// digest returns the Content-MD5 header value of body
+func digest(body string) string {
+	sum := md5.Sum([]byte(body))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestPutObjectReplaces(t *testing.T) {
+	sum := md5.Sum([]byte("hello world"))
+	g, log := replay(t, nil, append(account(),
+		interaction(http.MethodPost, "/contents/uploadfile", map[string]any{
+			"id": "f3", "type": "file", "name": "a.txt", "size": 11, "md5": hex.EncodeToString(sum[:]), "parentFolder": "bkt",
+		}),
+		interaction(http.MethodDelete, "/contents", map[string]any{"f1": map[string]any{"status": "ok"}}),
+	)...)
+
+	w := serve(g, put("a.txt", "hello world", digest("hello world")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if got, want := w.Header().Get("ETag"), etag(hex.EncodeToString(sum[:])); got != want {
+		t.Errorf("ETag = %s, want %s", got, want)
+	}
+	if !log.has(http.MethodDelete, "/contents") {
+		t.Error("the replaced file was not deleted")
+	}
+}
+
+// failed returns a recorded gofile response with the error status
+func failed(method string, path string, status string) cassette.Interaction {
+	i := interaction(method, path, nil)
+	i.Response.Body, _ = json.Marshal(map[string]any{"status": status, "data": map[string]any{}})
+	return i
+}
+
+// replacing returns the interactions of a PutObject replacing a.txt with "hello world", the
+// deletion of the replaced file f1 answers with deleted
+func replacing(deleted ...cassette.Interaction) []cassette.Interaction {
+	sum := md5.Sum([]byte("hello world"))
+	return append(append(account(),
+		interaction(http.MethodPost, "/contents/uploadfile", map[string]any{
+			"id": "f3", "type": "file", "name": "a.txt", "size": 11, "md5": hex.EncodeToString(sum[:]), "parentFolder": "bkt",
+		})), deleted...)
+}
+
+func TestPutObjectKeepsPreviousFile(t *testing.T) {
+	g, log := replay(t, nil, replacing(
+		failed(http.MethodDelete, "/contents", "error-rateLimit"),
+		interaction(http.MethodDelete, "/contents", map[string]any{"f3": map[string]any{"status": "ok"}}),
+	)...)
+
+	w := serve(g, put("a.txt", "hello world", ""))
+	if w.Code < 400 {
+		t.Fatalf("status %d, want an error", w.Code)
+	}
+	if n := log.count(http.MethodDelete, "/contents"); n != 2 {
+		t.Errorf("%d deletions sent, want the replaced file and then the new one", n)
+	}
+	if !strings.Contains(w.Body.String(), "previous file was kept") {
+		t.Errorf("error %s does not say the previous file was kept", w.Body)
+	}
+}
+
+func TestPutObjectReportsDuplicateKey(t *testing.T) {
+	g, _ := replay(t, nil, replacing(
+		failed(http.MethodDelete, "/contents", "error-rateLimit"),
+		failed(http.MethodDelete, "/contents", "error-rateLimit"),
+	)...)
+
+	w := serve(g, put("a.txt", "hello world", ""))
+	if w.Code < 400 {
+		t.Fatalf("status %d, want an error", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "f1") || !strings.Contains(body, "f3") {
+		t.Errorf("error %s does not name both files stored under the key", body)
+	}
+}
+
+func TestCreateBucket(t *testing.T) {
+	g, log := replay(t, nil, append(account(),
+		interaction(http.MethodPost, "/contents/createFolder", map[string]any{"id": "new", "type": "folder", "name": "docs"}),
+	)...)
+
+	if w := serve(g, httptest.NewRequest(http.MethodPut, "/photos", nil)); w.Code != http.StatusConflict {
+		t.Errorf("existing bucket: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	w := serve(g, httptest.NewRequest(http.MethodPut, "/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if !log.has(http.MethodPost, "/contents/createFolder") {
+		t.Error("the bucket folder was not created")
+	}
+}
+
+func TestCreateBucketLookupFails(t *testing.T) {
+	g, log := replay(t, nil, failed(http.MethodGet, "/contents/root", "error-rateLimit"))
+
+	w := serve(g, httptest.NewRequest(http.MethodPut, "/docs", nil))
+	if w.Code < 400 {
+		t.Errorf("status %d, want an error", w.Code)
+	}
+	if log.has(http.MethodPost, "/contents/createFolder") {
+		t.Error("a folder was created although the bucket lookup failed")
+	}
+}
+
+func TestPutObjectBadDigest(t *testing.T) {
+	// the upload would succeed if the body was not checked, the failed upload drops the cached
+	// listings so the second request lists the account again
+	interactions := append(account(),
+		interaction(http.MethodPost, "/contents/uploadfile", map[string]any{
+			"id": "f3", "type": "file", "name": "a.txt", "size": 11, "parentFolder": "bkt",
+		}),
+		interaction(http.MethodDelete, "/contents", map[string]any{"f1": map[string]any{"status": "ok"}}),
+	)
+	g, log := replay(t, nil, append(interactions, account()...)...)
+
+	w := serve(g, put("a.txt", "hello world", digest("something else")))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<Code>BadDigest</Code>") {
+		t.Errorf("status %d: %s, want BadDigest", w.Code, w.Body)
+	}
+	if log.has(http.MethodDelete, "/contents") {
+		t.Error("the existing file was deleted although the upload was rejected")
+	}
+
+	w = serve(g, put("a.txt", "hello world", "not base64"))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<Code>InvalidDigest</Code>") {
+		t.Errorf("status %d: %s, want InvalidDigest", w.Code, w.Body)
+	}
+}
+
+func TestPutObjectChunked(t *testing.T) {
+	sum := md5.Sum([]byte("hello world"))
+	g, _ := replay(t, nil, append(account(),
+		interaction(http.MethodPost, "/contents/uploadfile", map[string]any{
+			"id": "f3", "type": "file", "name": "c.txt", "size": 11, "md5": hex.EncodeToString(sum[:]), "parentFolder": "bkt",
+		}),
+	)...)
+
+	r := put("c.txt", "6;chunk-signature=aa\r\nhello \r\n5;chunk-signature=bb\r\nworld\r\n0;chunk-signature=cc\r\n\r\n", digest("hello world"))
+	r.Header.Set("X-Amz-Content-Sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD")
+	r.Header.Set("X-Amz-Decoded-Content-Length", "11")
+	w := serve(g, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if got, want := w.Header().Get("ETag"), etag(hex.EncodeToString(sum[:])); got != want {
+		t.Errorf("ETag = %s, want %s of the decoded body", got, want)
+	}
+}
+
+// createUpload starts a multipart upload of key and returns its ID
+func createUpload(t *testing.T, g *Gateway, key string) string {
+	t.Helper()
+	w := serve(g, httptest.NewRequest(http.MethodPost, "/photos/"+key+"?uploads", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create multipart upload: status %d: %s", w.Code, w.Body)
+	}
+	var result initiateMultipartUploadResult
+	if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result.UploadID
+}
+
+// uploadPart returns an UploadPart request sending body as part 1 of the upload id
+func uploadPart(id string, body string, digest string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/photos/big.bin?partNumber=1&uploadId="+url.QueryEscape(id), strings.NewReader(body))
+	if digest != "" {
+		r.Header.Set("Content-MD5", digest)
+	}
+	return r
+}
+
+func TestUploadPartBadDigest(t *testing.T) {
+	g, _ := replay(t, nil, account()...)
+	id := createUpload(t, g, "big.bin")
+
+	w := serve(g, uploadPart(id, "part one", digest("something else")))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<Code>BadDigest</Code>") {
+		t.Errorf("status %d: %s, want BadDigest", w.Code, w.Body)
+	}
+	w = serve(g, uploadPart(id, "part one", digest("part one")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	// only the accepted attempt is kept
+	files, err := os.ReadDir(g.uploads[id].dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d part files buffered, want 1", len(files))
+	}
+}
+
+func TestMultipartUploadExpires(t *testing.T) {
+	expiry := 10 * time.Millisecond
+	g, _ := replay(t, &Options{UploadExpiry: &expiry}, account()...)
+	id := createUpload(t, g, "big.bin")
+	dir := g.uploads[id].dir
+
+	time.Sleep(2 * expiry)
+	w := serve(g, uploadPart(id, "part one", ""))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "<Code>NoSuchUpload</Code>") {
+		t.Errorf("status %d: %s, want NoSuchUpload", w.Code, w.Body)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("parts of the expired upload were kept: %v", err)
+	}
+}
+
+func TestStaleTempDirsRemoved(t *testing.T) {
+	tempDir := t.TempDir()
+	stale, err := os.MkdirTemp(tempDir, tempDirPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := os.MkdirTemp(tempDir, tempDirPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replay(t, &Options{TempDir: tempDir})
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale upload directory was kept: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("recent upload directory was removed: %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package s3gateway
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPartNumber is the highest part number S3 accepts
+const maxPartNumber = 10000
+
+// defaultUploadExpiry is how long a multipart upload is kept without activity when Options.UploadExpiry is nil
+const defaultUploadExpiry = 24 * time.Hour
+
+// tempDirPattern names the temporary directories of multipart uploads
+const tempDirPattern = "gofile-s3-"
+
+// multipartUpload is an in-progress multipart upload whose parts are buffered in dir
+type multipartUpload struct {
+	bucket string
+	key    string
+	dir    string // temporary directory holding one file per part
+
+	// guarded by Gateway.uploadsMu
+	active   int       // requests using the upload
+	lastUsed time.Time // end of the last request using the upload
+
+	mu    sync.Mutex
+	parts map[int]uploadedPart // part number to the buffered part
+}
+
+// uploadedPart is a part buffered to a temporary file
+type uploadedPart struct {
+	path string
+	size int64
+	md5  []byte
+}
+
+// upload returns the multipart upload with the specified id, the caller must release it once done
+func (g *Gateway) upload(id string) (*multipartUpload, error) {
+	g.expireUploads()
+	g.uploadsMu.Lock()
+	defer g.uploadsMu.Unlock()
+	u, ok := g.uploads[id]
+	if !ok {
+		return nil, errNoSuchUpload
+	}
+	u.active++
+	return u, nil
+}
+
+// release marks the end of a request using u
+func (g *Gateway) release(u *multipartUpload) {
+	g.uploadsMu.Lock()
+	defer g.uploadsMu.Unlock()
+	u.active--
+	u.lastUsed = time.Now()
+}
+
+// expireUploads removes the multipart uploads unused for longer than the upload expiry
+func (g *Gateway) expireUploads() {
+	var dirs []string
+	g.uploadsMu.Lock()
+	for id, u := range g.uploads {
+		if u.active == 0 && time.Since(u.lastUsed) > g.uploadExpiry {
+			delete(g.uploads, id)
+			dirs = append(dirs, u.dir)
+		}
+	}
+	g.uploadsMu.Unlock()
+	for _, dir := range dirs {
+		os.RemoveAll(dir)
+	}
+}
+
+// removeStaleTempDirs deletes the temporary directories left behind by multipart uploads
+// of previous runs which were not touched for longer than expiry
+func removeStaleTempDirs(tempDir string, expiry time.Duration) {
+	dirs, _ := filepath.Glob(filepath.Join(tempDir, tempDirPattern+"*"))
+	for _, dir := range dirs {
+		fi, err := os.Stat(dir)
+		if err == nil && fi.IsDir() && time.Since(fi.ModTime()) > expiry {
+			os.RemoveAll(dir)
+		}
+	}
+}
+
+// removeUpload forgets the multipart upload with the specified id and deletes its buffered parts
+func (g *Gateway) removeUpload(id string) error {
+	g.uploadsMu.Lock()
+	u, ok := g.uploads[id]
+	delete(g.uploads, id)
+	g.uploadsMu.Unlock()
+	if !ok {
+		return errNoSuchUpload
+	}
+	return os.RemoveAll(u.dir)
+}
+
+func (g *Gateway) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	if _, err := g.bucket(bucket); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := objectName(bucket, key); err != nil || strings.HasSuffix(key, "/") {
+		writeError(w, r, &s3Error{Code: "InvalidArgument", Message: "invalid object key", Status: http.StatusBadRequest})
+		return
+	}
+	g.expireUploads()
+	dir, err := os.MkdirTemp(g.tempDir, tempDirPattern)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id := rand.Text()
+
+	g.uploadsMu.Lock()
+	g.uploads[id] = &multipartUpload{
+		bucket:   bucket,
+		key:      key,
+		dir:      dir,
+		lastUsed: time.Now(),
+		parts:    make(map[int]uploadedPart),
+	}
+	g.uploadsMu.Unlock()
+
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: id,
+	})
+}
+
+func (g *Gateway) uploadPart(w http.ResponseWriter, r *http.Request, id string, partNumber string) {
+	u, err := g.upload(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer g.release(u)
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 || n > maxPartNumber {
+		writeError(w, r, &s3Error{Code: "InvalidArgument", Message: "invalid part number", Status: http.StatusBadRequest})
+		return
+	}
+
+	want, err := contentMD5(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// each attempt gets its own file so a failed retry does not clobber the part already stored
+	body, _ := requestBody(r)
+	part, err := writePart(filepath.Join(u.dir, strconv.Itoa(n)+"-"+rand.Text()), body)
+	if err != nil {
+		os.Remove(part.path)
+		writeError(w, r, err)
+		return
+	}
+	if want != nil && !bytes.Equal(part.md5, want) {
+		os.Remove(part.path)
+		writeError(w, r, errBadDigest)
+		return
+	}
+	u.mu.Lock()
+	previous, replaced := u.parts[n]
+	u.parts[n] = part
+	u.mu.Unlock()
+	if replaced {
+		os.Remove(previous.path)
+	}
+
+	w.Header().Set("ETag", etag(hex.EncodeToString(part.md5)))
+	w.WriteHeader(http.StatusOK)
+}
+
+// writePart buffers r to the file at path and returns the part it holds
+func writePart(path string, r io.Reader) (uploadedPart, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return uploadedPart{}, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return uploadedPart{path: path}, err
+	}
+	if err := f.Close(); err != nil {
+		return uploadedPart{path: path}, err
+	}
+	return uploadedPart{path: path, size: size, md5: h.Sum(nil)}, nil
+}
+
+func (g *Gateway) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string, id string) {
+	u, err := g.upload(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer g.release(u)
+	if u.bucket != bucket || u.key != key {
+		writeError(w, r, errNoSuchUpload)
+		return
+	}
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		writeError(w, r, &s3Error{Code: "MalformedXML", Message: "invalid CompleteMultipartUpload document", Status: http.StatusBadRequest})
+		return
+	}
+
+	u.mu.Lock()
+	parts := make([]uploadedPart, 0, len(req.Parts))
+	for i, p := range req.Parts {
+		part, ok := u.parts[p.PartNumber]
+		if !ok || strings.Trim(p.ETag, `"`) != hex.EncodeToString(part.md5) {
+			u.mu.Unlock()
+			writeError(w, r, errInvalidPart)
+			return
+		}
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			u.mu.Unlock()
+			writeError(w, r, &s3Error{Code: "InvalidPartOrder", Message: "parts must be listed in ascending order", Status: http.StatusBadRequest})
+			return
+		}
+		parts = append(parts, part)
+	}
+	u.mu.Unlock()
+
+	// the ETag of a multipart object is the MD5 of its parts' MD5s followed by the part count
+	var size int64
+	h := md5.New()
+	for _, p := range parts {
+		size += p.size
+		h.Write(p.md5)
+	}
+	tag := fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(parts))
+
+	pr := &partsReader{parts: parts}
+	defer pr.Close()
+	if _, err := g.store(bucket, key, pr, size); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := g.removeUpload(id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:  s3Namespace,
+		Bucket: bucket,
+		Key:    key,
+		ETag:   etag(tag),
+	})
+}
+
+func (g *Gateway) abortMultipartUpload(w http.ResponseWriter, r *http.Request, id string) {
+	if err := g.removeUpload(id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// partsReader reads buffered parts one after another, opening one file at a time
+type partsReader struct {
+	parts   []uploadedPart
+	current *os.File
+}
+
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.current == nil {
+			if len(p.parts) == 0 {
+				return 0, io.EOF
+			}
+			f, err := os.Open(p.parts[0].path)
+			if err != nil {
+				return 0, err
+			}
+			p.current = f
+			p.parts = p.parts[1:]
+		}
+		n, err := p.current.Read(b)
+		if err == io.EOF {
+			p.current.Close()
+			p.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (p *partsReader) Close() error {
+	if p.current != nil {
+		return p.current.Close()
+	}
+	return nil
+}
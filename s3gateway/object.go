@@ -0,0 +1,383 @@
+package s3gateway
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// emptyMD5 is the MD5 of an empty object, used as the ETag of folder markers
+const emptyMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+// defaultMaxKeys is the number of keys returned by ListObjectsV2 when max-keys is not set
+const defaultMaxKeys = 1000
+
+// objectName returns the path of key inside bucket, relative to the root folder
+func objectName(bucket string, key string) (string, error) {
+	name := bucket + "/" + strings.TrimSuffix(key, "/")
+	if !fs.ValidPath(name) {
+		return "", &s3Error{Code: "InvalidArgument", Message: "invalid object key", Status: http.StatusBadRequest}
+	}
+	return name, nil
+}
+
+// etag quotes an MD5 hex digest as an S3 ETag
+func etag(md5 string) string {
+	return `"` + md5 + `"`
+}
+
+// store streams r into a file at key, creating missing folders and replacing an existing file
+func (g *Gateway) store(bucket string, key string, r io.Reader, size int64) (model.UploadFileData, error) {
+	name, err := objectName(bucket, key)
+	if err != nil {
+		return model.UploadFileData{}, err
+	}
+	existing, err := g.content(name)
+	switch {
+	case err == nil && existing.Type == model.FolderType:
+		return model.UploadFileData{}, &s3Error{Code: "InvalidArgument", Message: "key is a folder", Status: http.StatusBadRequest}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return model.UploadFileData{}, err
+	}
+	dir, base := path.Split(key)
+	folder, err := g.mkdirAll(bucket, dir)
+	if err != nil {
+		return model.UploadFileData{}, err
+	}
+	server, err := g.uploadServer()
+	if err != nil {
+		return model.UploadFileData{}, err
+	}
+	defer g.fsys.Invalidate()
+	resp, err := g.api.UploadReader(server, base, r, size, folder.ID, nil)
+	if err != nil {
+		return model.UploadFileData{}, err
+	}
+	if existing.ID != "" {
+		if err := g.replace(name, existing.ID, resp.Data.ID); err != nil {
+			return model.UploadFileData{}, err
+		}
+	}
+	return resp.Data, nil
+}
+
+// replace deletes the file oldID that the upload newID replaces at name.
+//
+// gofile allows several files with the same name, so when the old file cannot be deleted the
+// new one is deleted instead and the key keeps its previous content. If both deletions fail,
+// the returned error names the two files now sharing the key.
+func (g *Gateway) replace(name string, oldID string, newID string) error {
+	_, err := g.api.DeleteContent(oldID)
+	if err == nil {
+		return nil
+	}
+	if _, undoErr := g.api.DeleteContent(newID); undoErr != nil {
+		return fmt.Errorf("replacing %s failed, both %s and %s are stored under it: %w", name, oldID, newID, errors.Join(err, undoErr))
+	}
+	return fmt.Errorf("replacing %s failed, the previous file was kept: %w", name, err)
+}
+
+func (g *Gateway) putObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeError(w, r, errNotImplemented)
+		return
+	}
+	if _, err := g.bucket(bucket); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// keys ending with a slash are folder markers
+	if strings.HasSuffix(key, "/") {
+		if _, err := objectName(bucket, key); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if _, err := g.mkdirAll(bucket, key); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("ETag", etag(emptyMD5))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	want, err := contentMD5(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	body, size := requestBody(r)
+	d := &digestReader{r: body, h: md5.New(), want: want}
+	if _, err := g.store(bucket, key, d, size); err != nil {
+		if d.bad.Load() {
+			err = errBadDigest
+		}
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(hex.EncodeToString(d.h.Sum(nil))))
+	w.WriteHeader(http.StatusOK)
+}
+
+// contentMD5 returns the digest sent in the Content-MD5 header, nil if there is none
+func contentMD5(r *http.Request) ([]byte, error) {
+	v := r.Header.Get("Content-MD5")
+	if v == "" {
+		return nil, nil
+	}
+	sum, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(sum) != md5.Size {
+		return nil, errInvalidDigest
+	}
+	return sum, nil
+}
+
+// digestReader hashes what is read through it and fails with errBadDigest instead of io.EOF
+// when want is set and differs, so a corrupted body aborts the upload before it is stored
+type digestReader struct {
+	r    io.Reader
+	h    hash.Hash
+	want []byte
+	bad  atomic.Bool
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.h.Write(p[:n])
+	if err == io.EOF && d.want != nil && !bytes.Equal(d.h.Sum(nil), d.want) {
+		d.bad.Store(true)
+		return n, errBadDigest
+	}
+	return n, err
+}
+
+func (g *Gateway) getObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	if _, err := g.bucket(bucket); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	name, err := objectName(bucket, key)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	f, err := g.fsys.Open(name)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if fi.IsDir() || !ok {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+	c := fi.Sys().(model.Content)
+	if c.MD5 != "" {
+		w.Header().Set("ETag", etag(c.MD5))
+	}
+	if c.MimeType != "" {
+		w.Header().Set("Content-Type", c.MimeType)
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), rs)
+}
+
+func (g *Gateway) deleteObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	if _, err := g.bucket(bucket); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	name, err := objectName(bucket, key)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	c, err := g.content(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// S3 reports success when deleting a missing key
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if c.Type == model.FolderType {
+		// only empty folders are removed, through their folder marker key
+		entries, err := g.fsys.ReadDir(name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !strings.HasSuffix(key, "/") || len(entries) > 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if _, err := g.api.DeleteContent(c.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	g.fsys.Invalidate()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listEntry is a key or common prefix returned by listObjects
+type listEntry struct {
+	key    string
+	prefix bool        // prefix reports whether key is a common prefix
+	info   fs.FileInfo // info describes the object, nil for common prefixes
+}
+
+func (g *Gateway) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	if _, err := g.bucket(bucket); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	q := r.URL.Query()
+	result := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            q.Get("prefix"),
+		Delimiter:         q.Get("delimiter"),
+		StartAfter:        q.Get("start-after"),
+		ContinuationToken: q.Get("continuation-token"),
+		MaxKeys:           defaultMaxKeys,
+	}
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, &s3Error{Code: "InvalidArgument", Message: "invalid max-keys", Status: http.StatusBadRequest})
+			return
+		}
+		result.MaxKeys = min(n, defaultMaxKeys)
+	}
+	after := max(result.StartAfter, q.Get("marker"))
+	if result.ContinuationToken != "" {
+		token, err := base64.RawURLEncoding.DecodeString(result.ContinuationToken)
+		if err != nil {
+			writeError(w, r, &s3Error{Code: "InvalidArgument", Message: "invalid continuation token", Status: http.StatusBadRequest})
+			return
+		}
+		after = max(after, string(token))
+	}
+
+	entries, err := g.walk(bucket, result.Prefix, result.Delimiter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	start, _ := slices.BinarySearchFunc(entries, after, func(e listEntry, key string) int {
+		return strings.Compare(e.key, key)
+	})
+	if start < len(entries) && entries[start].key == after {
+		start++
+	}
+	entries = entries[start:]
+	if result.MaxKeys == 0 {
+		// nothing is listed, so there is no last key to continue from
+		result.IsTruncated = len(entries) > 0
+		entries = nil
+	} else if len(entries) > result.MaxKeys {
+		entries = entries[:result.MaxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(entries[len(entries)-1].key))
+	}
+
+	for _, e := range entries {
+		if e.prefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: e.key})
+			continue
+		}
+		o := objectXML{
+			Key:          e.key,
+			LastModified: s3Time(e.info.ModTime()),
+			Size:         e.info.Size(),
+			StorageClass: "STANDARD",
+		}
+		if c, ok := e.info.Sys().(model.Content); ok && c.MD5 != "" {
+			o.ETag = etag(c.MD5)
+		}
+		result.Contents = append(result.Contents, o)
+	}
+	result.KeyCount = len(entries)
+	writeXML(w, http.StatusOK, result)
+}
+
+// walk returns the objects of bucket matching prefix sorted by key, keys containing delimiter
+// after the prefix are rolled up into common prefixes
+func (g *Gateway) walk(bucket string, prefix string, delimiter string) ([]listEntry, error) {
+	sub, err := fs.Sub(g.fsys, bucket)
+	if err != nil {
+		return nil, err
+	}
+	var entries []listEntry
+	seen := make(map[string]bool)
+	err = fs.WalkDir(sub, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		key := p
+		if d.IsDir() {
+			key += "/"
+		}
+		if !strings.HasPrefix(key, prefix) {
+			// keep descending only into folders that can still contain the prefix
+			if d.IsDir() && !strings.HasPrefix(prefix, key) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				cp := key[:len(prefix)+i+len(delimiter)]
+				if !seen[cp] {
+					seen[cp] = true
+					entries = append(entries, listEntry{key: cp, prefix: true})
+				}
+				if d.IsDir() && cp == key {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, listEntry{key: key, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b listEntry) int {
+		return strings.Compare(a.key, b.key)
+	})
+	return entries, nil
+}
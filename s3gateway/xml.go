@@ -0,0 +1,154 @@
+package s3gateway
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+)
+
+// s3Namespace is the XML namespace of S3 responses
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type owner struct {
+	ID string `xml:"ID"`
+}
+
+type bucketXML struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name    `xml:"ListAllMyBucketsResult"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Owner   owner       `xml:"Owner"`
+	Buckets []bucketXML `xml:"Buckets>Bucket"`
+}
+
+type objectXML struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectXML    `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	Parts []completedPart `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+	ETag    string   `xml:"ETag"`
+}
+
+// s3Error is an error reported to the client as an S3 error document
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource,omitempty"`
+	Status   int      `xml:"-"`
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	errNoSuchBucket   = &s3Error{Code: "NoSuchBucket", Message: "the specified bucket does not exist", Status: http.StatusNotFound}
+	errNoSuchKey      = &s3Error{Code: "NoSuchKey", Message: "the specified key does not exist", Status: http.StatusNotFound}
+	errNoSuchUpload   = &s3Error{Code: "NoSuchUpload", Message: "the specified multipart upload does not exist", Status: http.StatusNotFound}
+	errInvalidPart    = &s3Error{Code: "InvalidPart", Message: "one or more of the specified parts could not be found", Status: http.StatusBadRequest}
+	errBadDigest      = &s3Error{Code: "BadDigest", Message: "the Content-MD5 you specified did not match what was received", Status: http.StatusBadRequest}
+	errInvalidDigest  = &s3Error{Code: "InvalidDigest", Message: "the Content-MD5 you specified is not valid", Status: http.StatusBadRequest}
+	errNotImplemented = &s3Error{Code: "NotImplemented", Message: "the gateway does not implement this operation", Status: http.StatusNotImplemented}
+)
+
+// toS3Error maps an error onto the S3 error reported to the client
+func toS3Error(err error) *s3Error {
+	var s3err *s3Error
+	switch {
+	case errors.As(err, &s3err):
+		return s3err
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, api.ErrNotFound):
+		return errNoSuchKey
+	case errors.Is(err, api.ErrUnauthorized), errors.Is(err, api.ErrNotPremium):
+		return &s3Error{Code: "AccessDenied", Message: err.Error(), Status: http.StatusForbidden}
+	case errors.Is(err, api.ErrRateLimited):
+		return &s3Error{Code: "SlowDown", Message: err.Error(), Status: http.StatusServiceUnavailable}
+	case errors.Is(err, api.ErrQuotaExceeded):
+		return &s3Error{Code: "QuotaExceeded", Message: err.Error(), Status: http.StatusForbidden}
+	default:
+		return &s3Error{Code: "InternalError", Message: err.Error(), Status: http.StatusInternalServerError}
+	}
+}
+
+// writeError writes err as an S3 error document, HEAD responses only get the status code
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := *toS3Error(err)
+	e.Resource = r.URL.Path
+	if r.Method == http.MethodHead {
+		w.WriteHeader(e.Status)
+		return
+	}
+	writeXML(w, e.Status, e)
+}
+
+// writeXML writes v as an XML document with the specified status code
+func writeXML(w http.ResponseWriter, status int, v any) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// s3Time formats t the way S3 XML documents do
+func s3Time(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
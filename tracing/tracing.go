@@ -0,0 +1,65 @@
+// package tracing defines the spans recorded around API calls and transfers
+//
+// Pass a Tracer in api.Options.Tracer to bridge them to a tracing backend. Two kinds of spans are started:
+//
+//   - "gofile.api" around every API call, with the endpoint, gofile status, HTTP status and response size
+//   - "gofile.http" around every HTTP request sent by the client, including retries, uploads and downloads,
+//     with events from net/http/httptrace (DNS, connect, TLS, connection reuse, request written,
+//     first response byte) and, for uploads, when the multipart writer starts and finishes reading the file
+//
+// The default tracer is Nop, which records nothing and skips the httptrace hooks.
+package tracing
+
+// Attribute is a key value pair describing a span or an event
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Tracer starts spans.
+//
+// Implementations must be safe for concurrent use.
+type Tracer interface {
+	Start(name string, attrs ...Attribute) Span
+}
+
+// Span is a timed operation.
+//
+// Events may be added from several goroutines at once, End is called exactly once.
+type Span interface {
+	// SetAttributes adds attributes to the span
+	SetAttributes(attrs ...Attribute)
+	// Event records a point in time during the span
+	Event(name string, attrs ...Attribute)
+	// End finishes the span, err is nil if the operation succeeded
+	End(err error)
+}
+
+// Nop is a Tracer whose spans record nothing, used when no Tracer is set
+type Nop struct{}
+
+// Start returns a span which records nothing
+func (Nop) Start(string, ...Attribute) Span {
+	return nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) Event(string, ...Attribute) {}
+func (nopSpan) End(error)                  {}
@@ -0,0 +1,96 @@
+package uploader
+
+import (
+	"errors"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// ErrChecksumMismatch is returned when the MD5 reported by gofile differs from the local file, see Options.Verify
+var ErrChecksumMismatch = errors.New("uploaded file checksum mismatch")
+
+// EventType is the kind of an Event
+type EventType string
+
+const (
+	EventStarted   EventType = "started"   // upload of a job started
+	EventProgress  EventType = "progress"  // bytes of the current job were sent, see Options.ProgressInterval
+	EventCompleted EventType = "completed" // job is done, either uploaded or reused from the index
+	EventFailed    EventType = "failed"    // job failed and will be retried on the next run
+	EventVerified  EventType = "verified"  // MD5 reported by gofile matches the local file, see Options.Verify
+)
+
+// Event describes a change in the upload of a job
+type Event struct {
+	Type      EventType             `json:"type"`                // kind of the event
+	Time      time.Time             `json:"time"`                // time the event was emitted
+	JobID     string                `json:"jobId"`               // ID of the job
+	Path      string                `json:"path"`                // absolute path of the local file
+	FolderID  string                `json:"folderId"`            // ID of the folder the file is uploaded into
+	Sent      int64                 `json:"sent,omitempty"`      // bytes sent so far, set on progress events
+	Size      int64                 `json:"size,omitempty"`      // size of the local file
+	MD5       string                `json:"md5,omitempty"`       // MD5 of the file, set on verified events
+	Result    *model.UploadFileData `json:"result,omitempty"`    // uploaded file, set on completed events unless a duplicate was reused without a copy
+	Duplicate *IndexEntry           `json:"duplicate,omitempty"` // existing file used instead of uploading, set on completed events
+	Error     string                `json:"error,omitempty"`     // error message, set on failed events or completed events whose policy could not be applied or whose copy was not found
+}
+
+// Sink receives the events emitted by an Uploader.
+//
+// Send is called on the upload path, it must not block.
+type Sink interface {
+	Send(e Event) error
+}
+
+// errorReporter is implemented by sinks delivering in the background, they report failed
+// deliveries to Options.OnSinkError themselves
+type errorReporter interface {
+	reportErrors(fn func(e Event, err error))
+}
+
+// defaultProgressInterval is the minimum delay between progress events when Options.ProgressInterval is nil
+const defaultProgressInterval = time.Second
+
+// emit sends e to every sink, errors are reported to Options.OnSinkError
+func (u *Uploader) emit(e Event) {
+	e.Time = time.Now()
+	for _, s := range u.opts.Sinks {
+		if err := s.Send(e); err != nil && u.opts.OnSinkError != nil {
+			u.opts.OnSinkError(e, err)
+		}
+	}
+}
+
+// jobEvent returns an event of type t about j
+func jobEvent(t EventType, j Job) Event {
+	return Event{
+		Type:     t,
+		JobID:    j.ID,
+		Path:     j.Path,
+		FolderID: j.FolderID,
+	}
+}
+
+// progress returns the progress callback of a job, forwarding to Options.OnProgress and
+// emitting progress events at most once per Options.ProgressInterval
+func (u *Uploader) progress(j Job) func(int64, int64) {
+	interval := defaultProgressInterval
+	if u.opts.ProgressInterval != nil {
+		interval = *u.opts.ProgressInterval
+	}
+	var last time.Time
+	return func(sent int64, size int64) {
+		if u.opts.OnProgress != nil {
+			u.opts.OnProgress(sent, size)
+		}
+		if len(u.opts.Sinks) == 0 || (time.Since(last) < interval && sent < size) {
+			return
+		}
+		last = time.Now()
+		e := jobEvent(EventProgress, j)
+		e.Sent = sent
+		e.Size = size
+		u.emit(e)
+	}
+}
@@ -0,0 +1,206 @@
+package uploader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/model"
+)
+
+// IndexEntry describes a file already stored in the account
+type IndexEntry struct {
+	ContentID    string `json:"contentId"`           // ID of the file on gofile
+	Name         string `json:"name"`                // name of the file
+	ParentFolder string `json:"parentFolder"`        // ID of the folder holding the file
+	Size         int64  `json:"size"`                // size of the file in bytes
+	Encrypted    bool   `json:"encrypted,omitempty"` // the stored file is encrypted, see Options.Encryption
+}
+
+// Index maps MD5 hashes to files already stored in the account.
+//
+// If the index is backed by a file it is loaded from and saved to that file.
+type Index struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string][]IndexEntry
+}
+
+// NewIndex creates an in-memory index which is not persisted
+func NewIndex() *Index {
+	return &Index{
+		entries: make(map[string][]IndexEntry),
+	}
+}
+
+// OpenIndex loads the index stored at path, a missing file yields an empty index.
+//
+// Returns the index or an error.
+func OpenIndex(path string) (*Index, error) {
+	idx := NewIndex()
+	idx.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read index failed: %w", err)
+	}
+	if err := json.Unmarshal(data, &idx.entries); err != nil {
+		return nil, fmt.Errorf("decode index failed: %w", err)
+	}
+	return idx, nil
+}
+
+// Add records that the file described by e has the hash md5
+func (i *Index) Add(md5 string, e IndexEntry) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, existing := range i.entries[md5] {
+		if existing.ContentID == e.ContentID {
+			return
+		}
+	}
+	i.entries[md5] = append(i.entries[md5], e)
+}
+
+// Remove forgets the file with the specified contentID
+func (i *Index) Remove(contentID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for hash, entries := range i.entries {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.ContentID != contentID {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			delete(i.entries, hash)
+			continue
+		}
+		i.entries[hash] = kept
+	}
+}
+
+// Lookup returns the plain file with hash md5, preferring one stored in folderID.
+//
+// Encrypted entries are never returned, see LookupEncrypted.
+// Returns the entry and whether one was found.
+func (i *Index) Lookup(md5 string, folderID string) (IndexEntry, bool) {
+	return i.lookup(md5, folderID, false)
+}
+
+// LookupEncrypted returns the encrypted file whose local content has hash md5, preferring one stored in folderID.
+//
+// Returns the entry and whether one was found.
+func (i *Index) LookupEncrypted(md5 string, folderID string) (IndexEntry, bool) {
+	return i.lookup(md5, folderID, true)
+}
+
+// lookup returns the file with hash md5 stored encrypted or not, preferring one stored in folderID
+func (i *Index) lookup(md5 string, folderID string, encrypted bool) (IndexEntry, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var found *IndexEntry
+	for _, e := range i.entries[md5] {
+		if e.Encrypted != encrypted {
+			continue
+		}
+		if e.ParentFolder == folderID {
+			return e, true
+		}
+		if found == nil {
+			found = &e
+		}
+	}
+	if found == nil {
+		return IndexEntry{}, false
+	}
+	return *found, true
+}
+
+// AddUpload records the file described by an upload response
+func (i *Index) AddUpload(resp model.UploadFileResponse) {
+	if resp.Data.MD5 == "" {
+		return
+	}
+	i.Add(resp.Data.MD5, IndexEntry{
+		ContentID:    resp.Data.ID,
+		Name:         resp.Data.Name,
+		ParentFolder: resp.Data.ParentFolder,
+		Size:         resp.Data.Size,
+	})
+}
+
+// AddFolder lists the folder with the specified folderID and its subfolders and records every file found.
+//
+// Returns an error if a listing fails.
+func (i *Index) AddFolder(a *api.Api, folderID string) error {
+	resp, err := a.GetContent(folderID)
+	if err != nil {
+		return err
+	}
+	for _, child := range resp.Data.Children {
+		if child.Type == model.FolderType {
+			if err := i.AddFolder(a, child.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if child.MD5 == "" {
+			continue
+		}
+		i.Add(child.MD5, IndexEntry{
+			ContentID:    child.ID,
+			Name:         child.Name,
+			ParentFolder: folderID,
+			Size:         child.Size,
+		})
+	}
+	return nil
+}
+
+// Save writes the index to the file it was opened from, an in-memory index is not saved
+func (i *Index) Save() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(i.entries)
+	if err != nil {
+		return err
+	}
+	tmpPath := i.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("write index failed: %w", err)
+	}
+	return os.Rename(tmpPath, i.path)
+}
+
+// fileMD5 returns the hex encoded MD5 hash of the file at path
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/plutack/go-gofile/crypt"
+	"github.com/plutack/go-gofile/faultinject"
+)
+
+func TestIndexReusesDuplicate(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(helloMD5, IndexEntry{ContentID: "kept", Name: "report.txt", ParentFolder: "fld", Size: 11})
+	a, log := replay(t, nil, interaction(http.MethodGet, "/contents/kept", map[string]any{
+		"id": "kept", "type": "file", "name": "report.txt", "size": 11, "md5": helloMD5, "parentFolder": "fld",
+	}))
+
+	j := run(t, a, testFile(t), Options{Index: idx})
+	if j.State != Done || j.Duplicate == nil || j.Duplicate.ContentID != "kept" {
+		t.Errorf("job is %s with duplicate %+v, want the indexed file reused", j.State, j.Duplicate)
+	}
+	if log.has(http.MethodPost, "/contents/uploadfile") {
+		t.Error("the duplicate was uploaded again")
+	}
+}
+
+func TestIndexDropsDeletedFile(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(helloMD5, IndexEntry{ContentID: "gone", Name: "report.txt", ParentFolder: "fld", Size: 11})
+	// the indexed file was deleted on gofile
+	a, _ := replay(t, []faultinject.Fault{faultinject.GofileStatus("error-notFound")}, uploaded())
+
+	j := run(t, a, testFile(t), Options{Index: idx})
+	if j.State != Done || j.Duplicate != nil || j.Result == nil {
+		t.Fatalf("job is %s with duplicate %+v, want uploaded", j.State, j.Duplicate)
+	}
+	if e, ok := idx.Lookup(helloMD5, "fld"); !ok || e.ContentID != "new" {
+		t.Errorf("index holds %+v, want only the new upload", e)
+	}
+}
+
+func TestIndexIgnoresEncryptedCopies(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(helloMD5, IndexEntry{ContentID: "sealed", Name: "report.txt", ParentFolder: "fld", Size: crypt.EncryptedSize(11), Encrypted: true})
+	a, _ := replay(t, nil, uploaded())
+
+	j := run(t, a, testFile(t), Options{Index: idx})
+	if j.State != Done || j.Duplicate != nil {
+		t.Errorf("job is %s with duplicate %+v, a plain upload must not reuse an encrypted copy", j.State, j.Duplicate)
+	}
+}
+
+func TestIndexRecordsCopiedDuplicate(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(helloMD5, IndexEntry{ContentID: "kept", Name: "report.txt", ParentFolder: "other", Size: 11})
+	a, log := replay(t, nil,
+		interaction(http.MethodGet, "/contents/kept", map[string]any{
+			"id": "kept", "type": "file", "name": "report.txt", "size": 11, "md5": helloMD5, "parentFolder": "other",
+		}),
+		interaction(http.MethodPost, "/contents/copy", map[string]any{}),
+		// the folder listing is the only place gofile reports the ID of the copy
+		interaction(http.MethodGet, "/contents/fld", map[string]any{"id": "fld", "type": "folder", "children": map[string]any{
+			"copy": map[string]any{"id": "copy", "type": "file", "name": "report.txt", "size": 11, "md5": helloMD5, "parentFolder": "fld"},
+		}}),
+	)
+
+	j := run(t, a, testFile(t), Options{Index: idx, CopyDuplicates: true})
+	if j.State != Done || !j.Copied || j.Error != "" {
+		t.Fatalf("job is %s, copied %v, error %q, want the duplicate copied", j.State, j.Copied, j.Error)
+	}
+	if j.Result == nil || j.Result.Data.ID != "copy" || j.Result.Data.ParentFolder != "fld" {
+		t.Errorf("result %+v, want the copy", j.Result)
+	}
+	if e, ok := idx.Lookup(helloMD5, "fld"); !ok || e.ContentID != "copy" || e.ParentFolder != "fld" {
+		t.Errorf("index holds %+v for the folder, want the copy", e)
+	}
+	if log.has(http.MethodPost, "/contents/uploadfile") {
+		t.Error("the duplicate was uploaded again")
+	}
+}
@@ -0,0 +1,271 @@
+package uploader
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/plutack/go-gofile/model"
+)
+
+// JobState is the state of a job in the upload queue
+type JobState string
+
+const (
+	Pending    JobState = "pending"     // job has not been started yet
+	InProgress JobState = "in-progress" // job is currently being uploaded
+	Done       JobState = "done"        // job was uploaded successfully
+	Failed     JobState = "failed"      // job upload failed and will be retried on the next run
+)
+
+// Job represents a single file upload recorded in the queue
+type Job struct {
+	ID        string                    `json:"id"`                  // unique key of the job, derived from path and folder
+	Path      string                    `json:"path"`                // absolute path of the local file
+	FolderID  string                    `json:"folderId"`            // ID of the folder the file is uploaded into
+	State     JobState                  `json:"state"`               // current state of the job
+	Error     string                    `json:"error,omitempty"`     // last error message, set on failed jobs or on done jobs whose policy could not be applied or whose copy was not found
+	Result    *model.UploadFileResponse `json:"result,omitempty"`    // response returned by gofile once the job is done, or the copy of a reused duplicate
+	Duplicate *IndexEntry               `json:"duplicate,omitempty"` // existing file used instead of uploading, see Options.Index
+	Copied    bool                      `json:"copied,omitempty"`    // whether the duplicate was copied into FolderID
+	UpdatedAt time.Time                 `json:"updatedAt"`           // time of the last state change
+
+	// Interrupted is set on jobs found in progress by OpenQueue: their upload may have landed,
+	// so the folder is checked before uploading them again
+	Interrupted bool `json:"-"`
+}
+
+// Queue is an ordered list of upload jobs.
+//
+// If the queue is backed by a state file, every state change is appended to it
+// as a JSON line so the queue can be restored after a crash.
+type Queue struct {
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+	file  *os.File // state file, nil for an in-memory queue
+}
+
+// jobID returns the key used to identify a job
+func jobID(path string, folderID string) string {
+	return folderID + ":" + path
+}
+
+// NewQueue creates an in-memory queue which is not persisted
+func NewQueue() *Queue {
+	return &Queue{
+		jobs: make(map[string]*Job),
+	}
+}
+
+// OpenQueue opens or creates a queue backed by the state file at path.
+//
+// Jobs found in progress are reset to pending and marked Interrupted since their upload was cut short.
+// A last line torn by a crash is dropped from the file, so the next state change starts on a new line.
+// Returns the restored queue or an error.
+func OpenQueue(path string) (*Queue, error) {
+	q := NewQueue()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open state file failed: %w", err)
+	}
+
+	r := bufio.NewReader(f)
+	var complete int64 // size of the file up to the end of the last complete line
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			f.Close()
+			return nil, fmt.Errorf("read state file failed: %w", err)
+		}
+		if err == io.EOF {
+			// a last line without a newline was torn while being written
+			if len(line) > 0 {
+				if err := f.Truncate(complete); err != nil {
+					f.Close()
+					return nil, fmt.Errorf("repair state file failed: %w", err)
+				}
+			}
+			break
+		}
+		complete += int64(len(line))
+		var j Job
+		if err := json.Unmarshal(line, &j); err != nil {
+			// skip lines that were damaged on disk, the next line of the job supersedes them
+			continue
+		}
+		q.set(&j)
+	}
+
+	for _, j := range q.jobs {
+		if j.State == InProgress {
+			j.State = Pending
+			j.Interrupted = true
+		}
+	}
+	q.file = f
+	return q, nil
+}
+
+// set stores j in the queue without persisting it
+func (q *Queue) set(j *Job) {
+	if _, ok := q.jobs[j.ID]; !ok {
+		q.order = append(q.order, j.ID)
+	}
+	q.jobs[j.ID] = j
+}
+
+// persist appends j to the state file and syncs it to disk
+func (q *Queue) persist(j *Job) error {
+	if q.file == nil {
+		return nil
+	}
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := q.file.Write(data); err != nil {
+		return fmt.Errorf("write state file failed: %w", err)
+	}
+	return q.file.Sync()
+}
+
+// Add enqueues the file at path for upload into folderID.
+//
+// Adding a file that is already in the queue keeps its current state, so
+// re-adding the same files after a restart does not upload them twice.
+// Returns the job or an error.
+func (q *Queue) Add(path string, folderID string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	id := jobID(path, folderID)
+	if j, ok := q.jobs[id]; ok {
+		return *j, nil
+	}
+	j := &Job{
+		ID:        id,
+		Path:      path,
+		FolderID:  folderID,
+		State:     Pending,
+		UpdatedAt: time.Now(),
+	}
+	if err := q.persist(j); err != nil {
+		return Job{}, err
+	}
+	q.set(j)
+	return *j, nil
+}
+
+// update applies fn to the job with the specified id, sets its state and persists it
+func (q *Queue) update(id string, state JobState, jobErr error, fn func(j *Job)) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("unknown job: %s", id)
+	}
+	next := *j
+	next.State = state
+	next.Error = ""
+	next.Interrupted = false
+	if jobErr != nil {
+		next.Error = jobErr.Error()
+	}
+	if fn != nil {
+		fn(&next)
+	}
+	next.UpdatedAt = time.Now()
+	if err := q.persist(&next); err != nil {
+		return Job{}, err
+	}
+	*j = next
+	return next, nil
+}
+
+// Jobs returns a snapshot of all jobs in the order they were added
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.order))
+	for _, id := range q.order {
+		jobs = append(jobs, *q.jobs[id])
+	}
+	return jobs
+}
+
+// Remaining returns jobs which are not done yet in the order they were added
+func (q *Queue) Remaining() []Job {
+	var jobs []Job
+	for _, j := range q.Jobs() {
+		if j.State != Done {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs
+}
+
+// Compact rewrites the state file so it only holds the latest state of each job
+func (q *Queue) Compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		return nil
+	}
+	tmpPath := q.file.Name() + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("create state file failed: %w", err)
+	}
+	enc := json.NewEncoder(tmp)
+	for _, id := range q.order {
+		if err := enc.Encode(q.jobs[id]); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, q.file.Name()); err != nil {
+		return fmt.Errorf("replace state file failed: %w", err)
+	}
+
+	f, err := os.OpenFile(q.file.Name(), os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopen state file failed: %w", err)
+	}
+	q.file.Close()
+	q.file = f
+	return nil
+}
+
+// Close releases the state file
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
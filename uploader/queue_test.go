@@ -0,0 +1,155 @@
+package uploader
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openQueue opens the queue stored at path
+func openQueue(t *testing.T, path string) *Queue {
+	t.Helper()
+	q, err := OpenQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+// job returns the job of q with the specified id
+func job(t *testing.T, q *Queue, id string) Job {
+	t.Helper()
+	for _, j := range q.Jobs() {
+		if j.ID == id {
+			return j
+		}
+	}
+	t.Fatalf("job %s not found", id)
+	return Job{}
+}
+
+func TestQueueReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	q := openQueue(t, path)
+	a, err := q.Add("/data/a.txt", "fld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := q.Add("/data/b.txt", "fld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.update(a.ID, InProgress, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.update(b.ID, Done, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q = openQueue(t, path)
+	jobs := q.Jobs()
+	if len(jobs) != 2 || jobs[0].ID != a.ID || jobs[1].ID != b.ID {
+		t.Fatalf("restored %+v, want a.txt then b.txt", jobs)
+	}
+	if got := job(t, q, a.ID); got.State != Pending || !got.Interrupted {
+		t.Errorf("job in progress restored as %s, interrupted %v, want pending and interrupted", got.State, got.Interrupted)
+	}
+	if got := job(t, q, b.ID); got.State != Done {
+		t.Errorf("done job restored as %s", got.State)
+	}
+	if remaining := q.Remaining(); len(remaining) != 1 || remaining[0].ID != a.ID {
+		t.Errorf("remaining %+v, want a.txt only", remaining)
+	}
+
+	// re-adding keeps the restored state
+	if j, err := q.Add("/data/b.txt", "fld"); err != nil || j.State != Done {
+		t.Errorf("re-added job is %s, %v, want done", j.State, err)
+	}
+}
+
+func TestQueueTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	q := openQueue(t, path)
+	a, err := q.Add("/data/a.txt", "fld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	// a damaged line followed by a line torn by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	f.WriteString(`{"id":"fld:/data/b.txt","path":"/da`)
+	f.Close()
+
+	q = openQueue(t, path)
+	if jobs := q.Jobs(); len(jobs) != 1 || jobs[0].ID != a.ID {
+		t.Fatalf("restored %+v, want a.txt only", jobs)
+	}
+	if _, err := q.update(a.ID, Done, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the damaged line is left undecodable, the torn one was dropped before appending
+	var damaged int
+	for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		if !json.Valid(line) {
+			damaged++
+		}
+	}
+	if damaged != 1 {
+		t.Errorf("%d undecodable lines, want 1:\n%s", damaged, data)
+	}
+	q = openQueue(t, path)
+	if got := job(t, q, a.ID); got.State != Done || got.Interrupted {
+		t.Errorf("job restored as %s, interrupted %v, want done", got.State, got.Interrupted)
+	}
+}
+
+func TestQueueCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	q := openQueue(t, path)
+	for _, p := range []string{"/data/a.txt", "/data/b.txt"} {
+		j, err := q.Add(p, "fld")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, state := range []JobState{InProgress, Failed, InProgress, Done} {
+			if _, err := q.update(j.ID, state, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	// the compacted file is still appended to
+	if _, err := q.Add("/data/c.txt", "fld"); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("compacted file has %d lines, want 3", lines)
+	}
+	q = openQueue(t, path)
+	if remaining := q.Remaining(); len(remaining) != 1 || remaining[0].Path != "/data/c.txt" {
+		t.Errorf("remaining %+v, want c.txt only", remaining)
+	}
+}
@@ -0,0 +1,255 @@
+package uploader
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrEventDropped is returned by ChannelSink and WebhookSink when their buffer is full
+	ErrEventDropped = errors.New("event dropped, buffer is full")
+	// ErrSinkClosed is returned by WebhookSink once it is closed
+	ErrSinkClosed = errors.New("sink is closed")
+)
+
+// ChannelSink sends events to a Go channel without blocking the upload, events are dropped when it is full
+type ChannelSink chan<- Event
+
+// Send delivers e to the channel
+func (c ChannelSink) Send(e Event) error {
+	select {
+	case c <- e:
+		return nil
+	default:
+		return ErrEventDropped
+	}
+}
+
+// JSONLinesSink appends every event as a JSON line to a file
+type JSONLinesSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewJSONLinesSink opens or creates the file at path for appending events.
+//
+// Returns the sink or an error.
+func NewJSONLinesSink(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open event log failed: %w", err)
+	}
+	return &JSONLinesSink{file: f}, nil
+}
+
+// Send appends e to the file
+func (s *JSONLinesSink) Send(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file
+func (s *JSONLinesSink) Close() error {
+	return s.file.Close()
+}
+
+// WebhookOptions defines optional configuration for a WebhookSink.
+type WebhookOptions struct {
+	Types      []EventType    // Types are the events sent, defaults to every event except progress
+	RetryCount *int           // RetryCount specifies how many times a failed delivery is retried, defaults to 3
+	RetryDelay *time.Duration // RetryDelay is the delay before the first retry, doubled after each attempt, defaults to 1 second
+	Client     *http.Client   // Client sends the requests, defaults to a client with a 10 second timeout
+	QueueSize  *int           // QueueSize is the number of events waiting for delivery before new ones are dropped, defaults to 100
+}
+
+// WebhookSink POSTs events as JSON to a URL.
+//
+// Events are queued and delivered one by one in the background so a slow or dead endpoint never
+// holds up an upload. Failed deliveries are reported to the OnSinkError of the Uploader using the sink.
+// Call Close once the uploads are done to deliver the queued events.
+//
+// When a secret is set, the body is signed with HMAC-SHA256 and the hex digest is sent in the
+// X-Gofile-Signature header as "sha256=<digest>". Receivers should compute the same digest over
+// the raw body and compare it with hmac.Equal.
+type WebhookSink struct {
+	url        string
+	secret     []byte
+	types      []EventType
+	retryCount int
+	retryDelay time.Duration
+	client     *http.Client
+
+	queue chan Event
+	done  chan struct{} // closed once the queue is drained after Close
+
+	mu      sync.RWMutex
+	closed  bool
+	onError func(e Event, err error) // receives failed deliveries, set by the Uploader
+}
+
+// NewWebhookSink creates a sink posting events to url, signed with secret if it is not empty,
+// and starts delivering them in the background.
+//
+// If opts is nil, default settings are used.
+func NewWebhookSink(url string, secret string, opts *WebhookOptions) *WebhookSink {
+	s := &WebhookSink{
+		url:        url,
+		secret:     []byte(secret),
+		types:      []EventType{EventStarted, EventCompleted, EventFailed, EventVerified},
+		retryCount: 3,
+		retryDelay: time.Second,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		done:       make(chan struct{}),
+	}
+	queueSize := 100
+	if opts != nil {
+		if opts.Types != nil {
+			s.types = opts.Types
+		}
+		if opts.RetryCount != nil {
+			s.retryCount = *opts.RetryCount
+		}
+		if opts.RetryDelay != nil {
+			s.retryDelay = *opts.RetryDelay
+		}
+		if opts.Client != nil {
+			s.client = opts.Client
+		}
+		if opts.QueueSize != nil {
+			queueSize = *opts.QueueSize
+		}
+	}
+	s.queue = make(chan Event, queueSize)
+	go s.run()
+	return s
+}
+
+// Sign returns the value of the X-Gofile-Signature header for body signed with secret
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send queues e for delivery without blocking.
+//
+// Returns ErrEventDropped if the queue is full or ErrSinkClosed after Close.
+func (s *WebhookSink) Send(e Event) error {
+	if !slices.Contains(s.types, e.Type) {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	select {
+	case s.queue <- e:
+		return nil
+	default:
+		return ErrEventDropped
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are delivered
+func (s *WebhookSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+// reportErrors sets the function receiving failed deliveries
+func (s *WebhookSink) reportErrors(fn func(e Event, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onError = fn
+}
+
+// run delivers queued events until the queue is closed
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	for e := range s.queue {
+		err := s.deliver(e)
+		if err == nil {
+			continue
+		}
+		s.mu.RLock()
+		onError := s.onError
+		s.mu.RUnlock()
+		if onError != nil {
+			onError(e, err)
+		}
+	}
+}
+
+// deliver posts e to the webhook, retrying on network errors, 429 and 5xx responses
+func (s *WebhookSink) deliver(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	delay := s.retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(e, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.retryCount {
+			return fmt.Errorf("webhook delivery failed: %w", err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post sends a single delivery and reports whether a failure may be retried
+func (s *WebhookSink) post(e Event, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gofile-Event", string(e.Type))
+	if len(s.secret) > 0 {
+		req.Header.Set("X-Gofile-Signature", Sign(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	// drain the body so the connection can be reused for the next delivery
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
@@ -0,0 +1,137 @@
+package uploader
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+)
+
+func TestWebhookSinkDoesNotBlock(t *testing.T) {
+	received := make(chan struct{}, 10)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	queueSize := 1
+	s := NewWebhookSink(srv.URL, "secret", &WebhookOptions{QueueSize: &queueSize})
+	e := Event{Type: EventCompleted}
+
+	start := time.Now()
+	if err := s.Send(e); err != nil {
+		t.Fatal(err)
+	}
+	<-received // the worker is stuck delivering the first event
+	if err := s.Send(e); err != nil {
+		t.Fatalf("queued event: %v", err)
+	}
+	if err := s.Send(e); !errors.Is(err, ErrEventDropped) {
+		t.Errorf("event sent to a full queue: error = %v, want %v", err, ErrEventDropped)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send blocked for %s", elapsed)
+	}
+
+	close(release)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(received); n != 1 {
+		t.Errorf("%d queued events delivered on Close, want 1", n)
+	}
+	if err := s.Send(e); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("event sent after Close: error = %v, want %v", err, ErrSinkClosed)
+	}
+}
+
+func TestWebhookSinkReportsFailures(t *testing.T) {
+	var mu sync.Mutex
+	var signatures []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		signatures = append(signatures, r.Header.Get("X-Gofile-Signature"), Sign([]byte("secret"), body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	retries, delay := 1, time.Millisecond
+	s := NewWebhookSink(srv.URL, "secret", &WebhookOptions{RetryCount: &retries, RetryDelay: &delay})
+	var failed []Event
+	_, err := New(api.New(nil), Options{
+		Sinks: []Sink{s},
+		OnSinkError: func(e Event, err error) {
+			mu.Lock()
+			failed = append(failed, e)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(Event{Type: EventFailed, JobID: "job"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(Event{Type: EventProgress, JobID: "job"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || failed[0].Type != EventFailed {
+		t.Errorf("failures reported for %+v, want the failed event only", failed)
+	}
+	// one attempt and one retry, progress events are not sent by default
+	if len(signatures) != 4 {
+		t.Fatalf("%d deliveries, want 2", len(signatures)/2)
+	}
+	for i := 0; i < len(signatures); i += 2 {
+		if signatures[i] != signatures[i+1] {
+			t.Errorf("signature %s, want %s", signatures[i], signatures[i+1])
+		}
+	}
+}
+
+func TestWebhookSinkReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// large enough that the client does not drain it on its own when the body is closed
+		w.Write(make([]byte, 1<<20))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	s := NewWebhookSink(srv.URL, "secret", nil)
+	for range 3 {
+		if err := s.Send(Event{Type: EventCompleted, JobID: "job"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("3 deliveries opened %d connections, want 1", conns)
+	}
+}
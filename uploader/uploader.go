@@ -0,0 +1,538 @@
+// package uploader uploads batches of files through the api package and keeps track of their progress
+package uploader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/crypt"
+	"github.com/plutack/go-gofile/internal/client"
+	"github.com/plutack/go-gofile/model"
+	"github.com/plutack/go-gofile/policy"
+)
+
+// Options defines optional configuration for an Uploader.
+type Options struct {
+	Server     string                  // Server is the upload server name, if empty one is picked using GetAvailableServers
+	Zone       string                  // Zone is passed to GetAvailableServers when Server is empty, can be "eu" or "na"
+	StatePath  string                  // StatePath is the file the queue is persisted to, if empty the queue only lives in memory
+	OnProgress client.ProgressCallback // OnProgress receives upload progress of the file currently being uploaded
+
+	// Index enables deduplication: files whose MD5 is found in it are not uploaded again.
+	// Successful uploads are added to it and it is saved at the end of each run. A duplicate is
+	// looked up on gofile before it is reused, entries whose file no longer exists are removed.
+	Index *Index
+	// CopyDuplicates copies a duplicate found in another folder into the job's folder using CopyContent.
+	// This is a premium only feature, if the copy is refused the existing file is used as is.
+	CopyDuplicates bool
+	// CheckQuota fetches the account usage at the start of each run and fails jobs
+	// which would exceed the tier limits without uploading them. gofile does not report the limits,
+	// so api.Options.TierLimits must hold the tier of the account or Run returns api.ErrNoTierLimits.
+	CheckQuota bool
+	// Policy sets the expiry of every uploaded file from the first matching rule.
+	// Rules are matched against the same path policy.Sweep uses, see PolicyRoot.
+	// The policy is validated in New.
+	Policy *policy.Policy
+	// PolicyRoot is the local directory mirroring the folder swept with policy.Sweep: rules are matched
+	// against the path of the file relative to it. If empty, or the file is outside of it, rules are
+	// matched against the file name, as when sweeping the folder the file is uploaded into.
+	PolicyRoot string
+	// Verify compares the MD5 reported by gofile with the local file after each upload,
+	// a mismatch fails the job with ErrChecksumMismatch. Responses without an MD5 are not verified.
+	Verify bool
+	// Encryption encrypts every file on the fly before it is uploaded, see package crypt.
+	// Verify then checks the MD5 of the uploaded ciphertext, and the Index records encrypted
+	// uploads under the MD5 of the local file so unchanged files are still found as duplicates.
+	// Only encrypted copies are reused when it is set, and only plain copies when it is not.
+	Encryption *crypt.Key
+	// EncryptNames also encrypts the names of uploaded files, it is only used with Encryption.
+	EncryptNames bool
+
+	Sinks            []Sink                   // Sinks receive the events of every job, see Event
+	OnSinkError      func(e Event, err error) // OnSinkError is called when a sink fails to receive or deliver an event, possibly from another goroutine, errors are ignored if nil
+	ProgressInterval *time.Duration           // ProgressInterval is the minimum delay between progress events of a job, defaults to 1 second
+}
+
+// Uploader uploads queued files one after the other
+type Uploader struct {
+	api   *api.Api
+	opts  Options
+	queue *Queue
+	usage *api.Usage // usage of the account during the current run, nil unless CheckQuota is set
+}
+
+// New creates an Uploader which uses a to talk to gofile.
+//
+// If opts.StatePath is set, the queue stored in it is restored so a previous run can be resumed.
+// Returns the uploader or an error.
+func New(a *api.Api, opts Options) (*Uploader, error) {
+	if opts.Policy != nil {
+		if err := opts.Policy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range opts.Sinks {
+		if r, ok := s.(errorReporter); ok {
+			r.reportErrors(opts.OnSinkError)
+		}
+	}
+	q := NewQueue()
+	if opts.StatePath != "" {
+		var err error
+		q, err = OpenQueue(opts.StatePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Uploader{
+		api:   a,
+		opts:  opts,
+		queue: q,
+	}, nil
+}
+
+// Queue returns the queue used by the uploader
+func (u *Uploader) Queue() *Queue {
+	return u.queue
+}
+
+// Add enqueues the file at path for upload into folderID.
+//
+// Returns the job or an error.
+func (u *Uploader) Add(path string, folderID string) (Job, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Job{}, err
+	}
+	return u.queue.Add(abs, folderID)
+}
+
+// server returns the configured upload server or picks one
+func (u *Uploader) server() (string, error) {
+	if u.opts.Server != "" {
+		return u.opts.Server, nil
+	}
+	resp, err := u.api.GetAvailableServers(u.opts.Zone)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Data.Servers) == 0 {
+		return "", errors.New("no upload server available")
+	}
+	u.opts.Server = resp.Data.Servers[0].Name
+	return u.opts.Server, nil
+}
+
+// Run uploads every job which is not done yet.
+//
+// A failed upload does not stop the run, it is recorded on the job and retried on the next run.
+// Returns the jobs processed in this run or an error if the queue could not be updated.
+func (u *Uploader) Run() ([]Job, error) {
+	if u.opts.CheckQuota {
+		usage, err := u.api.Usage()
+		if err != nil {
+			return nil, err
+		}
+		if !usage.LimitsSet {
+			return nil, fmt.Errorf("check quota for tier %s: %w", usage.Tier, api.ErrNoTierLimits)
+		}
+		u.usage = &usage
+	}
+
+	var processed []Job
+	for _, j := range u.queue.Remaining() {
+		job, err := u.upload(j)
+		if err != nil {
+			return processed, err
+		}
+		processed = append(processed, job)
+	}
+	if u.opts.Index != nil {
+		if err := u.opts.Index.Save(); err != nil {
+			return processed, err
+		}
+	}
+	return processed, nil
+}
+
+// upload uploads a single job and records its outcome in the queue
+func (u *Uploader) upload(j Job) (Job, error) {
+	if _, err := u.queue.update(j.ID, InProgress, nil, nil); err != nil {
+		return Job{}, err
+	}
+
+	fi, err := os.Stat(j.Path)
+	if err != nil {
+		return u.fail(j, err)
+	}
+	started := jobEvent(EventStarted, j)
+	started.Size = fi.Size()
+	u.emit(started)
+
+	var hash string
+	if j.Interrupted {
+		// the upload cut short by a crash may have landed, uploading again would store a duplicate
+		c, ok, err := u.landed(j, fi.Size(), &hash)
+		if err != nil {
+			return u.fail(j, err)
+		}
+		if ok {
+			return u.finish(j, uploadResponse(c), hash)
+		}
+	}
+	if u.opts.Index != nil {
+		if hash == "" {
+			hash, err = fileMD5(j.Path)
+			if err != nil {
+				return u.fail(j, err)
+			}
+		}
+		e, ok, err := u.lookup(hash, j.FolderID)
+		if err != nil {
+			return u.fail(j, err)
+		}
+		if ok {
+			return u.reuse(j, e, hash)
+		}
+	}
+
+	size := fi.Size()
+	if u.opts.Encryption != nil {
+		size = crypt.EncryptedSize(size)
+	}
+	if u.usage != nil {
+		if err := u.usage.CheckUpload(size); err != nil {
+			return u.fail(j, err)
+		}
+	}
+
+	server, err := u.server()
+	if err != nil {
+		return u.fail(j, err)
+	}
+
+	var resp model.UploadFileResponse
+	remoteHash := &hash
+	if u.opts.Encryption != nil {
+		var sealedHash string
+		resp, sealedHash, err = u.uploadEncrypted(j, server, fi.Size())
+		remoteHash = &sealedHash
+	} else {
+		resp, err = u.api.UploadFile(server, j.Path, j.FolderID, u.progress(j))
+	}
+	if err != nil {
+		return u.fail(j, err)
+	}
+	if u.opts.Verify {
+		if err := u.verify(j, remoteHash, resp.Data.MD5); err != nil {
+			return u.fail(j, err)
+		}
+	}
+	return u.finish(j, resp, hash)
+}
+
+// finish records the stored file resp of a job in the index, applies the policy and marks the job done.
+//
+// hash is the MD5 of the local file, it is computed if empty and needed by an Index of encrypted files.
+func (u *Uploader) finish(j Job, resp model.UploadFileResponse, hash string) (Job, error) {
+	if u.opts.Index != nil {
+		if u.opts.Encryption != nil {
+			// landed does not hash the local file of encrypted jobs, it can not be compared with the ciphertext
+			if hash == "" {
+				var err error
+				if hash, err = fileMD5(j.Path); err != nil {
+					return u.fail(j, err)
+				}
+			}
+			// the MD5 reported by gofile is the one of the ciphertext, which differs on every upload
+			u.opts.Index.Add(hash, IndexEntry{
+				ContentID:    resp.Data.ID,
+				Name:         resp.Data.Name,
+				ParentFolder: resp.Data.ParentFolder,
+				Size:         resp.Data.Size,
+				Encrypted:    true,
+			})
+		} else {
+			u.opts.Index.AddUpload(resp)
+		}
+	}
+	if u.usage != nil {
+		u.usage.StorageUsed += resp.Data.Size
+	}
+	var policyErr error
+	if u.opts.Policy != nil {
+		// the file is uploaded either way, a failure is only recorded on the job
+		_, _, policyErr = u.opts.Policy.ApplyUpload(u.api, resp.Data, u.policyPath(j.Path))
+	}
+	job, err := u.queue.update(j.ID, Done, policyErr, func(j *Job) {
+		j.Result = &resp
+	})
+	if err != nil {
+		return job, err
+	}
+	completed := jobEvent(EventCompleted, job)
+	completed.Size = resp.Data.Size
+	completed.Result = &resp.Data
+	completed.Error = job.Error
+	u.emit(completed)
+	return job, nil
+}
+
+// landed looks in the folder of an interrupted job for the file its upload may have stored.
+//
+// The file is matched by name and size, and by MD5 for plain uploads, hash is computed if empty.
+// Returns the file and whether one was found, or an error if the folder could not be listed.
+func (u *Uploader) landed(j Job, size int64, hash *string) (model.Content, bool, error) {
+	resp, err := u.api.GetContent(j.FolderID)
+	if err != nil {
+		return model.Content{}, false, err
+	}
+	name := filepath.Base(j.Path)
+	if u.opts.Encryption != nil {
+		size = crypt.EncryptedSize(size)
+	}
+	for _, c := range resp.Data.Children {
+		if c.Type != model.FileType || c.Size != size {
+			continue
+		}
+		if u.opts.Encryption != nil && u.opts.EncryptNames {
+			if plain, err := u.opts.Encryption.DecryptName(c.Name); err != nil || plain != name {
+				continue
+			}
+		} else if c.Name != name {
+			continue
+		}
+		// the MD5 of an encrypted copy is the one of its ciphertext, which can not be compared
+		if u.opts.Encryption == nil && c.MD5 != "" {
+			if *hash == "" {
+				if *hash, err = fileMD5(j.Path); err != nil {
+					return model.Content{}, false, err
+				}
+			}
+			if c.MD5 != *hash {
+				continue
+			}
+		}
+		return c, true, nil
+	}
+	return model.Content{}, false, nil
+}
+
+// uploadResponse describes the stored file c as the response of its upload
+func uploadResponse(c model.Content) model.UploadFileResponse {
+	return model.UploadFileResponse{
+		Status: "ok",
+		Data: model.UploadFileData{
+			CreateTime:   c.CreateTime,
+			ID:           c.ID,
+			MD5:          c.MD5,
+			Mimetype:     c.MimeType,
+			ModTime:      c.ModTime,
+			Name:         c.Name,
+			ParentFolder: c.ParentFolder,
+			Size:         c.Size,
+			Type:         c.Type,
+		},
+	}
+}
+
+// policyPath returns the path the policy rules are matched against for the local file at p
+func (u *Uploader) policyPath(p string) string {
+	if u.opts.PolicyRoot != "" {
+		root, err := filepath.Abs(u.opts.PolicyRoot)
+		if err == nil {
+			if rel, err := filepath.Rel(root, p); err == nil && filepath.IsLocal(rel) {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return filepath.Base(p)
+}
+
+// uploadEncrypted encrypts the file of j while uploading it, size is the size of the local file.
+//
+// Returns the upload response and the MD5 of the uploaded ciphertext or an error.
+func (u *Uploader) uploadEncrypted(j Job, server string, size int64) (model.UploadFileResponse, string, error) {
+	f, err := os.Open(j.Path)
+	if err != nil {
+		return model.UploadFileResponse{}, "", err
+	}
+	defer f.Close()
+
+	sealed, err := crypt.EncryptReader(f, u.opts.Encryption)
+	if err != nil {
+		return model.UploadFileResponse{}, "", err
+	}
+	name := filepath.Base(j.Path)
+	if u.opts.EncryptNames {
+		name, err = u.opts.Encryption.EncryptName(name)
+		if err != nil {
+			return model.UploadFileResponse{}, "", err
+		}
+	}
+	h := md5.New()
+	resp, err := u.api.UploadReader(server, name, io.TeeReader(sealed, h), crypt.EncryptedSize(size), j.FolderID, u.progress(j))
+	if err != nil {
+		return resp, "", err
+	}
+	return resp, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verify checks the MD5 reported by gofile against the local file, hash is computed if empty.
+//
+// Returns ErrChecksumMismatch if they differ.
+func (u *Uploader) verify(j Job, hash *string, remote string) error {
+	if remote == "" {
+		return nil
+	}
+	if *hash == "" {
+		var err error
+		*hash, err = fileMD5(j.Path)
+		if err != nil {
+			return err
+		}
+	}
+	if remote != *hash {
+		return fmt.Errorf("%w: local %s, gofile %s", ErrChecksumMismatch, *hash, remote)
+	}
+	e := jobEvent(EventVerified, j)
+	e.MD5 = *hash
+	u.emit(e)
+	return nil
+}
+
+// fail records err on the job and emits a failed event
+func (u *Uploader) fail(j Job, jobErr error) (Job, error) {
+	job, err := u.queue.update(j.ID, Failed, jobErr, nil)
+	if err != nil {
+		return job, err
+	}
+	e := jobEvent(EventFailed, job)
+	e.Error = jobErr.Error()
+	u.emit(e)
+	return job, nil
+}
+
+// lookup returns a copy of the file with hash md5 from the index, preferring one stored in folderID.
+//
+// Each candidate is checked against gofile first, entries whose file was deleted or replaced are
+// dropped from the index and the next one is tried.
+// Returns the entry, whether one was found, or an error if a check failed.
+func (u *Uploader) lookup(md5 string, folderID string) (IndexEntry, bool, error) {
+	// only reuse copies stored the same way, an encrypted run must never reuse a plain copy
+	lookup := u.opts.Index.Lookup
+	if u.opts.Encryption != nil {
+		lookup = u.opts.Index.LookupEncrypted
+	}
+	for {
+		e, ok := lookup(md5, folderID)
+		if !ok {
+			return IndexEntry{}, false, nil
+		}
+		resp, err := u.api.GetContent(e.ContentID)
+		if errors.Is(err, api.ErrNotFound) {
+			u.opts.Index.Remove(e.ContentID)
+			continue
+		}
+		if err != nil {
+			return IndexEntry{}, false, fmt.Errorf("check duplicate %s: %w", e.ContentID, err)
+		}
+		c := resp.Data
+		// the MD5 of an encrypted copy is the one of its ciphertext, only its size can be compared
+		if c.Type == model.FolderType || c.Size != e.Size || (!e.Encrypted && c.MD5 != "" && c.MD5 != md5) {
+			u.opts.Index.Remove(e.ContentID)
+			continue
+		}
+		// the file may have been moved since it was indexed
+		if c.ParentFolder != "" {
+			e.ParentFolder = c.ParentFolder
+		}
+		return e, true, nil
+	}
+}
+
+// reuse completes a job with the existing file e, whose local content has hash md5, instead of uploading it again.
+//
+// A copy made into the folder of the job becomes its result and is added to the index, a copy
+// which can not be found afterwards is recorded as the error of the job.
+func (u *Uploader) reuse(j Job, e IndexEntry, md5 string) (Job, error) {
+	copied := false
+	var result *model.UploadFileResponse
+	var copyErr error
+	if e.ParentFolder != j.FolderID && u.opts.CopyDuplicates {
+		_, err := u.api.CopyContent(j.FolderID, e.ContentID)
+		if err != nil && !errors.Is(err, api.ErrNotPremium) {
+			return u.fail(j, err)
+		}
+		copied = err == nil
+	}
+	if copied {
+		// gofile does not return the ID of the copy, it is looked up in the folder
+		c, ok, err := u.copyOf(j.FolderID, e)
+		switch {
+		case err != nil:
+			copyErr = fmt.Errorf("find copy of %s: %w", e.ContentID, err)
+		case !ok:
+			copyErr = fmt.Errorf("copy of %s not found in %s", e.ContentID, j.FolderID)
+		default:
+			resp := uploadResponse(c)
+			result = &resp
+			u.opts.Index.Add(md5, IndexEntry{
+				ContentID:    c.ID,
+				Name:         c.Name,
+				ParentFolder: j.FolderID,
+				Size:         c.Size,
+				Encrypted:    e.Encrypted,
+			})
+		}
+	}
+	job, err := u.queue.update(j.ID, Done, copyErr, func(j *Job) {
+		j.Duplicate = &e
+		j.Copied = copied
+		j.Result = result
+	})
+	if err != nil {
+		return job, err
+	}
+	completed := jobEvent(EventCompleted, job)
+	completed.Duplicate = &e
+	if result != nil {
+		completed.Result = &result.Data
+	}
+	completed.Error = job.Error
+	u.emit(completed)
+	return job, nil
+}
+
+// copyOf looks in the folder with the specified folderID for the copy of the file e, the newest one if several match.
+//
+// Returns the copy and whether one was found, or an error if the folder could not be listed.
+func (u *Uploader) copyOf(folderID string, e IndexEntry) (model.Content, bool, error) {
+	resp, err := u.api.GetContent(folderID)
+	if err != nil {
+		return model.Content{}, false, err
+	}
+	var found model.Content
+	ok := false
+	for _, c := range resp.Data.Children {
+		if c.Type != model.FileType || c.ID == e.ContentID || c.Name != e.Name || c.Size != e.Size {
+			continue
+		}
+		if !ok || c.CreateTime.After(found.CreateTime.Time) || (c.CreateTime.Equal(found.CreateTime.Time) && c.ID > found.ID) {
+			found, ok = c, true
+		}
+	}
+	return found, ok, nil
+}
+
+// Close releases the queue state file
+func (u *Uploader) Close() error {
+	return u.queue.Close()
+}
@@ -0,0 +1,202 @@
+package uploader
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plutack/go-gofile/api"
+	"github.com/plutack/go-gofile/cassette"
+	"github.com/plutack/go-gofile/crypt"
+	"github.com/plutack/go-gofile/faultinject"
+)
+
+// helloMD5 is the MD5 of the content of the file created by testFile
+const helloMD5 = "5eb63bbbe01eeed093cb22bb8f5acdc3"
+
+// requestLog records the method and path of every request sent through it
+type requestLog struct {
+	rt http.RoundTripper
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (l *requestLog) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	l.sent = append(l.sent, req.Method+" "+req.URL.Path)
+	l.mu.Unlock()
+	return l.rt.RoundTrip(req)
+}
+
+// has reports whether a request with method and path was sent
+func (l *requestLog) has(method string, path string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.sent, method+" "+path)
+}
+
+// interaction returns a recorded gofile response with status "ok" and data
+func interaction(method string, path string, data any) cassette.Interaction {
+	body, _ := json.Marshal(map[string]any{"status": "ok", "data": data})
+	return cassette.Interaction{
+		Request: cassette.Request{Method: method, URL: "https://api.gofile.io" + path},
+		Response: cassette.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       body,
+		},
+	}
+}
+
+// matchPath matches interactions on method and URL path only, uploads have a random multipart body
+func matchPath(req *http.Request, body []byte, i cassette.Interaction) bool {
+	u, err := url.Parse(i.Request.URL)
+	return err == nil && req.Method == i.Request.Method && req.URL.Path == u.Path
+}
+
+// replay returns an Api answering from interactions, each answering a single request, after
+// the scripted faults were applied to the first requests
+func replay(t *testing.T, faults []faultinject.Fault, interactions ...cassette.Interaction) (*api.Api, *requestLog) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	data, err := json.Marshal(interactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := cassette.New(path, cassette.ModeReplay, &cassette.Options{Match: matchPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &requestLog{rt: rec}
+	token, retries := "token", 0
+	a := api.New(&api.Options{
+		APIToken:   &token,
+		RetryCount: &retries,
+		Transport:  faultinject.New(&faultinject.Options{Transport: log, Script: faults}),
+	})
+	return a, log
+}
+
+// testFile creates a file named report.txt holding "hello world" and returns its path
+func testFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("hello world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// uploaded returns the recorded response of the upload of report.txt into the folder fld
+func uploaded() cassette.Interaction {
+	return interaction(http.MethodPost, "/contents/uploadfile", map[string]any{
+		"id": "new", "type": "file", "name": "report.txt", "size": 11, "md5": helloMD5, "parentFolder": "fld",
+	})
+}
+
+// run uploads the file at path into the folder fld and returns the job
+func run(t *testing.T, a *api.Api, path string, opts Options) Job {
+	t.Helper()
+	opts.Server = "store1"
+	u, err := New(a, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	if _, err := u.Add(path, "fld"); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := u.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("%d jobs processed, want 1", len(jobs))
+	}
+	return jobs[0]
+}
+
+func TestRunRetriesFailedJobs(t *testing.T) {
+	path := testFile(t)
+	state := filepath.Join(t.TempDir(), "state.jsonl")
+	a, _ := replay(t, []faultinject.Fault{faultinject.Status(http.StatusServiceUnavailable)}, uploaded())
+
+	j := run(t, a, path, Options{StatePath: state})
+	if j.State != Failed || j.Error == "" {
+		t.Fatalf("first run: job is %s with error %q, want failed", j.State, j.Error)
+	}
+	j = run(t, a, path, Options{StatePath: state})
+	if j.State != Done || j.Result == nil || j.Result.Data.ID != "new" {
+		t.Errorf("second run: job is %s with result %+v, want done", j.State, j.Result)
+	}
+}
+
+// interrupted creates the test file and a state file recording that the previous run crashed while
+// uploading it into the folder fld, and returns their paths
+func interrupted(t *testing.T) (path string, state string) {
+	t.Helper()
+	path, err := filepath.Abs(testFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state = filepath.Join(t.TempDir(), "state.jsonl")
+	line, _ := json.Marshal(Job{ID: jobID(path, "fld"), Path: path, FolderID: "fld", State: InProgress, UpdatedAt: time.Now()})
+	if err := os.WriteFile(state, append(line, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, state
+}
+
+func TestInterruptedUploadLanded(t *testing.T) {
+	path, state := interrupted(t)
+	a, log := replay(t, nil, interaction(http.MethodGet, "/contents/fld", map[string]any{
+		"id": "fld", "type": "folder", "name": "fld",
+		"children": map[string]any{
+			"landed": map[string]any{"id": "landed", "type": "file", "name": "report.txt", "size": 11, "md5": helloMD5, "parentFolder": "fld"},
+		},
+	}))
+
+	j := run(t, a, path, Options{StatePath: state})
+	if j.State != Done || j.Result == nil || j.Result.Data.ID != "landed" {
+		t.Errorf("job is %s with result %+v, want the landed file", j.State, j.Result)
+	}
+	if log.has(http.MethodPost, "/contents/uploadfile") {
+		t.Error("the landed file was uploaded again")
+	}
+}
+
+func TestInterruptedEncryptedUploadIndexed(t *testing.T) {
+	path, state := interrupted(t)
+	key, err := crypt.NewKey(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := replay(t, nil, interaction(http.MethodGet, "/contents/fld", map[string]any{
+		"id": "fld", "type": "folder", "name": "fld",
+		"children": map[string]any{
+			"sealed": map[string]any{"id": "sealed", "type": "file", "name": "report.txt", "size": crypt.EncryptedSize(11), "md5": "ciphertext", "parentFolder": "fld"},
+		},
+	}))
+	idx := NewIndex()
+
+	j := run(t, a, path, Options{StatePath: state, Encryption: key, Index: idx})
+	if j.State != Done || j.Result == nil || j.Result.Data.ID != "sealed" {
+		t.Fatalf("job is %s with result %+v, want the landed file", j.State, j.Result)
+	}
+	if e, ok := idx.LookupEncrypted(helloMD5, "fld"); !ok || e.ContentID != "sealed" {
+		t.Errorf("index holds %+v under the MD5 of the local file, want the landed file", e)
+	}
+	if e, ok := idx.LookupEncrypted("", "fld"); ok {
+		t.Errorf("index holds %+v under an empty MD5", e)
+	}
+}